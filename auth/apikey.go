@@ -0,0 +1,33 @@
+package auth
+
+import "strings"
+
+//APIKeyPrefix starts every API key, telling them apart from session tokens
+const APIKeyPrefix = "mmk_"
+
+//displayLength is how many characters of a key are kept to show it to its owner
+const displayLength = len(APIKeyPrefix) + 6
+
+//NewAPIKey generates a random API key. Like session tokens only its hash should ever be stored
+func NewAPIKey() (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	return APIKeyPrefix + token, nil
+}
+
+//IsAPIKey reports whether the bearer token is an API key rather than a session token
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+//KeyPrefix returns the part of an API key that is kept to show it to its owner
+func KeyPrefix(key string) string {
+	if len(key) < displayLength {
+		return key
+	}
+
+	return key[:displayLength]
+}
@@ -0,0 +1,29 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestAPIKeys(t *testing.T) {
+	convey.Convey("Generating API keys...", t, func() {
+		key, err := auth.NewAPIKey()
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Keys should be told apart from session tokens\n", func() {
+			token, err := auth.NewToken()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(auth.IsAPIKey(key), convey.ShouldBeTrue)
+			convey.So(auth.IsAPIKey(token), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Only the start of a key should be kept for display\n", func() {
+			prefix := auth.KeyPrefix(key)
+			convey.So(strings.HasPrefix(key, prefix), convey.ShouldBeTrue)
+			convey.So(len(prefix), convey.ShouldBeLessThan, len(key)/2)
+		})
+	})
+}
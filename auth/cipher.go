@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+//ErrDecrypt is returned for ciphertexts that were not encrypted with the cipher's key or have been tampered with
+var ErrDecrypt = errors.New("unable to decrypt")
+
+//Cipher encrypts secrets stored in the database with AES-256-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+//NewCipher creates a cipher whose key is derived from the given passphrase
+func NewCipher(passphrase string) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("Encryption key cannot be empty")
+	}
+
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+//Encrypt returns the base64 encoded nonce and ciphertext of the plaintext
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+//Decrypt reverses Encrypt
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+
+	plaintext, err := c.aead.Open(nil, data[:c.aead.NonceSize()], data[c.aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 128
+)
+
+//ErrWeakPassword is returned for passwords too short to be accepted or made of letters only
+var ErrWeakPassword = fmt.Errorf("password must be at least %d characters and contain a digit or symbol",
+	MinPasswordLength)
+
+//ErrLongPassword is returned for passwords longer than MaxPasswordLength
+var ErrLongPassword = fmt.Errorf("password must be at most %d characters", MaxPasswordLength)
+
+//ErrWrongPassword is returned when the password given does not match the user's
+var ErrWrongPassword = errors.New("password incorrect")
+
+//ValidatePassword checks a new password is acceptable
+func ValidatePassword(password string) error {
+	n := utf8.RuneCountInString(password)
+	if n > MaxPasswordLength {
+		return ErrLongPassword
+	} else if n < MinPasswordLength || strings.IndexFunc(password, isNotLetter) < 0 {
+		return ErrWeakPassword
+	}
+
+	return nil
+}
+
+func isNotLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+//Passwords are hashed with argon2id using the parameters OWASP recommends, memory in KiB
+const (
+	argonTime    = 2
+	argonMemory  = 19 * 1024
+	argonThreads = 1
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+//argonPrefix starts the PHC string format of argon2id hashes, which records the parameters next to the salt and hash
+const argonPrefix = "$argon2id$"
+
+//dummyHash is checked against when a login names no account, so those take as long as wrong passwords
+var dummyHash, _ = HashPassword("no such account")
+
+//HashPassword returns the argon2id hash of the password, with a random salt, to store in place of the password
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argonPrefix, argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+//CheckPassword compares the given password with the stored hash in constant time. Passwords stored before they were
+//hashed are compared as they are, NeedsRehash reports those so they can be replaced by their hash
+func CheckPassword(stored, given string) bool {
+	if !strings.HasPrefix(stored, argonPrefix) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+	}
+
+	var version int
+	var memory, iterations uint32
+	var threads uint8
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads)
+	if err != nil {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(given), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, actual) == 1
+}
+
+//CheckMissingPassword spends the time checking a password takes, for logins naming no account
+func CheckMissingPassword(given string) {
+	CheckPassword(dummyHash, given)
+}
+
+//NeedsRehash reports whether the stored password is not an argon2id hash with the current parameters
+func NeedsRehash(stored string) bool {
+	return !strings.HasPrefix(stored, fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$", argonPrefix, argon2.Version, argonMemory,
+		argonTime, argonThreads))
+}
@@ -0,0 +1,55 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPasswords(t *testing.T) {
+	convey.Convey("Checking passwords...", t, func() {
+		convey.Convey("Short passwords should be rejected\n", func() {
+			convey.So(auth.ValidatePassword("short"), convey.ShouldEqual, auth.ErrWeakPassword)
+			convey.So(auth.ValidatePassword("long enough"), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Passwords of letters only or too long should be rejected\n", func() {
+			convey.So(auth.ValidatePassword("onlyletters"), convey.ShouldEqual, auth.ErrWeakPassword)
+			convey.So(auth.ValidatePassword("letters4ever"), convey.ShouldBeNil)
+			convey.So(auth.ValidatePassword(strings.Repeat("a1", 65)), convey.ShouldEqual, auth.ErrLongPassword)
+		})
+
+		convey.Convey("Only the exact password should match\n", func() {
+			convey.So(auth.CheckPassword("secret123", "secret123"), convey.ShouldBeTrue)
+			convey.So(auth.CheckPassword("secret123", "secret12"), convey.ShouldBeFalse)
+			convey.So(auth.CheckPassword("secret123", ""), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Hashed passwords should match the password only, with a new salt every time\n", func() {
+			hash, err := auth.HashPassword("secret123")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(hash, convey.ShouldStartWith, "$argon2id$")
+			convey.So(hash, convey.ShouldNotContainSubstring, "secret123")
+			convey.So(auth.CheckPassword(hash, "secret123"), convey.ShouldBeTrue)
+			convey.So(auth.CheckPassword(hash, "secret12"), convey.ShouldBeFalse)
+			convey.So(auth.CheckPassword(hash, hash), convey.ShouldBeFalse)
+
+			other, _ := auth.HashPassword("secret123")
+			convey.So(other, convey.ShouldNotEqual, hash)
+		})
+
+		convey.Convey("Passwords stored in plain text should need rehashing, hashes should not\n", func() {
+			hash, _ := auth.HashPassword("secret123")
+			convey.So(auth.NeedsRehash("secret123"), convey.ShouldBeTrue)
+			convey.So(auth.NeedsRehash(hash), convey.ShouldBeFalse)
+			convey.So(auth.NeedsRehash("$argon2id$v=19$m=4096,t=1,p=1$c2FsdA$aGFzaA"), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Malformed hashes should never match\n", func() {
+			convey.So(auth.CheckPassword("$argon2id$v=19$m=19456,t=2,p=1$!!$!!", "secret123"), convey.ShouldBeFalse)
+			convey.So(auth.CheckPassword("$argon2id$garbage", "$argon2id$garbage"), convey.ShouldBeFalse)
+		})
+	})
+}
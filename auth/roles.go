@@ -0,0 +1,71 @@
+package auth
+
+import "github.com/danny-m08/music-match/types"
+
+//Permission is an action only some roles may take
+type Permission string
+
+const (
+	PermModerateComments Permission = "comments:moderate"
+	PermRemoveListings   Permission = "listings:remove"
+	PermViewUsers        Permission = "users:view"
+	PermSuspendUsers     Permission = "users:suspend"
+	PermManageRoles      Permission = "users:roles"
+	PermViewTransactions Permission = "transactions:view"
+	PermViewAuditLog     Permission = "audit:view"
+)
+
+//grants lists the permissions each role holds. Users and sellers only act on what they own and need none
+var grants = map[types.Role][]Permission{
+	types.RoleModerator: {PermModerateComments, PermRemoveListings, PermViewUsers, PermSuspendUsers},
+	types.RoleAdmin: {PermModerateComments, PermRemoveListings, PermViewUsers, PermSuspendUsers, PermManageRoles,
+		PermViewTransactions, PermViewAuditLog},
+}
+
+//ranks orders the roles by authority. Staff can only manage users who rank below them
+var ranks = map[types.Role]int{
+	types.RoleUser:      0,
+	types.RoleSeller:    0,
+	types.RoleModerator: 1,
+	types.RoleAdmin:     2,
+}
+
+//ValidRole reports whether role is one of the known roles
+func ValidRole(role types.Role) bool {
+	_, ok := ranks[role]
+	return ok
+}
+
+//Can reports whether any of the user's roles grants the permission
+func Can(user *types.User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+
+	for _, role := range user.Roles {
+		for _, granted := range grants[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//Outranks reports whether the actor's highest role ranks above the target's, which staff need to suspend a user or
+//change their roles. Nobody outranks themselves
+func Outranks(actor, target *types.User) bool {
+	return actor.Username != target.Username && rank(actor) > rank(target)
+}
+
+func rank(user *types.User) int {
+	highest := 0
+	for _, role := range user.Roles {
+		if ranks[role] > highest {
+			highest = ranks[role]
+		}
+	}
+
+	return highest
+}
@@ -0,0 +1,47 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoles(t *testing.T) {
+	user := &types.User{Username: "user", Roles: []types.Role{types.RoleUser, types.RoleSeller}}
+	moderator := &types.User{Username: "moderator", Roles: []types.Role{types.RoleUser, types.RoleModerator}}
+	admin := &types.User{Username: "admin", Roles: []types.Role{types.RoleUser, types.RoleAdmin}}
+
+	convey.Convey("Checking permissions...", t, func() {
+		convey.Convey("Users and sellers should hold no staff permissions\n", func() {
+			convey.So(auth.Can(user, auth.PermRemoveListings), convey.ShouldBeFalse)
+			convey.So(auth.Can(nil, auth.PermRemoveListings), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Moderators should moderate but not manage roles or see transactions\n", func() {
+			convey.So(auth.Can(moderator, auth.PermRemoveListings), convey.ShouldBeTrue)
+			convey.So(auth.Can(moderator, auth.PermSuspendUsers), convey.ShouldBeTrue)
+			convey.So(auth.Can(moderator, auth.PermManageRoles), convey.ShouldBeFalse)
+			convey.So(auth.Can(moderator, auth.PermViewTransactions), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Admins should hold every permission\n", func() {
+			convey.So(auth.Can(admin, auth.PermManageRoles), convey.ShouldBeTrue)
+			convey.So(auth.Can(admin, auth.PermViewAuditLog), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Staff should only manage users ranking below them\n", func() {
+			convey.So(auth.Outranks(moderator, user), convey.ShouldBeTrue)
+			convey.So(auth.Outranks(moderator, admin), convey.ShouldBeFalse)
+			convey.So(auth.Outranks(admin, moderator), convey.ShouldBeTrue)
+			convey.So(auth.Outranks(admin, admin), convey.ShouldBeFalse)
+			convey.So(auth.Outranks(user, &types.User{Username: "other"}), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Only known roles should be valid\n", func() {
+			convey.So(auth.ValidRole(types.RoleModerator), convey.ShouldBeTrue)
+			convey.So(auth.ValidRole("owner"), convey.ShouldBeFalse)
+		})
+	})
+}
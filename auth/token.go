@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const tokenLength = 32
+
+//NewToken generates a random URL safe token. Only the hash of a token returned by HashToken should ever be stored
+func NewToken() (string, error) {
+	buf := make([]byte, tokenLength)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+//HashToken returns the hex encoded SHA-256 hash of the token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
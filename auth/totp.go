@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits       = 6
+	totpPeriod       = 30
+	totpSkew         = 1
+	totpSecretLength = 20
+	recoveryLength   = 10
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+//NewTOTPSecret generates a random base32 encoded TOTP secret
+func NewTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretLength)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(buf), nil
+}
+
+//TOTPStep returns the RFC 6238 time step t falls into
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+//TOTPCode computes the code of the base32 encoded secret for a time step
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo), nil
+}
+
+//ValidateTOTP checks the code against the secret, allowing for one step of clock drift either way. It returns the
+//step the code belongs to so callers can refuse codes that have been used before
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+//TOTPURI builds the otpauth:// provisioning URI authenticator apps read from a QR code
+func TOTPURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+//NewRecoveryCodes generates n one-time recovery codes. Like tokens, only their hashes should be stored, using
+//HashRecoveryCode
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		buf := make([]byte, recoveryLength*5/8)
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(secretEncoding.EncodeToString(buf))
+		codes = append(codes, code[:recoveryLength/2]+"-"+code[recoveryLength/2:])
+	}
+
+	return codes, nil
+}
+
+//HashRecoveryCode hashes a recovery code ignoring case and dashes, so codes can be typed in however they are read
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
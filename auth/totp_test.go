@@ -0,0 +1,74 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTOTP(t *testing.T) {
+	convey.Convey("Time-based one-time passwords...", t, func() {
+		// The SHA-1 secret of the RFC 6238 test vectors
+		secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+		convey.Convey("Codes should match the RFC 6238 test vectors\n", func() {
+			for unix, code := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+				actual, err := auth.TOTPCode(secret, auth.TOTPStep(time.Unix(unix, 0)))
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(actual, convey.ShouldEqual, code)
+			}
+		})
+
+		convey.Convey("Codes of neighbouring steps should be accepted, older ones should not\n", func() {
+			now := time.Unix(1111111109, 0)
+			step, ok := auth.ValidateTOTP(secret, "081804", now.Add(30*time.Second))
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(step, convey.ShouldEqual, auth.TOTPStep(now))
+
+			_, ok = auth.ValidateTOTP(secret, "081804", now.Add(2*time.Minute))
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+
+		convey.Convey("New secrets should round trip through the provisioning URI\n", func() {
+			secret, err := auth.NewTOTPSecret()
+			convey.So(err, convey.ShouldBeNil)
+
+			uri := auth.TOTPURI("Music Match", "alice", secret)
+			convey.So(uri, convey.ShouldStartWith, "otpauth://totp/Music%20Match:alice?")
+			convey.So(uri, convey.ShouldContainSubstring, "secret="+secret)
+		})
+
+		convey.Convey("Recovery codes should hash the same however they are typed\n", func() {
+			codes, err := auth.NewRecoveryCodes(10)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(codes, convey.ShouldHaveLength, 10)
+			convey.So(codes[0], convey.ShouldNotEqual, codes[1])
+			convey.So(auth.HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))), convey.ShouldEqual, auth.HashRecoveryCode(codes[0]))
+		})
+	})
+}
+
+func TestCipher(t *testing.T) {
+	convey.Convey("Encrypting secrets...", t, func() {
+		c, err := auth.NewCipher("passphrase")
+		convey.So(err, convey.ShouldBeNil)
+
+		ciphertext, err := c.Encrypt("secret")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ciphertext, convey.ShouldNotContainSubstring, "secret")
+
+		convey.Convey("The plaintext should only be recovered with the same key\n", func() {
+			plaintext, err := c.Decrypt(ciphertext)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(plaintext, convey.ShouldEqual, "secret")
+
+			other, _ := auth.NewCipher("other")
+			_, err = other.Decrypt(ciphertext)
+			convey.So(err, convey.ShouldEqual, auth.ErrDecrypt)
+		})
+	})
+}
@@ -57,3 +57,114 @@ func (config *Config) GetDBConfig() *Neo4jConfig {
 func (config *Config) GetHTTPServerConfig() *HTTPConfig {
 	return config.Http
 }
+
+//GetAuthConfig returns the auth config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetAuthConfig() *AuthConfig {
+	if config.Auth == nil {
+		return &AuthConfig{}
+	}
+
+	return config.Auth
+}
+
+//GetTaxConfig returns the tax config of the global config object, falling back to no tax rules if none is set
+func (config *Config) GetTaxConfig() *TaxConfig {
+	if config.Tax == nil {
+		return &TaxConfig{}
+	}
+
+	return config.Tax
+}
+
+//GetStorageConfig returns the storage config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetStorageConfig() *StorageConfig {
+	if config.Storage == nil {
+		return &StorageConfig{}
+	}
+
+	return config.Storage
+}
+
+//GetDownloadConfig returns the download config of the global config object, falling back to the defaults if none is
+//set
+func (config *Config) GetDownloadConfig() *DownloadConfig {
+	if config.Downloads == nil {
+		return &DownloadConfig{}
+	}
+
+	return config.Downloads
+}
+
+//GetFeedConfig returns the feed config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetFeedConfig() *FeedConfig {
+	if config.Feed == nil {
+		return &FeedConfig{}
+	}
+
+	return config.Feed
+}
+
+//GetEmailConfig returns the email config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetEmailConfig() *EmailConfig {
+	if config.Email == nil {
+		return &EmailConfig{}
+	}
+
+	return config.Email
+}
+
+//GetThrottleConfig returns the throttle config of the global config object, falling back to the defaults if none is
+//set
+func (config *Config) GetThrottleConfig() *ThrottleConfig {
+	if config.Throttle == nil {
+		return &ThrottleConfig{}
+	}
+
+	return config.Throttle
+}
+
+//GetOAuthConfig returns the OAuth config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetOAuthConfig() *OAuthConfig {
+	if config.OAuth == nil {
+		return &OAuthConfig{}
+	}
+
+	return config.OAuth
+}
+
+//GetHealthConfig returns the health check config of the global config object, falling back to the defaults if none is
+//set
+func (config *Config) GetHealthConfig() *HealthConfig {
+	if config.Health == nil {
+		return &HealthConfig{}
+	}
+
+	return config.Health
+}
+
+//GetMetricsConfig returns the metrics config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetMetricsConfig() *MetricsConfig {
+	if config.Metrics == nil {
+		return &MetricsConfig{}
+	}
+
+	return config.Metrics
+}
+
+//GetTracingConfig returns the tracing config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetTracingConfig() *TracingConfig {
+	if config.Tracing == nil {
+		return &TracingConfig{}
+	}
+
+	return config.Tracing
+}
+
+//GetLoggingConfig returns the logging config of the global config object, falling back to the defaults if none is set
+func (config *Config) GetLoggingConfig() *LoggingConfig {
+	if config.Logging == nil {
+		return &LoggingConfig{}
+	}
+
+	return config.Logging
+}
@@ -1,14 +1,78 @@
 package config
 
+import "time"
+
 type Config struct {
-	DB   *Neo4jConfig `yaml:"neo4j"`
-	Http *HTTPConfig  `yaml:"http"`
+	DB        *Neo4jConfig    `yaml:"neo4j"`
+	Http      *HTTPConfig     `yaml:"http"`
+	Auth      *AuthConfig     `yaml:"auth,omitempty"`
+	Tax       *TaxConfig      `yaml:"tax,omitempty"`
+	Storage   *StorageConfig  `yaml:"storage,omitempty"`
+	Downloads *DownloadConfig `yaml:"downloads,omitempty"`
+	Feed      *FeedConfig     `yaml:"feed,omitempty"`
+	Email     *EmailConfig    `yaml:"email,omitempty"`
+	Throttle  *ThrottleConfig `yaml:"throttle,omitempty"`
+	OAuth     *OAuthConfig    `yaml:"oauth,omitempty"`
+	Health    *HealthConfig   `yaml:"health,omitempty"`
+	Metrics   *MetricsConfig  `yaml:"metrics,omitempty"`
+	Tracing   *TracingConfig  `yaml:"tracing,omitempty"`
+	Logging   *LoggingConfig  `yaml:"logging,omitempty"`
 }
 
-//HTTPConfig provisions an http server from the given config
+//HTTPConfig configures the API server. ReadTimeout and WriteTimeout bound reading a request and writing its response,
+//IdleTimeout how long kept-alive connections wait for the next request. ShutdownTimeout is how long in-flight requests
+//get to finish once the server is asked to stop, WorkerTimeout how long background jobs get after that
 type HTTPConfig struct {
-	ListenAddr string     `yaml:"listen-address"`
-	TLS        *TLSConfig `yaml:"tls,omitempty"`
+	ListenAddr      string        `yaml:"listen-address"`
+	TLS             *TLSConfig    `yaml:"tls,omitempty"`
+	CORS            *CORSConfig   `yaml:"cors,omitempty"`
+	ReadTimeout     time.Duration `yaml:"read-timeout,omitempty"`
+	WriteTimeout    time.Duration `yaml:"write-timeout,omitempty"`
+	IdleTimeout     time.Duration `yaml:"idle-timeout,omitempty"`
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout,omitempty"`
+	WorkerTimeout   time.Duration `yaml:"worker-timeout,omitempty"`
+}
+
+//CORSConfig lists the origins browsers may call the API from, "*" allowing any. No origin is allowed when it is not
+//set. MaxAge is how long browsers may cache the answer to a preflight request
+type CORSConfig struct {
+	AllowedOrigins []string      `yaml:"allowed-origins"`
+	MaxAge         time.Duration `yaml:"max-age,omitempty"`
+}
+
+//HealthConfig tunes the readiness checks. Results are cached for CacheTTL so frequent probes do not load the
+//dependencies, and a check taking longer than Timeout fails. Emails still waiting OutboxLag after they were due mean the
+//outbox is backed up. DrainDelay is how long the server reports not ready before it stops accepting connections when
+//shutting down, giving load balancers time to notice
+type HealthConfig struct {
+	CacheTTL   time.Duration `yaml:"cache-ttl,omitempty"`
+	Timeout    time.Duration `yaml:"timeout,omitempty"`
+	OutboxLag  time.Duration `yaml:"outbox-lag,omitempty"`
+	DrainDelay time.Duration `yaml:"drain-delay,omitempty"`
+}
+
+//MetricsConfig protects the metrics endpoint. When Token is set scrapers must send it as a bearer token, otherwise the
+//metrics, revenue included, are served to anyone
+type MetricsConfig struct {
+	Token string `yaml:"token,omitempty"`
+}
+
+//TracingConfig configures where spans go. Exporter is "otlp" to send them to a collector listening for OTLP over HTTP
+//at Endpoint, with Headers added to every export, "stdout" to print them for local debugging, or empty to record none.
+//SampleRatio is the share of new traces recorded, all of them if unset; traces started by a caller follow its decision
+type TracingConfig struct {
+	Exporter    string            `yaml:"exporter,omitempty"`
+	Endpoint    string            `yaml:"endpoint,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	ServiceName string            `yaml:"service-name,omitempty"`
+	SampleRatio float64           `yaml:"sample-ratio,omitempty"`
+}
+
+//LoggingConfig configures the log output. Level is the lowest level logged, one of debug, info, warn and error, info
+//if unset. Format is "json" for one JSON object per line or "console" for human readable lines, console if unset
+type LoggingConfig struct {
+	Level  string `yaml:"level,omitempty"`
+	Format string `yaml:"format,omitempty"`
 }
 
 //TLSConfig provides TLS configuration options for the server
@@ -19,6 +83,101 @@ type TLSConfig struct {
 	CAFile   string `yaml:"ca-path"`
 }
 
+//AuthConfig configures user sessions, email verification, password resets and two-factor authentication. Accounts
+//that have not verified their email address within UnverifiedTTL of signing up are deleted. EncryptionKey encrypts
+//TOTP secrets at rest; two-factor enrollment is unavailable without it. Admins are the usernames granted the admin
+//role on startup
+type AuthConfig struct {
+	SessionTTL      time.Duration `yaml:"session-ttl,omitempty"`
+	VerificationTTL time.Duration `yaml:"verification-ttl,omitempty"`
+	UnverifiedTTL   time.Duration `yaml:"unverified-ttl,omitempty"`
+	ResetTTL        time.Duration `yaml:"reset-ttl,omitempty"`
+	EncryptionKey   string        `yaml:"encryption-key,omitempty"`
+	Admins          []string      `yaml:"admins,omitempty"`
+}
+
+//TaxConfig holds the tax rules applied at checkout
+type TaxConfig struct {
+	Rules []*TaxRule `yaml:"rules"`
+}
+
+//TaxRule is the tax charged to buyers in a country, or in a region of it when Region is set. Rate is a percentage and
+//Inclusive means listing prices already include the tax
+type TaxRule struct {
+	Country   string `yaml:"country"`
+	Region    string `yaml:"region,omitempty"`
+	Name      string `yaml:"name"`
+	Rate      string `yaml:"rate"`
+	Inclusive bool   `yaml:"inclusive,omitempty"`
+}
+
+//StorageConfig configures where track files are stored. Root is required, MaxUploadSize is in bytes
+type StorageConfig struct {
+	Root          string `yaml:"root"`
+	MaxUploadSize int64  `yaml:"max-upload-size,omitempty"`
+}
+
+//DownloadConfig configures the signed links buyers download purchased tracks with
+type DownloadConfig struct {
+	SigningKey   string        `yaml:"signing-key"`
+	LinkTTL      time.Duration `yaml:"link-ttl,omitempty"`
+	MaxDownloads int64         `yaml:"max-downloads,omitempty"`
+}
+
+//FeedConfig configures how activity feeds are built. In the "read" mode feeds are queried from the follow graph on
+//every request, in the "write" mode events are copied into every follower's feed when they happen so heavy users
+//with many followings can read theirs cheaply. Milestones are the sale counts announced in feeds. Entries copied in
+//the "write" mode are deleted after Retention
+type FeedConfig struct {
+	Mode       string        `yaml:"mode,omitempty"`
+	Milestones []int64       `yaml:"milestones,omitempty"`
+	Retention  time.Duration `yaml:"retention,omitempty"`
+}
+
+//EmailConfig configures the SMTP server outbound email is delivered through and the worker delivering it from the
+//outbox. Emails are queued but not delivered while Host is empty. BaseURL is the address of the site links in
+//emails point to. Sent and failed emails are deleted from the outbox after Retention
+type EmailConfig struct {
+	Host         string        `yaml:"host"`
+	Port         int           `yaml:"port"`
+	Username     string        `yaml:"username,omitempty"`
+	Password     string        `yaml:"password,omitempty"`
+	From         string        `yaml:"from"`
+	BaseURL      string        `yaml:"base-url"`
+	PollInterval time.Duration `yaml:"poll-interval,omitempty"`
+	BatchSize    int           `yaml:"batch-size,omitempty"`
+	MaxAttempts  int64         `yaml:"max-attempts,omitempty"`
+	Retention    time.Duration `yaml:"retention,omitempty"`
+}
+
+//ThrottleConfig configures how failed logins are throttled per account and per IP address. Store is "memory" to keep
+//attempts in the instance, which is enough for a single one, or "shared" to keep them in the database when running
+//several
+type ThrottleConfig struct {
+	Store   string          `yaml:"store,omitempty"`
+	Account *ThrottlePolicy `yaml:"account,omitempty"`
+	IP      *ThrottlePolicy `yaml:"ip,omitempty"`
+}
+
+//ThrottlePolicy sets how attempts back off. The first Free failures cost nothing, after that every failure doubles
+//the delay from Base up to MaxDelay, and LockAfter failures lock attempts out for Lockout. Failures are forgotten
+//once none happened for Window
+type ThrottlePolicy struct {
+	Free      int64         `yaml:"free,omitempty"`
+	Base      time.Duration `yaml:"base,omitempty"`
+	MaxDelay  time.Duration `yaml:"max-delay,omitempty"`
+	LockAfter int64         `yaml:"lock-after,omitempty"`
+	Lockout   time.Duration `yaml:"lockout,omitempty"`
+	Window    time.Duration `yaml:"window,omitempty"`
+}
+
+//OAuthConfig sets how long the authorization codes, access tokens and refresh tokens issued to partner apps last
+type OAuthConfig struct {
+	CodeTTL    time.Duration `yaml:"code-ttl,omitempty"`
+	AccessTTL  time.Duration `yaml:"access-ttl,omitempty"`
+	RefreshTTL time.Duration `yaml:"refresh-ttl,omitempty"`
+}
+
 type Neo4jConfig struct {
 	URI         string       `yaml:"endpoint"`
 	Database    string       `yaml:"database,omitempty"`
@@ -0,0 +1,147 @@
+package discount
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/types"
+)
+
+var (
+	ErrInvalidType     = errors.New("discount type must be either percentage or fixed")
+	ErrInvalidValue    = errors.New("discount value must be a positive number")
+	ErrInvalidPeriod   = errors.New("discount must expire after it starts")
+	ErrSaleNeedsPeriod = errors.New("a sale must have an expiry date")
+	ErrNotStarted      = errors.New("discount is not active yet")
+	ErrExpired         = errors.New("discount has expired")
+	ErrUsedUp          = errors.New("discount has reached its usage limit")
+	ErrBuyerLimit      = errors.New("buyer has reached the usage limit for this discount")
+	ErrWrongListing    = errors.New("discount does not apply to this listing")
+)
+
+const hundred = "100"
+
+//Validate checks that a discount created by a seller is well formed
+func Validate(d *types.Discount) error {
+	if d.Type != types.PercentageDiscount && d.Type != types.FixedDiscount {
+		return ErrInvalidType
+	}
+
+	value, err := strconv.ParseFloat(d.Value, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || value <= 0 {
+		return ErrInvalidValue
+	}
+
+	if d.Type == types.PercentageDiscount && value > 100 {
+		return ErrInvalidValue
+	}
+
+	if d.MaxUses < 0 || d.PerBuyerLimit < 0 {
+		return ErrInvalidValue
+	}
+
+	if d.IsSale() && d.Expires == nil {
+		return ErrSaleNeedsPeriod
+	}
+
+	if d.Starts != nil && d.Expires != nil && !d.Expires.After(*d.Starts) {
+		return ErrInvalidPeriod
+	}
+
+	return nil
+}
+
+//Active checks that the discount can be redeemed on the given listing at the given time by a buyer who has already
+//used it buyerUses times
+func Active(d *types.Discount, listingID string, buyerUses int64, now time.Time) error {
+	if d.ListingID != "" && d.ListingID != listingID {
+		return ErrWrongListing
+	}
+
+	if d.Starts != nil && now.Before(*d.Starts) {
+		return ErrNotStarted
+	}
+
+	if d.Expires != nil && !now.Before(*d.Expires) {
+		return ErrExpired
+	}
+
+	if d.MaxUses > 0 && d.Uses >= d.MaxUses {
+		return ErrUsedUp
+	}
+
+	if d.PerBuyerLimit > 0 && buyerUses >= d.PerBuyerLimit {
+		return ErrBuyerLimit
+	}
+
+	return nil
+}
+
+//Apply returns the amount taken off price by the discount and the resulting final price. The discount is rounded to
+//the currency's precision and never exceeds the price itself
+func Apply(d *types.Discount, price currency.Amount) (currency.Amount, currency.Amount, error) {
+	var off currency.Amount
+	var err error
+
+	switch d.Type {
+	case types.PercentageDiscount:
+		off, err = price.Mul(d.Value)
+		if err != nil {
+			return currency.Amount{}, currency.Amount{}, err
+		}
+
+		off, err = off.Div(hundred)
+	case types.FixedDiscount:
+		off, err = currency.NewAmount(d.Value, price.CurrencyCode())
+	default:
+		err = ErrInvalidType
+	}
+
+	if err != nil {
+		return currency.Amount{}, currency.Amount{}, err
+	}
+
+	off = off.Round()
+	if cmp, _ := off.Cmp(price); cmp > 0 {
+		off = price
+	}
+
+	final, err := price.Sub(off)
+	if err != nil {
+		return currency.Amount{}, currency.Amount{}, err
+	}
+
+	return off, final, nil
+}
+
+//Best picks the sale giving the largest discount on price out of the ones active at the given time. It returns nil
+//if none of them apply
+func Best(sales []*types.Discount, listingID string, price currency.Amount, now time.Time) *types.Discount {
+	var best *types.Discount
+	var bestOff currency.Amount
+
+	for _, sale := range sales {
+		if Active(sale, listingID, 0, now) != nil {
+			continue
+		}
+
+		off, _, err := Apply(sale, price)
+		if err != nil {
+			continue
+		}
+
+		if best == nil {
+			best, bestOff = sale, off
+			continue
+		}
+
+		if cmp, _ := off.Cmp(bestOff); cmp > 0 {
+			best, bestOff = sale, off
+		}
+	}
+
+	return best
+}
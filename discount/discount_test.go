@@ -0,0 +1,64 @@
+package discount_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/discount"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiscount(t *testing.T) {
+	convey.Convey("Discount codes and sales...", t, func() {
+		now := time.Now()
+		yesterday := now.Add(-24 * time.Hour)
+		tomorrow := now.Add(24 * time.Hour)
+		price, _ := currency.NewAmount("25", "USD")
+
+		convey.Convey("Validation should reject malformed discounts\n", func() {
+			convey.So(discount.Validate(&types.Discount{Code: "A", Type: "bogus", Value: "10"}), convey.ShouldEqual, discount.ErrInvalidType)
+			convey.So(discount.Validate(&types.Discount{Code: "A", Type: types.PercentageDiscount, Value: "-5"}), convey.ShouldEqual, discount.ErrInvalidValue)
+			convey.So(discount.Validate(&types.Discount{Code: "A", Type: types.PercentageDiscount, Value: "101"}), convey.ShouldEqual, discount.ErrInvalidValue)
+			convey.So(discount.Validate(&types.Discount{Type: types.FixedDiscount, Value: "5"}), convey.ShouldEqual, discount.ErrSaleNeedsPeriod)
+			convey.So(discount.Validate(&types.Discount{Code: "A", Type: types.FixedDiscount, Value: "5", Starts: &tomorrow, Expires: &yesterday}), convey.ShouldEqual, discount.ErrInvalidPeriod)
+			convey.So(discount.Validate(&types.Discount{Code: "A", Type: types.FixedDiscount, Value: "5"}), convey.ShouldBeNil)
+		})
+
+		convey.Convey("A percentage discount should be rounded to the currency precision\n", func() {
+			off, final, err := discount.Apply(&types.Discount{Type: types.PercentageDiscount, Value: "33"}, price)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(off.String(), convey.ShouldEqual, "8.25 USD")
+			convey.So(final.String(), convey.ShouldEqual, "16.75 USD")
+		})
+
+		convey.Convey("A fixed discount should never take the price below zero\n", func() {
+			off, final, err := discount.Apply(&types.Discount{Type: types.FixedDiscount, Value: "30"}, price)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(off.String(), convey.ShouldEqual, "25 USD")
+			convey.So(final.IsZero(), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Usage limits, listing and period should be enforced\n", func() {
+			d := &types.Discount{Code: "A", ListingID: "abc", Type: types.FixedDiscount, Value: "5", MaxUses: 2, PerBuyerLimit: 1, Starts: &yesterday, Expires: &tomorrow}
+			convey.So(discount.Active(d, "abc", 0, now), convey.ShouldBeNil)
+			convey.So(discount.Active(d, "xyz", 0, now), convey.ShouldEqual, discount.ErrWrongListing)
+			convey.So(discount.Active(d, "abc", 1, now), convey.ShouldEqual, discount.ErrBuyerLimit)
+			convey.So(discount.Active(d, "abc", 0, tomorrow), convey.ShouldEqual, discount.ErrExpired)
+			convey.So(discount.Active(d, "abc", 0, yesterday.Add(-time.Hour)), convey.ShouldEqual, discount.ErrNotStarted)
+
+			d.Uses = 2
+			convey.So(discount.Active(d, "abc", 0, now), convey.ShouldEqual, discount.ErrUsedUp)
+		})
+
+		convey.Convey("The sale with the largest discount should be picked\n", func() {
+			small := &types.Discount{ID: "small", Type: types.PercentageDiscount, Value: "10", Expires: &tomorrow}
+			large := &types.Discount{ID: "large", Type: types.FixedDiscount, Value: "5", Expires: &tomorrow}
+			expired := &types.Discount{ID: "expired", Type: types.PercentageDiscount, Value: "90", Expires: &yesterday}
+
+			convey.So(discount.Best([]*types.Discount{small, large, expired}, "abc", price, now), convey.ShouldEqual, large)
+			convey.So(discount.Best([]*types.Discount{expired}, "abc", price, now), convey.ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,55 @@
+package download
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("download link signature is invalid")
+	ErrExpired          = errors.New("download link has expired")
+)
+
+//Signer signs and verifies download links. A signature binds a link to a buyer, a transaction, the grant ID the link
+//was issued with and an expiry time so none of them can be changed without invalidating the link
+type Signer struct {
+	key []byte
+}
+
+//NewSigner creates a signer using the given HMAC key
+func NewSigner(key []byte) (*Signer, error) {
+	if len(key) == 0 {
+		return nil, errors.New("Download signing key cannot be empty")
+	}
+
+	return &Signer{key: key}, nil
+}
+
+//Sign returns the hex encoded HMAC-SHA256 signature of the link parameters
+func (s *Signer) Sign(buyer, txID, grant string, expires time.Time) string {
+	return hex.EncodeToString(s.sum(buyer, txID, grant, expires))
+}
+
+//Verify checks the signature of the link parameters and that the link has not expired at the given time
+func (s *Signer) Verify(buyer, txID, grant string, expires time.Time, signature string, now time.Time) error {
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(s.sum(buyer, txID, grant, expires), actual) {
+		return ErrInvalidSignature
+	}
+
+	if !now.Before(expires) {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+func (s *Signer) sum(buyer, txID, grant string, expires time.Time) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(buyer + "\n" + txID + "\n" + grant + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return mac.Sum(nil)
+}
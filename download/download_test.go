@@ -0,0 +1,38 @@
+package download_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/download"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSigner(t *testing.T) {
+	convey.Convey("Signed download links...", t, func() {
+		signer, err := download.NewSigner([]byte("secret"))
+		convey.So(err, convey.ShouldBeNil)
+
+		now := time.Now()
+		expires := now.Add(time.Minute)
+		signature := signer.Sign("buyer", "tx123", "grant1", expires)
+
+		convey.Convey("A valid link should verify until it expires\n", func() {
+			convey.So(signer.Verify("buyer", "tx123", "grant1", expires, signature, now), convey.ShouldBeNil)
+			convey.So(signer.Verify("buyer", "tx123", "grant1", expires, signature, expires), convey.ShouldEqual, download.ErrExpired)
+		})
+
+		convey.Convey("Changing any bound parameter should invalidate the link\n", func() {
+			convey.So(signer.Verify("other", "tx123", "grant1", expires, signature, now), convey.ShouldEqual, download.ErrInvalidSignature)
+			convey.So(signer.Verify("buyer", "tx456", "grant1", expires, signature, now), convey.ShouldEqual, download.ErrInvalidSignature)
+			convey.So(signer.Verify("buyer", "tx123", "grant2", expires, signature, now), convey.ShouldEqual, download.ErrInvalidSignature)
+			convey.So(signer.Verify("buyer", "tx123", "grant1", expires.Add(time.Hour), signature, now), convey.ShouldEqual, download.ErrInvalidSignature)
+			convey.So(signer.Verify("buyer", "tx123", "grant1", expires, "not-hex", now), convey.ShouldEqual, download.ErrInvalidSignature)
+		})
+
+		convey.Convey("A link signed with another key should be rejected\n", func() {
+			other, _ := download.NewSigner([]byte("other"))
+			convey.So(other.Verify("buyer", "tx123", "grant1", expires, signature, now), convey.ShouldEqual, download.ErrInvalidSignature)
+		})
+	})
+}
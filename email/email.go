@@ -0,0 +1,111 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+)
+
+//ErrUnknownKind is returned when rendering an email of a kind there is no template for
+var ErrUnknownKind = errors.New("unknown email kind")
+
+//Kind names the template an email is rendered from. There is no kind for offer updates: listings are only bought at
+//their price, there are no offers to update buyers on
+type Kind string
+
+const (
+	Verification  Kind = "verification"
+	PasswordReset Kind = "password_reset"
+	Receipt       Kind = "receipt"
+	Sale          Kind = "sale"
+	Lockout       Kind = "lockout"
+)
+
+//categories decides which preference controls whether an email of a kind is sent
+var categories = map[Kind]types.EmailCategory{
+	Verification:  types.EmailAccount,
+	PasswordReset: types.EmailAccount,
+	Receipt:       types.EmailReceipts,
+	Sale:          types.EmailSales,
+	Lockout:       types.EmailAccount,
+}
+
+//LinkData is rendered by the emails asking the user to follow a link, i.e. Verification, PasswordReset and Lockout
+type LinkData struct {
+	Username string
+	Link     string
+	Expires  time.Time
+}
+
+//SaleData is rendered by Receipt, sent to the buyer, and Sale, sent to the seller. Username is the recipient
+type SaleData struct {
+	Username      string
+	Track         string
+	Seller        string
+	Buyer         string
+	TransactionID string
+	Date          time.Time
+	Discount      string
+	TaxName       string
+	Tax           string
+	Total         string
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+type templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var kinds = map[Kind]*templates{}
+
+func init() {
+	for kind := range categories {
+		kinds[kind] = &templates{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+string(kind)+".txt")),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+string(kind)+".html")),
+		}
+	}
+}
+
+//New renders an email of the given kind to the user with the given username. The result is ready to be queued in
+//the outbox
+func New(kind Kind, to string, data interface{}) (*types.Email, error) {
+	t, ok := kinds[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+
+	subject := &bytes.Buffer{}
+	if err := t.text.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	text := &bytes.Buffer{}
+	if err := t.text.Execute(text, data); err != nil {
+		return nil, err
+	}
+
+	html := &bytes.Buffer{}
+	if err := t.html.ExecuteTemplate(html, "layout", data); err != nil {
+		return nil, err
+	}
+
+	return &types.Email{
+		ID:       types.GenerateID(),
+		To:       to,
+		Category: categories[kind],
+		Subject:  strings.TrimSpace(subject.String()),
+		Text:     text.String(),
+		HTML:     html.String(),
+		Created:  time.Now(),
+	}, nil
+}
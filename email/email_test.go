@@ -0,0 +1,153 @@
+package email_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestNew(t *testing.T) {
+	convey.Convey("Rendering emails...", t, func() {
+		expires := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		convey.Convey("Every kind should render a subject, text and HTML body\n", func() {
+			e, err := email.New(email.Verification, "alice", &email.LinkData{Username: "alice", Link: "https://example.com/verify?token=abc", Expires: expires})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(e.Subject, convey.ShouldEqual, "Verify your email address")
+			convey.So(e.Category, convey.ShouldEqual, types.EmailAccount)
+			convey.So(e.Text, convey.ShouldStartWith, "Hi alice,")
+			convey.So(e.Text, convey.ShouldContainSubstring, "https://example.com/verify?token=abc")
+			convey.So(e.HTML, convey.ShouldContainSubstring, `href="https://example.com/verify?token=abc"`)
+			convey.So(e.HTML, convey.ShouldContainSubstring, "<title>Verify your email address</title>")
+
+			e, err = email.New(email.Receipt, "bob", &email.SaleData{Username: "bob", Track: "Song", Seller: "alice", TransactionID: "tx1", Date: expires, Total: "10.00 USD"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(e.Subject, convey.ShouldEqual, "Your receipt for Song")
+			convey.So(e.Category, convey.ShouldEqual, types.EmailReceipts)
+			convey.So(e.Text, convey.ShouldContainSubstring, "Total: 10.00 USD")
+			convey.So(e.Text, convey.ShouldNotContainSubstring, "Discount")
+		})
+
+		convey.Convey("User supplied values should be escaped in the HTML body\n", func() {
+			e, err := email.New(email.Sale, "alice", &email.SaleData{Username: "alice", Track: "<script>", Buyer: "bob", Date: expires})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(e.HTML, convey.ShouldNotContainSubstring, "<script>")
+			convey.So(e.HTML, convey.ShouldContainSubstring, "&lt;script&gt;")
+		})
+
+		convey.Convey("Unknown kinds should be rejected\n", func() {
+			_, err := email.New(email.Kind("other"), "alice", nil)
+			convey.So(err, convey.ShouldEqual, email.ErrUnknownKind)
+		})
+	})
+}
+
+func TestSMTPSender(t *testing.T) {
+	convey.Convey("Sending through an SMTP server...", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		convey.So(err, convey.ShouldBeNil)
+		defer listener.Close()
+
+		received := make(chan string, 1)
+		go smtpSink(listener, received)
+
+		addr := listener.Addr().(*net.TCPAddr)
+		sender, err := email.NewSMTPSender(&config.EmailConfig{Host: "127.0.0.1", Port: addr.Port, From: "Music Match <no-reply@example.com>"})
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("The email should arrive with both bodies\n", func() {
+			e, err := email.New(email.PasswordReset, "alice", &email.LinkData{Username: "alice", Link: "https://example.com/reset", Expires: time.Now()})
+			convey.So(err, convey.ShouldBeNil)
+			e.Address = "alice@example.com"
+
+			convey.So(sender.Send(e), convey.ShouldBeNil)
+
+			msg, err := mail.ReadMessage(strings.NewReader(<-received))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(msg.Header.Get("To"), convey.ShouldEqual, `"alice" <alice@example.com>`)
+			convey.So(msg.Header.Get("Subject"), convey.ShouldEqual, "Reset your password")
+
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mediaType, convey.ShouldEqual, "multipart/alternative")
+
+			parts := multipart.NewReader(msg.Body, params["boundary"])
+			var bodies []string
+			for {
+				part, err := parts.NextPart()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				convey.So(err, convey.ShouldBeNil)
+
+				body, _ := io.ReadAll(part)
+				bodies = append(bodies, string(body))
+			}
+
+			convey.So(bodies, convey.ShouldHaveLength, 2)
+			convey.So(bodies[0], convey.ShouldContainSubstring, "https://example.com/reset")
+			convey.So(bodies[1], convey.ShouldContainSubstring, `href="https://example.com/reset"`)
+		})
+
+		convey.Convey("Emails without an address should not be sent\n", func() {
+			convey.So(sender.Send(&types.Email{ID: "1", To: "alice"}), convey.ShouldEqual, email.ErrNoAddress)
+		})
+	})
+}
+
+//smtpSink speaks just enough SMTP to accept a single message and hand over its data
+func smtpSink(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(code int, text string) {
+		_, _ = conn.Write([]byte(strconv.Itoa(code) + " " + text + "\r\n"))
+	}
+
+	reply(220, "sink ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+		case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "go ahead")
+			data := &strings.Builder{}
+			for {
+				line, err = reader.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			received <- data.String()
+			reply(250, "queued")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
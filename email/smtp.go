@@ -0,0 +1,119 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/types"
+)
+
+//ErrNoAddress is returned when sending an email whose recipient has no address
+var ErrNoAddress = errors.New("email has no recipient address")
+
+//Sender delivers rendered emails
+type Sender interface {
+	Send(e *types.Email) error
+}
+
+//SMTPSender delivers emails through an SMTP server. The connection is upgraded with STARTTLS whenever the server
+//offers it
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from *mail.Address
+	host string
+}
+
+func NewSMTPSender(conf *config.EmailConfig) (*SMTPSender, error) {
+	if conf == nil || conf.Host == "" {
+		return nil, errors.New("SMTP host cannot be empty")
+	}
+
+	from, err := mail.ParseAddress(conf.From)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid from address %q: %w", conf.From, err)
+	}
+
+	port := conf.Port
+	if port == 0 {
+		port = 25
+	}
+
+	sender := &SMTPSender{
+		addr: net.JoinHostPort(conf.Host, strconv.Itoa(port)),
+		from: from,
+		host: conf.Host,
+	}
+
+	if conf.Username != "" {
+		sender.auth = smtp.PlainAuth("", conf.Username, conf.Password, conf.Host)
+	}
+
+	return sender, nil
+}
+
+func (s *SMTPSender) Send(e *types.Email) error {
+	if e.Address == "" {
+		return ErrNoAddress
+	}
+
+	msg, err := buildMessage(s.from, e, s.host, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(s.addr, s.auth, s.from.Address, []string{e.Address}, msg)
+}
+
+//buildMessage builds a multipart/alternative message carrying both the text and the HTML body
+func buildMessage(from *mail.Address, e *types.Email, host string, date time.Time) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	body := multipart.NewWriter(buf)
+
+	to := &mail.Address{Name: e.To, Address: e.Address}
+	fmt.Fprintf(buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@%s>\r\n", e.ID, host)
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", body.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", e.Text},
+		{"text/html; charset=utf-8", e.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err = qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err = qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
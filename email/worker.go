@@ -0,0 +1,172 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/tracing"
+	"github.com/danny-m08/music-match/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 20
+	defaultMaxAttempts  = 8
+	//lease is how long a claimed email is hidden from other workers while it is being sent
+	lease      = time.Minute
+	retryDelay = 30 * time.Second
+	maxDelay   = 2 * time.Hour
+)
+
+//Outbox stores the emails waiting to be delivered
+type Outbox interface {
+	//ClaimEmails returns up to limit emails due at now and hides them from other claims until lease
+	ClaimEmails(now, lease time.Time, limit int) ([]*types.Email, error)
+	//MarkEmailSent removes the email from the queue
+	MarkEmailSent(id string, sent time.Time) error
+	//MarkEmailFailed records a failed attempt. The email is retried at next, or given up on if next is nil
+	MarkEmailFailed(id string, attempts int64, next *time.Time, reason string) error
+}
+
+//Worker delivers the emails in the outbox, retrying failed deliveries with exponential backoff
+type Worker struct {
+	outbox       Outbox
+	sender       Sender
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int64
+	//lastPoll is when Run last polled the outbox, in Unix nanoseconds
+	lastPoll int64
+}
+
+func NewWorker(conf *config.EmailConfig, outbox Outbox, sender Sender) *Worker {
+	worker := &Worker{
+		outbox:       outbox,
+		sender:       sender,
+		pollInterval: conf.PollInterval,
+		batchSize:    conf.BatchSize,
+		maxAttempts:  conf.MaxAttempts,
+	}
+
+	if worker.pollInterval == 0 {
+		worker.pollInterval = defaultPollInterval
+	}
+	if worker.batchSize == 0 {
+		worker.batchSize = defaultBatchSize
+	}
+	if worker.maxAttempts == 0 {
+		worker.maxAttempts = defaultMaxAttempts
+	}
+
+	return worker
+}
+
+//Run delivers emails until the context is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		atomic.StoreInt64(&w.lastPoll, time.Now().UnixNano())
+		for {
+			n, err := w.deliver(ctx)
+			if err != nil {
+				logging.FromContext(ctx).Error("Unable to deliver emails", "error", err)
+			}
+			if err != nil || n < w.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//Check returns an error unless Run polled the outbox within the last three poll intervals, which it does not do when it
+//is stuck or was never started
+func (w *Worker) Check() error {
+	last := atomic.LoadInt64(&w.lastPoll)
+	if last == 0 {
+		return errors.New("not running")
+	} else if since := time.Since(time.Unix(0, last)); since > 3*w.pollInterval {
+		return fmt.Errorf("last polled %s ago", since.Round(time.Second))
+	}
+
+	return nil
+}
+
+//deliver runs Deliver, tracing the batches that had emails to send or failed. Polls finding nothing are left out, as
+//they are most of them
+func (w *Worker) deliver(ctx context.Context) (int, error) {
+	start := time.Now()
+	n, err := w.Deliver(start)
+	if n > 0 || err != nil {
+		_, span := tracing.Start(ctx, "email delivery", trace.WithTimestamp(start),
+			trace.WithAttributes(attribute.Int("email.claimed", n)))
+		tracing.End(span, err)
+	}
+
+	return n, err
+}
+
+//Deliver sends one batch of the emails due at now and returns how many were claimed
+func (w *Worker) Deliver(now time.Time) (int, error) {
+	emails, err := w.outbox.ClaimEmails(now, now.Add(lease), w.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range emails {
+		err = w.sender.Send(e)
+		if err == nil {
+			err = w.outbox.MarkEmailSent(e.ID, time.Now())
+			if err != nil {
+				logging.Error("Unable to mark email sent", "email_id", e.ID, "error", err)
+			}
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		var next *time.Time
+		if attempts < w.maxAttempts {
+			retry := now.Add(Backoff(attempts))
+			next = &retry
+			logging.Warn("Unable to send email", "email_id", e.ID, "attempts", attempts, "max_attempts", w.maxAttempts,
+				"error", err)
+		} else {
+			logging.Error("Giving up on email", "email_id", e.ID, "attempts", attempts, "error", err)
+		}
+
+		err = w.outbox.MarkEmailFailed(e.ID, attempts, next, err.Error())
+		if err != nil {
+			logging.Error("Unable to record failed attempt", "email_id", e.ID, "error", err)
+		}
+	}
+
+	return len(emails), nil
+}
+
+//Backoff is how long to wait before retrying an email that failed the given number of times
+func Backoff(attempts int64) time.Duration {
+	delay := retryDelay
+	for i := int64(1); i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,97 @@
+package email_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type fakeOutbox struct {
+	pending []*types.Email
+	sent    []string
+	failed  map[string]*time.Time
+}
+
+func (o *fakeOutbox) ClaimEmails(now, lease time.Time, limit int) ([]*types.Email, error) {
+	claimed := o.pending
+	o.pending = nil
+	return claimed, nil
+}
+
+func (o *fakeOutbox) MarkEmailSent(id string, sent time.Time) error {
+	o.sent = append(o.sent, id)
+	return nil
+}
+
+func (o *fakeOutbox) MarkEmailFailed(id string, attempts int64, next *time.Time, reason string) error {
+	o.failed[id] = next
+	return nil
+}
+
+type fakeSender struct {
+	fail map[string]bool
+}
+
+func (s *fakeSender) Send(e *types.Email) error {
+	if s.fail[e.ID] {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestWorker(t *testing.T) {
+	convey.Convey("Delivering the outbox...", t, func() {
+		now := time.Now()
+		outbox := &fakeOutbox{failed: map[string]*time.Time{}}
+		sender := &fakeSender{fail: map[string]bool{"retry": true, "last": true}}
+		worker := email.NewWorker(&config.EmailConfig{MaxAttempts: 3}, outbox, sender)
+
+		outbox.pending = []*types.Email{
+			{ID: "ok", Attempts: 0},
+			{ID: "retry", Attempts: 1},
+			{ID: "last", Attempts: 2},
+		}
+
+		n, err := worker.Deliver(now)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(n, convey.ShouldEqual, 3)
+
+		convey.Convey("Sent emails should be marked sent\n", func() {
+			convey.So(outbox.sent, convey.ShouldResemble, []string{"ok"})
+		})
+
+		convey.Convey("Failed emails should be retried with backoff until they run out of attempts\n", func() {
+			convey.So(outbox.failed["retry"], convey.ShouldNotBeNil)
+			convey.So(*outbox.failed["retry"], convey.ShouldEqual, now.Add(email.Backoff(2)))
+			convey.So(outbox.failed, convey.ShouldContainKey, "last")
+			convey.So(outbox.failed["last"], convey.ShouldBeNil)
+		})
+
+		convey.Convey("Workers should only pass their check while running\n", func() {
+			convey.So(worker.Check(), convey.ShouldNotBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				worker.Run(ctx)
+				close(done)
+			}()
+			cancel()
+			<-done
+
+			convey.So(worker.Check(), convey.ShouldBeNil)
+		})
+	})
+
+	convey.Convey("The backoff should double up to its cap\n", t, func() {
+		convey.So(email.Backoff(2), convey.ShouldEqual, 2*email.Backoff(1))
+		convey.So(email.Backoff(3), convey.ShouldEqual, 2*email.Backoff(2))
+		convey.So(email.Backoff(100), convey.ShouldEqual, email.Backoff(50))
+	})
+}
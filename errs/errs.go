@@ -0,0 +1,189 @@
+package errs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/danny-m08/music-match/logging"
+)
+
+//Code identifies a kind of error to clients. Codes are part of the API, once published they must not change
+type Code string
+
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeMalformedBody      Code = "malformed_body"
+	CodeBodyTooLarge       Code = "body_too_large"
+	CodeValidation         Code = "validation_failed"
+	CodeSelfAction         Code = "self_action"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeForbidden          Code = "forbidden"
+	CodePermissionDenied   Code = "permission_denied"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeAccountSuspended   Code = "account_suspended"
+	CodeEmailNotVerified   Code = "email_not_verified"
+	CodeBlocked            Code = "blocked"
+	CodeNotFound           Code = "not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeConflict           Code = "conflict"
+	CodeAlreadyExists      Code = "already_exists"
+	CodeUsernameTaken      Code = "username_taken"
+	CodeEmailTaken         Code = "email_taken"
+	CodeAlreadySold        Code = "already_sold"
+	CodeAlreadyVerified    Code = "already_verified"
+	CodeLimitReached       Code = "limit_reached"
+	CodeRateLimited        Code = "rate_limited"
+	CodeInternal           Code = "internal_error"
+	CodeUnavailable        Code = "unavailable"
+)
+
+var statuses = map[Code]int{
+	CodeBadRequest:         http.StatusBadRequest,
+	CodeMalformedBody:      http.StatusBadRequest,
+	CodeBodyTooLarge:       http.StatusRequestEntityTooLarge,
+	CodeValidation:         http.StatusBadRequest,
+	CodeSelfAction:         http.StatusBadRequest,
+	CodeUnauthorized:       http.StatusUnauthorized,
+	CodeInvalidCredentials: http.StatusUnauthorized,
+	CodeForbidden:          http.StatusForbidden,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeInsufficientScope:  http.StatusForbidden,
+	CodeAccountSuspended:   http.StatusForbidden,
+	CodeEmailNotVerified:   http.StatusForbidden,
+	CodeBlocked:            http.StatusForbidden,
+	CodeNotFound:           http.StatusNotFound,
+	CodeMethodNotAllowed:   http.StatusMethodNotAllowed,
+	CodeConflict:           http.StatusConflict,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodeUsernameTaken:      http.StatusConflict,
+	CodeEmailTaken:         http.StatusConflict,
+	CodeAlreadySold:        http.StatusConflict,
+	CodeAlreadyVerified:    http.StatusConflict,
+	CodeLimitReached:       http.StatusConflict,
+	CodeRateLimited:        http.StatusTooManyRequests,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+}
+
+//Status returns the HTTP status code errors with the code are answered with
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+//ErrInternal is answered for anything that went wrong on our side. Its message is all clients get to see, the cause
+//has to be logged where it happened
+var ErrInternal = New(CodeInternal, "Unable to process request")
+
+//Error is an error clients can be told about. Message is shown to them, so it must not carry internal details; the
+//cause, if any, is kept for errors.Is and errors.As only. Two errors are considered the same by errors.Is when they
+//have the same code, so a handler's "Listing not found" is the storage layer's ErrNotFound
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	cause   error
+}
+
+//FieldError tells which field of a request failed validation and why
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+//Wrap returns an error with the code and message keeping cause to be unwrapped
+func Wrap(code Code, message string, cause error) *Error {
+	return &Error{Code: code, Message: message, cause: cause}
+}
+
+//WithCode returns err if it is an *Error already, otherwise an error with the code whose message is err's
+func WithCode(code Code, err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	return &Error{Code: code, Message: err.Error(), cause: err}
+}
+
+//Invalid returns a validation error for a single field
+func Invalid(field, message string) *Error {
+	return &Error{Code: CodeValidation, Message: message, Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+//Validation returns a validation error for several fields at once
+func Validation(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidation, Message: "Request failed validation", Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil && e.cause.Error() != e.Message {
+		return e.Message + ": " + e.cause.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+//Problem is the RFC 7807 problem+json body errors are answered with. Type is always about:blank, clients tell errors
+//apart by Code instead. RequestID is the ID of the request in the server logs
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+//requestIDHeader is the response header router.RequestID writes the ID of the request to
+const requestIDHeader = "X-Request-ID"
+
+//Write answers the request with err as a problem. Errors that are not an *Error are answered with ErrInternal
+func Write(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		logging.Error("Unexpected error", "error", err)
+		e = ErrInternal
+	}
+
+	status := e.Code.Status()
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Code:      e.Code,
+		Errors:    e.Fields,
+		RequestID: w.Header().Get(requestIDHeader),
+	}
+
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/problem+json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	err = json.NewEncoder(w).Encode(problem)
+	if err != nil {
+		logging.Error("Unable to write problem", "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package errs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func write(err error) (*httptest.ResponseRecorder, *errs.Problem) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("X-Request-ID", "req-1")
+	errs.Write(recorder, err)
+
+	problem := &errs.Problem{}
+	convey.So(json.Unmarshal(recorder.Body.Bytes(), problem), convey.ShouldBeNil)
+	return recorder, problem
+}
+
+func TestWrite(t *testing.T) {
+	convey.Convey("Writing errors as problems...", t, func() {
+		convey.Convey("Errors should be answered with their code, status and the request ID\n", func() {
+			recorder, problem := write(errs.New(errs.CodeUsernameTaken, "Username already taken"))
+			convey.So(recorder.Code, convey.ShouldEqual, http.StatusConflict)
+			convey.So(recorder.Header().Get("Content-Type"), convey.ShouldEqual, "application/problem+json")
+			convey.So(problem, convey.ShouldResemble, &errs.Problem{
+				Type:      "about:blank",
+				Title:     "Conflict",
+				Status:    http.StatusConflict,
+				Detail:    "Username already taken",
+				Code:      errs.CodeUsernameTaken,
+				RequestID: "req-1",
+			})
+		})
+
+		convey.Convey("Validation errors should list the fields\n", func() {
+			recorder, problem := write(errs.Invalid("limit", "Limit must be between 1 and 100"))
+			convey.So(recorder.Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(problem.Code, convey.ShouldEqual, errs.CodeValidation)
+			convey.So(problem.Errors, convey.ShouldResemble, []errs.FieldError{
+				{Field: "limit", Message: "Limit must be between 1 and 100"},
+			})
+		})
+
+		convey.Convey("Other errors should be answered as internal without their message\n", func() {
+			recorder, problem := write(errors.New("connection refused by 10.0.0.7"))
+			convey.So(recorder.Code, convey.ShouldEqual, http.StatusInternalServerError)
+			convey.So(problem.Code, convey.ShouldEqual, errs.CodeInternal)
+			convey.So(problem.Detail, convey.ShouldEqual, errs.ErrInternal.Message)
+		})
+	})
+}
+
+func TestIs(t *testing.T) {
+	convey.Convey("Comparing errors...", t, func() {
+		notFound := errs.New(errs.CodeNotFound, "not found")
+
+		convey.Convey("Errors with the same code should match, even wrapped\n", func() {
+			err := fmt.Errorf("loading listing: %w", errs.New(errs.CodeNotFound, "Listing not found"))
+			convey.So(errors.Is(err, notFound), convey.ShouldBeTrue)
+			convey.So(errors.Is(err, errs.New(errs.CodeConflict, "not found")), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Wrapped causes should be kept\n", func() {
+			cause := errors.New("constraint violated")
+			err := errs.Wrap(errs.CodeEmailTaken, "Email address already in use", cause)
+			convey.So(errors.Is(err, cause), convey.ShouldBeTrue)
+			convey.So(errs.WithCode(errs.CodeBadRequest, err), convey.ShouldEqual, err)
+			convey.So(errs.WithCode(errs.CodeBadRequest, cause).Code, convey.ShouldEqual, errs.CodeBadRequest)
+		})
+	})
+}
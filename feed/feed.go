@@ -0,0 +1,131 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	//ModeRead builds feeds from the follow graph when they are read
+	ModeRead = "read"
+	//ModeWrite copies events into every follower's feed when they are published
+	ModeWrite = "write"
+)
+
+var defaultMilestones = []int64{1, 10, 25, 50, 100, 250, 500, 1000}
+
+//defaultRetention is how long fanned out feed entries are kept
+const defaultRetention = 90 * 24 * time.Hour
+
+//Cursor is the position in a feed a page starts after
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+//Feed publishes events to and reads them from users' activity feeds
+type Feed interface {
+	//Publish records an event by the item's actor
+	Publish(ctx context.Context, item *types.FeedItem) error
+	//Read returns up to limit items of the user's feed, newest first, starting after the cursor
+	Read(ctx context.Context, user *types.User, after *Cursor, limit int) ([]*types.FeedItem, error)
+	//Prune forgets the events that are too old to be kept as of now
+	Prune(ctx context.Context, now time.Time) error
+	//Milestones returns the sale counts announced in feeds
+	Milestones() []int64
+}
+
+//New creates the feed for the configured mode
+func New(conf *config.FeedConfig, client *neo4j.Client) (Feed, error) {
+	milestones := conf.Milestones
+	if len(milestones) == 0 {
+		milestones = defaultMilestones
+	}
+
+	retention := conf.Retention
+	if retention == 0 {
+		retention = defaultRetention
+	}
+
+	switch conf.Mode {
+	case "", ModeRead:
+		return &readFeed{client: client, milestones: milestones}, nil
+	case ModeWrite:
+		return &writeFeed{client: client, milestones: milestones, retention: retention}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown feed mode %q", conf.Mode)
+}
+
+//IsMilestone reports whether a seller reaching the given number of sales should be announced
+func IsMilestone(f Feed, sales int64) bool {
+	for _, m := range f.Milestones() {
+		if m == sales {
+			return true
+		}
+	}
+
+	return false
+}
+
+//start converts a cursor into the position the first item of a page has to come before
+func start(after *Cursor) (time.Time, string) {
+	if after == nil {
+		return time.Now().Add(24 * time.Hour), ""
+	}
+
+	return after.Time, after.ID
+}
+
+//readFeed queries the follow graph on every read, so publishing and pruning are no-ops
+type readFeed struct {
+	client     *neo4j.Client
+	milestones []int64
+}
+
+func (f *readFeed) Publish(ctx context.Context, item *types.FeedItem) error {
+	return nil
+}
+
+func (f *readFeed) Read(ctx context.Context, user *types.User, after *Cursor, limit int) ([]*types.FeedItem, error) {
+	before, id := start(after)
+	return f.client.WithContext(ctx).GetFeed(user, before, id, limit, f.milestones)
+}
+
+func (f *readFeed) Prune(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+func (f *readFeed) Milestones() []int64 {
+	return f.milestones
+}
+
+//writeFeed fans every event out to the actor's followers when it is published. Followings made after an event was
+//published do not see it, and entries older than the retention are deleted
+type writeFeed struct {
+	client     *neo4j.Client
+	milestones []int64
+	retention  time.Duration
+}
+
+func (f *writeFeed) Publish(ctx context.Context, item *types.FeedItem) error {
+	return f.client.WithContext(ctx).FanOut(item)
+}
+
+func (f *writeFeed) Read(ctx context.Context, user *types.User, after *Cursor, limit int) ([]*types.FeedItem, error) {
+	before, id := start(after)
+	return f.client.WithContext(ctx).GetFeedEntries(user, before, id, limit)
+}
+
+func (f *writeFeed) Prune(ctx context.Context, now time.Time) error {
+	return f.client.WithContext(ctx).PruneFeedEntries(now.Add(-f.retention))
+}
+
+func (f *writeFeed) Milestones() []int64 {
+	return f.milestones
+}
@@ -0,0 +1,57 @@
+package feed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/feed"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeed(t *testing.T) {
+	convey.Convey("Creating feeds...", t, func() {
+		convey.Convey("Feeds should be built on read unless the write mode is configured\n", func() {
+			for _, mode := range []string{"", feed.ModeRead, feed.ModeWrite} {
+				f, err := feed.New(&config.FeedConfig{Mode: mode}, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(f, convey.ShouldNotBeNil)
+			}
+		})
+
+		convey.Convey("Unknown modes should be rejected\n", func() {
+			_, err := feed.New(&config.FeedConfig{Mode: "push"}, nil)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Feeds should announce the default milestones unless others are configured\n", func() {
+			f, err := feed.New(&config.FeedConfig{}, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(f.Milestones(), convey.ShouldResemble, []int64{1, 10, 25, 50, 100, 250, 500, 1000})
+
+			f, err = feed.New(&config.FeedConfig{Mode: feed.ModeWrite, Milestones: []int64{5, 20}}, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(f.Milestones(), convey.ShouldResemble, []int64{5, 20})
+		})
+
+		convey.Convey("Feeds built on read should have nothing to prune\n", func() {
+			f, err := feed.New(&config.FeedConfig{Mode: feed.ModeRead}, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(f.Prune(context.Background(), time.Now()), convey.ShouldBeNil)
+		})
+	})
+
+	convey.Convey("Detecting milestones...", t, func() {
+		f, err := feed.New(&config.FeedConfig{Milestones: []int64{1, 10}}, nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Only the sale reaching a milestone should be announced\n", func() {
+			convey.So(feed.IsMilestone(f, 1), convey.ShouldBeTrue)
+			convey.So(feed.IsMilestone(f, 10), convey.ShouldBeTrue)
+			convey.So(feed.IsMilestone(f, 0), convey.ShouldBeFalse)
+			convey.So(feed.IsMilestone(f, 9), convey.ShouldBeFalse)
+			convey.So(feed.IsMilestone(f, 11), convey.ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+)
+
+const (
+	defaultCacheTTL = 2 * time.Second
+	defaultTimeout  = 3 * time.Second
+
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDegraded = "degraded"
+	StatusDraining = "draining"
+)
+
+//errTimeout is reported for checks that did not finish within the timeout
+var errTimeout = errors.New("check timed out")
+
+//Check reports why a dependency cannot be used, or nil if it can. Its error is shown to whoever probes the server,
+//so checks must not return errors carrying addresses or credentials
+type Check func() error
+
+//Result is the outcome of one check
+type Result struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+//Report is the outcome of all checks. The server is ready unless a critical check failed or it is draining; failed
+//checks that are not critical only degrade it
+type Report struct {
+	Status  string             `json:"status"`
+	Checks  map[string]*Result `json:"checks"`
+	Checked time.Time          `json:"checked"`
+}
+
+//Ready reports whether the server should receive traffic
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+type check struct {
+	name     string
+	check    Check
+	critical bool
+}
+
+//Checker runs the registered checks for readiness probes, caching the report briefly
+type Checker struct {
+	cacheTTL time.Duration
+	timeout  time.Duration
+	checks   []check
+
+	mu       sync.Mutex
+	last     *Report
+	draining bool
+}
+
+func NewChecker(conf *config.HealthConfig) *Checker {
+	checker := &Checker{cacheTTL: conf.CacheTTL, timeout: conf.Timeout}
+	if checker.cacheTTL == 0 {
+		checker.cacheTTL = defaultCacheTTL
+	}
+	if checker.timeout == 0 {
+		checker.timeout = defaultTimeout
+	}
+
+	return checker
+}
+
+//Register adds a check. The server is not ready while a critical check fails. Checks must be registered before the
+//first call to Check
+func (c *Checker) Register(name string, fn Check, critical bool) {
+	c.checks = append(c.checks, check{name: name, check: fn, critical: critical})
+}
+
+//Drain makes every later report not ready, whatever the checks say
+func (c *Checker) Drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.draining = true
+}
+
+//Check returns the report of the last run if it is recent enough, otherwise it runs every check concurrently. Probes
+//arriving while the checks run wait for their result rather than running them again
+func (c *Checker) Check() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.draining {
+		return &Report{Status: StatusDraining, Checks: map[string]*Result{}, Checked: time.Now()}
+	}
+
+	if c.last != nil && time.Since(c.last.Checked) < c.cacheTTL {
+		return c.last
+	}
+
+	c.last = c.run()
+	return c.last
+}
+
+func (c *Checker) run() *Report {
+	results := make([]chan error, len(c.checks))
+	for i, chk := range c.checks {
+		results[i] = make(chan error, 1)
+		go func(fn Check, result chan<- error) {
+			result <- fn()
+		}(chk.check, results[i])
+	}
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]*Result, len(c.checks))}
+	deadline := time.NewTimer(c.timeout)
+	defer deadline.Stop()
+
+	expired := false
+	for i, chk := range c.checks {
+		var err error
+		if expired {
+			select {
+			case err = <-results[i]:
+			default:
+				err = errTimeout
+			}
+		} else {
+			select {
+			case err = <-results[i]:
+			case <-deadline.C:
+				expired = true
+				err = errTimeout
+			}
+		}
+
+		result := &Result{Status: StatusOK, Critical: chk.critical}
+		if err != nil {
+			result.Status = StatusFailing
+			result.Error = err.Error()
+			if chk.critical {
+				report.Status = StatusFailing
+			} else if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		}
+		report.Checks[chk.name] = result
+	}
+
+	report.Checked = time.Now()
+	return report
+}
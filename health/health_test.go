@@ -0,0 +1,69 @@
+package health_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/health"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestChecker(t *testing.T) {
+	convey.Convey("Checking readiness...", t, func() {
+		checker := health.NewChecker(&config.HealthConfig{CacheTTL: time.Hour, Timeout: 50 * time.Millisecond})
+		var dbErr, mailErr error
+		runs := 0
+		checker.Register("neo4j", func() error {
+			runs++
+			return dbErr
+		}, true)
+		checker.Register("outbox", func() error { return mailErr }, false)
+
+		convey.Convey("Passing checks should report ready\n", func() {
+			report := checker.Check()
+			convey.So(report.Ready(), convey.ShouldBeTrue)
+			convey.So(report.Status, convey.ShouldEqual, health.StatusOK)
+			convey.So(report.Checks["neo4j"].Status, convey.ShouldEqual, health.StatusOK)
+		})
+
+		convey.Convey("Failing checks that are not critical should only degrade\n", func() {
+			mailErr = errors.New("12 emails overdue")
+			report := checker.Check()
+			convey.So(report.Ready(), convey.ShouldBeTrue)
+			convey.So(report.Status, convey.ShouldEqual, health.StatusDegraded)
+			convey.So(report.Checks["outbox"].Error, convey.ShouldEqual, "12 emails overdue")
+		})
+
+		convey.Convey("Failing critical checks should report not ready\n", func() {
+			dbErr = errors.New("unreachable")
+			convey.So(checker.Check().Ready(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Reports should be cached\n", func() {
+			checker.Check()
+			checker.Check()
+			convey.So(runs, convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Slow checks should fail once the timeout passed\n", func() {
+			checker.Register("storage", func() error {
+				time.Sleep(time.Second)
+				return nil
+			}, true)
+
+			report := checker.Check()
+			convey.So(report.Ready(), convey.ShouldBeFalse)
+			convey.So(report.Checks["storage"].Error, convey.ShouldEqual, "check timed out")
+			convey.So(report.Checks["neo4j"].Status, convey.ShouldEqual, health.StatusOK)
+		})
+
+		convey.Convey("Draining servers should report not ready\n", func() {
+			checker.Drain()
+			report := checker.Check()
+			convey.So(report.Ready(), convey.ShouldBeFalse)
+			convey.So(report.Status, convey.ShouldEqual, health.StatusDraining)
+		})
+	})
+}
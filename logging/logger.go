@@ -1,24 +1,253 @@
 package logging
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/danny-m08/music-match/config"
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
-func Info(msg string) { log.Printf("%s\t%s\t%s", color.GreenString("[INFO]"), getSource(), msg) }
+//Level is the severity of a log line. Lines below the configured level are dropped
+type Level int
 
-func Error(msg string) { log.Printf("%s\t%s\t%s", color.RedString("[ERROR]"), getSource(), msg) }
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+
+	consoleTime = "2006/01/02 15:04:05"
+)
+
+var levelNames = map[Level]string{LevelDebug: "debug", LevelInfo: "info", LevelWarn: "warn", LevelError: "error"}
+
+var levelColors = map[Level]func(string, ...interface{}) string{
+	LevelDebug: color.BlueString,
+	LevelInfo:  color.GreenString,
+	LevelWarn:  color.YellowString,
+	LevelError: color.RedString,
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+//ParseLevel returns the level with the given name
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+//output is where every logger writes, shared so Configure applies to loggers derived before it ran
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format string
+	color  bool
+}
+
+var std = &output{w: os.Stderr, level: LevelInfo, format: FormatConsole, color: isatty.IsTerminal(os.Stderr.Fd())}
+
+//Logger writes log lines carrying a message and key/value fields, along with the fields it was given by With
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+var defaultLogger = &Logger{out: std}
+
+type contextKey struct{}
+
+//Configure sets the level and format of every logger. Colours are only used for consoles that are terminals
+func Configure(conf *config.LoggingConfig) error {
+	level := LevelInfo
+	if conf.Level != "" {
+		var err error
+		level, err = ParseLevel(conf.Level)
+		if err != nil {
+			return err
+		}
+	}
+
+	format := conf.Format
+	if format == "" {
+		format = FormatConsole
+	} else if format != FormatConsole && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.level, std.format = level, format
+	return nil
+}
+
+//SetOutput redirects every logger to w, which is written to without colours
+func SetOutput(w io.Writer) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.w, std.color = w, false
+}
+
+//Default returns the logger without fields the package level functions write to
+func Default() *Logger {
+	return defaultLogger
+}
+
+//NewContext returns a copy of ctx carrying the logger, for FromContext to return
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
 
-func Warn(msg string) { log.Printf("%s\t%s\t%s", color.YellowString("[WARN]"), getSource(), msg) }
+//FromContext returns the logger in ctx, or the default logger if ctx is nil or carries none. Requests carry a logger
+//with their request and trace IDs
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return defaultLogger
+	}
 
-func Debug(msg string) { log.Printf("%s\t%s\t%s", color.BlueString("[DEBUG]"), getSource(), msg) }
+	logger, ok := ctx.Value(contextKey{}).(*Logger)
+	if !ok {
+		return defaultLogger
+	}
+
+	return logger
+}
+
+//With returns a logger adding the key/value pairs to every line, after the fields of l
+func (l *Logger) With(fields ...interface{}) *Logger {
+	combined := make([]interface{}, 0, len(l.fields)+len(fields))
+	return &Logger{out: l.out, fields: append(append(combined, l.fields...), fields...)}
+}
+
+func (l *Logger) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+
+func (l *Logger) Info(msg string, fields ...interface{}) { l.log(LevelInfo, msg, fields) }
+
+func (l *Logger) Warn(msg string, fields ...interface{}) { l.log(LevelWarn, msg, fields) }
+
+func (l *Logger) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+func Debug(msg string, fields ...interface{}) { defaultLogger.log(LevelDebug, msg, fields) }
+
+func Info(msg string, fields ...interface{}) { defaultLogger.log(LevelInfo, msg, fields) }
+
+func Warn(msg string, fields ...interface{}) { defaultLogger.log(LevelWarn, msg, fields) }
+
+func Error(msg string, fields ...interface{}) { defaultLogger.log(LevelError, msg, fields) }
+
+//log writes a line. It must be called directly by the exported functions so the source is that of their caller
+func (l *Logger) log(level Level, msg string, fields []interface{}) {
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+
+	if level < l.out.level {
+		return
+	}
+
+	line := &bytes.Buffer{}
+	all := append(append(make([]interface{}, 0, len(l.fields)+len(fields)), l.fields...), fields...)
+	if l.out.format == FormatJSON {
+		writeJSON(line, level, msg, all)
+	} else {
+		l.writeConsole(line, level, msg, all)
+	}
+
+	_, _ = l.out.w.Write(line.Bytes())
+}
+
+func writeJSON(line *bytes.Buffer, level Level, msg string, fields []interface{}) {
+	line.WriteString(`{"time":`)
+	writeValue(line, time.Now().Format(time.RFC3339Nano))
+	line.WriteString(`,"level":`)
+	writeValue(line, level.String())
+	line.WriteString(`,"source":`)
+	writeValue(line, source())
+	line.WriteString(`,"msg":`)
+	writeValue(line, msg)
+
+	eachField(fields, func(key string, value interface{}) {
+		line.WriteByte(',')
+		writeValue(line, key)
+		line.WriteByte(':')
+		writeValue(line, value)
+	})
+	line.WriteString("}\n")
+}
+
+func (l *Logger) writeConsole(line *bytes.Buffer, level Level, msg string, fields []interface{}) {
+	name := "[" + strings.ToUpper(level.String()) + "]"
+	if l.out.color {
+		name = levelColors[level](name)
+	}
+	fmt.Fprintf(line, "%s %s\t%s\t%s", time.Now().Format(consoleTime), name, source(), msg)
+
+	eachField(fields, func(key string, value interface{}) {
+		line.WriteString("\t" + key + "=")
+		if s, ok := value.(string); ok && !strings.ContainsAny(s, " \t\n\"=") && s != "" {
+			line.WriteString(s)
+		} else {
+			writeValue(line, value)
+		}
+	})
+	line.WriteByte('\n')
+}
+
+//eachField calls fn with every key/value pair of the fields, redacted. A value without a key is reported under
+//!BADKEY rather than dropped
+func eachField(fields []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			fn("!BADKEY", redact(fields[i]))
+			return
+		}
+
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+
+		if sensitive(key) {
+			fn(key, redacted)
+		} else {
+			fn(key, redact(fields[i+1]))
+		}
+	}
+}
+
+func writeValue(line *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	line.Write(data)
+}
 
-func getSource() string {
-	_, file, line, _ := runtime.Caller(2)
-	_, file = filepath.Split(file)
-	return fmt.Sprintf("%s:%d", file, line)
+//source returns the file and line that logged, skipping source, the writer, log and the exported function
+func source() string {
+	_, file, line, _ := runtime.Caller(4)
+	return filepath.Base(file) + ":" + strconv.Itoa(line)
 }
@@ -0,0 +1,121 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+//lines decodes the JSON lines written to out
+func lines(out *bytes.Buffer) []map[string]interface{} {
+	var decoded []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		entry := map[string]interface{}{}
+		_ = json.Unmarshal([]byte(line), &entry)
+		decoded = append(decoded, entry)
+	}
+
+	return decoded
+}
+
+func TestLogger(t *testing.T) {
+	convey.Convey("Logging...", t, func() {
+		out := &bytes.Buffer{}
+		logging.SetOutput(out)
+		defer logging.SetOutput(os.Stderr)
+		err := logging.Configure(&config.LoggingConfig{Level: "info", Format: logging.FormatJSON})
+		convey.So(err, convey.ShouldBeNil)
+		defer func() { _ = logging.Configure(&config.LoggingConfig{}) }()
+
+		convey.Convey("Lines should be JSON objects carrying the level, source, message and fields\n", func() {
+			logging.Error("Unable to send message", "conversation_id", "c1", "error", errors.New("unreachable"))
+
+			entries := lines(out)
+			convey.So(entries, convey.ShouldHaveLength, 1)
+			convey.So(entries[0]["level"], convey.ShouldEqual, "error")
+			convey.So(entries[0]["msg"], convey.ShouldEqual, "Unable to send message")
+			convey.So(entries[0]["source"], convey.ShouldStartWith, "logger_test.go:")
+			convey.So(entries[0]["conversation_id"], convey.ShouldEqual, "c1")
+			convey.So(entries[0]["error"], convey.ShouldEqual, "unreachable")
+		})
+
+		convey.Convey("Lines below the configured level should be dropped\n", func() {
+			logging.Debug("Connecting")
+			logging.Info("Connected")
+			convey.So(lines(out), convey.ShouldHaveLength, 1)
+		})
+
+		convey.Convey("Sensitive fields should be redacted, at any depth\n", func() {
+			user := &types.User{Username: "alice", Password: "hunter2", Email: "alice@example.com"}
+			logging.Info("Logging in", "password", "hunter2", "Api-Key", "mm_abc", "user", user,
+				"request", map[string]interface{}{"nested": map[string]string{"refresh_token": "r1"}})
+
+			entry := lines(out)[0]
+			convey.So(out.String(), convey.ShouldNotContainSubstring, "hunter2")
+			convey.So(entry["password"], convey.ShouldEqual, "[REDACTED]")
+			convey.So(entry["Api-Key"], convey.ShouldEqual, "[REDACTED]")
+			convey.So(entry["user"], convey.ShouldEqual, "{username: 'alice'}")
+			convey.So(out.String(), convey.ShouldNotContainSubstring, "r1")
+		})
+
+		convey.Convey("Structs should be logged without their secrets\n", func() {
+			request := struct {
+				Email    string `json:"email"`
+				Password string `json:"password"`
+			}{Email: "alice@example.com", Password: "hunter2"}
+			logging.Info("Login request", "request", request)
+
+			entry := lines(out)[0]
+			convey.So(entry["request"], convey.ShouldResemble, map[string]interface{}{"email": "alice@example.com",
+				"password": "[REDACTED]"})
+		})
+
+		convey.Convey("Loggers from a request context should add the request's fields\n", func() {
+			r := router.New()
+			r.Use(router.RequestID, router.LogRequests)
+			r.Get("/listings/{id}", func(w http.ResponseWriter, req *http.Request) {
+				logging.FromContext(req.Context()).With("listing_id", "l1").Warn("Listing not found")
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/listings/l1?token=secret", nil)
+			req.Header.Set(router.RequestIDHeader, "req-1")
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := lines(out)
+			convey.So(entries, convey.ShouldHaveLength, 2)
+			convey.So(entries[0]["request_id"], convey.ShouldEqual, "req-1")
+			convey.So(entries[0]["listing_id"], convey.ShouldEqual, "l1")
+			convey.So(entries[1]["msg"], convey.ShouldEqual, "Request served")
+			convey.So(entries[1]["request_id"], convey.ShouldEqual, "req-1")
+			convey.So(entries[1]["route"], convey.ShouldEqual, "/listings/{id}")
+			convey.So(entries[1]["status"], convey.ShouldEqual, float64(http.StatusNotFound))
+			convey.So(out.String(), convey.ShouldNotContainSubstring, "secret")
+		})
+
+		convey.Convey("Contexts without a logger should fall back to the default one\n", func() {
+			convey.So(logging.FromContext(context.Background()), convey.ShouldEqual, logging.Default())
+		})
+
+		convey.Convey("Unknown levels and formats should be rejected\n", func() {
+			convey.So(logging.Configure(&config.LoggingConfig{Level: "verbose"}), convey.ShouldNotBeNil)
+			convey.So(logging.Configure(&config.LoggingConfig{Format: "xml"}), convey.ShouldNotBeNil)
+		})
+	})
+}
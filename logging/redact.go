@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//redacted replaces the values of sensitive fields
+const redacted = "[REDACTED]"
+
+//sensitiveKeys are the parts of field names whose values are never logged. Names are compared in lower case with
+//dashes as underscores, so api-key, apiKey and API_KEY all match
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie", "apikey", "api_key", "recovery_code",
+	"private_key", "credential",
+}
+
+func sensitive(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, part := range sensitiveKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//redact turns a field value into one that can be encoded safely. Errors and stringers are logged as their text, and
+//other composite values go through their JSON encoding with the values of sensitive keys replaced at any depth, so a
+//struct logged whole does not leak the password or token it holds
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%T", value)
+	}
+
+	var decoded interface{}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return fmt.Sprintf("%T", value)
+	}
+
+	return redactJSON(decoded)
+}
+
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if sensitive(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactJSON(nested)
+		}
+	}
+
+	return value
+}
@@ -1,43 +1,59 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/danny-m08/music-match/config"
 	"github.com/danny-m08/music-match/logging"
 	"github.com/danny-m08/music-match/server"
+	"github.com/danny-m08/music-match/tracing"
 )
 
 var configFile string
 
-const defaultConfig = "config.yaml"
+const (
+	defaultConfig     = "config.yaml"
+	traceFlushTimeout = 5 * time.Second
+)
 
 func init() {
 	configFile = os.Getenv("CONFIG_PATH")
 	if configFile == "" {
 		configFile = defaultConfig
 	}
-
-	println(configFile)
 }
 
 func main() {
-	errChan := make(chan error)
+	errChan := make(chan error, 1)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	err := config.CreateConfigFromFile(configFile)
 	if err != nil {
-		logging.Error("Unable to create config from file: " + err.Error())
+		logging.Error("Unable to create config from file", "path", configFile, "error", err)
+		os.Exit(1)
+	}
+
+	err = logging.Configure(config.GetGlobalConfig().GetLoggingConfig())
+	if err != nil {
+		logging.Error("Unable to configure logging", "error", err)
 		os.Exit(1)
 	}
+	logging.Info("Configuration loaded", "path", configFile)
 
-	serv, err := server.NewServer(config.GetGlobalConfig().GetHTTPServerConfig(), config.GetGlobalConfig().GetDBConfig())
+	flushTraces, err := tracing.Setup(config.GetGlobalConfig().GetTracingConfig())
 	if err != nil {
-		logging.Error("Unable to start server: " + err.Error())
+		logging.Error("Unable to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	serv, err := server.NewServer(config.GetGlobalConfig())
+	if err != nil {
+		logging.Error("Unable to start server", "error", err)
 		os.Exit(1)
 	}
 
@@ -47,13 +63,31 @@ func main() {
 
 	select {
 	case err := <-errChan:
-		logging.Error(err.Error())
+		logging.Error("Server stopped", "error", err)
 		os.Exit(1)
 	case sig := <-sigChan:
-		logging.Info(fmt.Sprintf("Signal %s caught -- terminating program", sig.String()))
-		if serv.Close() != nil {
-			logging.Error("Error closing server: " + err.Error())
-		}
-		os.Exit(0)
+		logging.Info("Signal caught -- shutting down", "signal", sig)
+	}
+
+	// A second signal skips draining
+	go func() {
+		sig := <-sigChan
+		logging.Warn("Signal caught again -- terminating program", "signal", sig)
+		os.Exit(1)
+	}()
+
+	err = serv.Shutdown()
+	if err != nil {
+		logging.Error("Error shutting down server", "error", err)
+		os.Exit(1)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	err = flushTraces(ctx)
+	if err != nil {
+		logging.Warn("Unable to export the remaining spans", "error", err)
+	}
+
+	logging.Info("Server shut down")
 }
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "musicmatch"
+
+//Registry holds every metric of the service along with the Go runtime and process metrics. A registry of our own
+//keeps metrics registered by libraries through the default one out of the output
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	neo4jDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "neo4j_transaction_duration_seconds",
+		Help:      "Time taken by Neo4j transactions, including retries, by client operation and access mode.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "mode"})
+
+	neo4jErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "neo4j_transaction_errors_total",
+		Help:      "Neo4j transactions that failed, by client operation and access mode.",
+	}, []string{"operation", "mode"})
+
+	neo4jSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "neo4j_sessions_in_use",
+		Help:      "Neo4j sessions currently running a transaction, each holding a connection from the driver's pool.",
+	})
+
+	signups = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Accounts created.",
+	})
+
+	follows = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "follows_total",
+		Help:      "Users followed.",
+	})
+
+	listings = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "listings_created_total",
+		Help:      "Listings created.",
+	})
+
+	purchases = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "purchases_total",
+		Help:      "Listings bought, by currency.",
+	}, []string{"currency"})
+
+	revenue = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Amount paid for listings before tax, in units of the currency, by currency.",
+	}, []string{"currency"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		neo4jDuration, neo4jErrors, neo4jSessions,
+		signups, follows, listings, purchases, revenue,
+	)
+}
+
+//Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+//ObserveRequest records a served request. Route must be the pattern the request matched rather than its path, so
+//IDs in paths do not create a series each; requests that matched no route are counted under "unmatched"
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+//ObserveTransaction records a Neo4j transaction run for the named client operation in the "read" or "write" mode
+func ObserveTransaction(operation, mode string, duration time.Duration, err error) {
+	neo4jDuration.WithLabelValues(operation, mode).Observe(duration.Seconds())
+	if err != nil {
+		neo4jErrors.WithLabelValues(operation, mode).Inc()
+	}
+}
+
+//SessionOpened and SessionClosed track the Neo4j sessions in use. The driver does not expose its connection pool, so
+//this is how close the pool is to running out
+func SessionOpened() {
+	neo4jSessions.Inc()
+}
+
+func SessionClosed() {
+	neo4jSessions.Dec()
+}
+
+func Signup() {
+	signups.Inc()
+}
+
+func Follow() {
+	follows.Inc()
+}
+
+func ListingCreated() {
+	listings.Inc()
+}
+
+//Purchase records a sale and adds the price paid to the revenue of its currency
+func Purchase(paid currency.Amount) {
+	code := paid.CurrencyCode()
+	purchases.WithLabelValues(code).Inc()
+
+	value, err := strconv.ParseFloat(paid.Number(), 64)
+	if err == nil {
+		revenue.WithLabelValues(code).Add(value)
+	}
+}
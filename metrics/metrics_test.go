@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/router"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func scrape() string {
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return recorder.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	convey.Convey("Recording metrics...", t, func() {
+		convey.Convey("Requests should be counted by route pattern rather than path\n", func() {
+			r := router.New()
+			r.Use(router.Instrument)
+			r.Get("/listings/{id}", func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/listings/abc", nil))
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+			output := scrape()
+			convey.So(output, convey.ShouldContainSubstring,
+				`musicmatch_http_requests_total{method="GET",route="/listings/{id}",status="202"} 1`)
+			convey.So(output, convey.ShouldContainSubstring,
+				`musicmatch_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+			convey.So(output, convey.ShouldNotContainSubstring, "/listings/abc")
+		})
+
+		convey.Convey("Failed transactions should be counted as errors\n", func() {
+			metrics.ObserveTransaction("InsertUser", "write", time.Millisecond, nil)
+			metrics.ObserveTransaction("InsertUser", "write", time.Millisecond, http.ErrAbortHandler)
+
+			output := scrape()
+			convey.So(output, convey.ShouldContainSubstring,
+				`musicmatch_neo4j_transaction_duration_seconds_count{mode="write",operation="InsertUser"} 2`)
+			convey.So(output, convey.ShouldContainSubstring,
+				`musicmatch_neo4j_transaction_errors_total{mode="write",operation="InsertUser"} 1`)
+		})
+
+		convey.Convey("Purchases should add up revenue per currency\n", func() {
+			first, _ := currency.NewAmount("9.99", "EUR")
+			second, _ := currency.NewAmount("0.01", "EUR")
+			metrics.Purchase(first)
+			metrics.Purchase(second)
+
+			output := scrape()
+			convey.So(output, convey.ShouldContainSubstring, `musicmatch_purchases_total{currency="EUR"} 2`)
+			convey.So(output, convey.ShouldContainSubstring, `musicmatch_revenue_total{currency="EUR"} 10`)
+		})
+	})
+}
@@ -0,0 +1,335 @@
+package neo4j
+
+import (
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//SearchUsers retrieves up to limit accounts ordered by username, starting after the given username. When query is
+//set only accounts whose username or email address contains it, ignoring case, are included
+func (c *Client) SearchUsers(query, after string, limit int) ([]*types.Account, error) {
+	cypher := `MATCH (u:User) WHERE u.username > $after
+		AND ($query = '' OR toLower(u.username) CONTAINS $query OR toLower(u.email) CONTAINS $query)
+		return u ORDER BY u.username LIMIT $limit`
+	records, err := c.readTransaction("SearchUsers", cypher, map[string]interface{}{
+		"query": strings.ToLower(query),
+		"after": after,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*types.Account, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		account, err := getAccount(&node)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+//GetAccount retrieves the account with the given username, or nil if there is none
+func (c *Client) GetAccount(username string) (*types.Account, error) {
+	query := `MATCH (u:User { username: $username }) return u`
+	records, err := c.readTransaction("GetAccount", query, map[string]interface{}{"username": username})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	return getAccount(&node)
+}
+
+//SuspendUser suspends the account with the given username and ends all its sessions, recording the entry in the
+//audit log. It returns ErrNotFound if there is no such account and ErrAlreadyExists if it is suspended already
+func (c *Client) SuspendUser(username string, entry *types.AuditEntry) error {
+	c.log().Info("Suspending user", "username", username, "actor", entry.Actor)
+
+	_, err := c.write("SuspendUser", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (u:User { username: $username })
+			WITH u, u.suspended IS NULL AS active
+			FOREACH (_ IN CASE WHEN active THEN [1] ELSE [] END |
+				SET u.suspended = $now, u.suspended_by = $actor, u.suspension_reason = $reason)
+			WITH u, active
+			OPTIONAL MATCH (s:Session)-[:AUTHENTICATES]->(u) WHERE active
+			DETACH DELETE s
+			return DISTINCT active`
+		result, err := t.Run(query, map[string]interface{}{
+			"username": username,
+			"now":      entry.Created,
+			"actor":    entry.Actor,
+			"reason":   optionalString(entry.Reason),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single()
+		if err != nil {
+			return nil, ErrNotFound
+		}
+
+		if !record.Values[0].(bool) {
+			return nil, ErrAlreadyExists
+		}
+
+		return nil, audit(t, entry)
+	})
+	return err
+}
+
+//ReinstateUser lifts the suspension of the account with the given username, recording the entry in the audit log. It
+//returns ErrNotFound if there is no suspended account with that username
+func (c *Client) ReinstateUser(username string, entry *types.AuditEntry) error {
+	c.log().Info("Reinstating user", "username", username, "actor", entry.Actor)
+
+	_, err := c.write("ReinstateUser", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (u:User { username: $username }) WHERE u.suspended IS NOT NULL
+			REMOVE u.suspended, u.suspended_by, u.suspension_reason return u`
+		result, err := t.Run(query, map[string]interface{}{"username": username})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = result.Single(); err != nil {
+			return nil, ErrNotFound
+		}
+
+		return nil, audit(t, entry)
+	})
+	return err
+}
+
+//SetRoles replaces the roles of the account with the given username, recording the entry in the audit log. The user
+//role is always kept. It returns ErrNotFound if there is no such account
+func (c *Client) SetRoles(username string, roles []types.Role, entry *types.AuditEntry) error {
+	c.log().Info("Setting roles", "username", username, "actor", entry.Actor)
+
+	params := []string{string(types.RoleUser)}
+	for _, role := range roles {
+		if role != types.RoleUser {
+			params = append(params, string(role))
+		}
+	}
+
+	_, err := c.write("SetRoles", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (u:User { username: $username }) SET u.roles = $roles return u`
+		result, err := t.Run(query, map[string]interface{}{"username": username, "roles": uniqueStrings(params)})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = result.Single(); err != nil {
+			return nil, ErrNotFound
+		}
+
+		return nil, audit(t, entry)
+	})
+	return err
+}
+
+//GrantRole adds the role to the account with the given username. It is used to bootstrap administrators from the
+//config and returns ErrNotFound if there is no such account
+func (c *Client) GrantRole(username string, role types.Role) error {
+	query := `MATCH (u:User { username: $username })
+		SET u.roles = CASE WHEN $role IN u.roles THEN u.roles ELSE coalesce(u.roles, ['user']) + $role END return u`
+	records, err := c.writeTransaction("GrantRole", query, map[string]interface{}{"username": username, "role": string(role)})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//RemoveListing takes a listing down, recording the entry in the audit log. Removed listings are kept for the records
+//of their sales but can no longer be viewed, bought or interacted with, and are taken out of feeds. It returns
+//ErrNotFound if there is no such listing or it has been removed already
+func (c *Client) RemoveListing(id string, entry *types.AuditEntry) error {
+	c.log().Info("Removing listing", "listing_id", id, "actor", entry.Actor)
+
+	_, err := c.write("RemoveListing", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (l:Listing { id: $id }) WHERE l.removed IS NULL
+			SET l.removed = $now, l.removed_by = $actor, l.removal_reason = $reason
+			WITH l
+			OPTIONAL MATCH (f:FeedEntry)-[:ABOUT]->(l)
+			DETACH DELETE f
+			return DISTINCT l.id`
+		result, err := t.Run(query, map[string]interface{}{
+			"id":     id,
+			"now":    entry.Created,
+			"actor":  entry.Actor,
+			"reason": optionalString(entry.Reason),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = result.Single(); err != nil {
+			return nil, ErrNotFound
+		}
+
+		return nil, audit(t, entry)
+	})
+	return err
+}
+
+//ListSales retrieves up to limit sales across all sellers, newest first, starting after the sale with the given time
+//and transaction ID. Each sale is the listing sold with its seller, transaction and buyer filled in. When username
+//is set only sales the user bought or sold are included
+func (c *Client) ListSales(username string, before time.Time, beforeID string, limit int) ([]*types.Listing, error) {
+	query := `MATCH (buyer:User)-[b:BOUGHT]->(l:Listing)
+		OPTIONAL MATCH (seller:User)-[:SELLING]->(l)
+		WITH buyer, b, l, seller WHERE ($username = '' OR buyer.username = $username OR seller.username = $username)
+		AND (b.date < $before OR (b.date = $before AND b.id < $id))
+		return l, seller, b, buyer, ` + listingCounts("l") + ` ORDER BY b.date DESC, b.id DESC LIMIT $limit`
+	records, err := c.readTransaction("ListSales", query, map[string]interface{}{
+		"username": username,
+		"before":   before,
+		"id":       beforeID,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sales := make([]*types.Listing, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		listing, err := getListing(&node)
+		if err != nil {
+			return nil, err
+		}
+		getCounts(listing, record.Values[4:])
+
+		if seller, ok := record.Values[1].(neo4j.Node); ok {
+			listing.Seller, err = getUser(&seller, map[string]bool{})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		bought := record.Values[2].(neo4j.Relationship)
+		listing.Sold = true
+		listing.Tx, err = getTransaction(&bought)
+		if err != nil {
+			return nil, err
+		}
+
+		buyer := record.Values[3].(neo4j.Node)
+		listing.Tx.Buyer, err = getUser(&buyer, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		sales = append(sales, listing)
+	}
+
+	return sales, nil
+}
+
+//RecordAudit writes the entry to the audit log. Actions that change something record their entry in the same
+//transaction instead
+func (c *Client) RecordAudit(entry *types.AuditEntry) error {
+	_, err := c.write("RecordAudit", func(t neo4j.Transaction) (interface{}, error) {
+		return nil, audit(t, entry)
+	})
+	return err
+}
+
+//ListAuditLog retrieves up to limit audit log entries, newest first, starting after the entry with the given time and
+//ID. When actor is set only the entries of that user are included
+func (c *Client) ListAuditLog(actor string, before time.Time, beforeID string, limit int) ([]*types.AuditEntry, error) {
+	query := `MATCH (a:AuditEntry) WHERE ($actor = '' OR a.actor = $actor)
+		AND (a.created < $before OR (a.created = $before AND a.id < $id))
+		return a ORDER BY a.created DESC, a.id DESC LIMIT $limit`
+	records, err := c.readTransaction("ListAuditLog", query, map[string]interface{}{
+		"actor":  actor,
+		"before": before,
+		"id":     beforeID,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*types.AuditEntry, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		entry := &types.AuditEntry{}
+		entry.ID, _ = node.Props["id"].(string)
+		entry.Actor, _ = node.Props["actor"].(string)
+		entry.Target, _ = node.Props["target"].(string)
+		entry.Reason, _ = node.Props["reason"].(string)
+		entry.Details, _ = node.Props["details"].(string)
+		entry.Created, _ = getTime(node.Props["created"])
+		if action, ok := node.Props["action"].(string); ok {
+			entry.Action = types.AuditAction(action)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+//audit writes an audit log entry in the given transaction. Entries keep the actor's username rather than a
+//relationship so they outlive the actor's account
+func audit(t neo4j.Transaction, entry *types.AuditEntry) error {
+	query := `CREATE (:AuditEntry { id: $id, actor: $actor, action: $action, target: $target, reason: $reason,
+		details: $details, created: $created })`
+	_, err := t.Run(query, map[string]interface{}{
+		"id":      entry.ID,
+		"actor":   entry.Actor,
+		"action":  string(entry.Action),
+		"target":  optionalString(entry.Target),
+		"reason":  optionalString(entry.Reason),
+		"details": optionalString(entry.Details),
+		"created": entry.Created,
+	})
+	return err
+}
+
+//getAccount builds the staff view of a user from a User node
+func getAccount(node *neo4j.Node) (*types.Account, error) {
+	user, err := getUser(node, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	account := &types.Account{
+		Username:  user.Username,
+		Email:     user.Email,
+		Roles:     user.Roles,
+		Verified:  user.Verified,
+		TwoFactor: user.TwoFactor,
+	}
+
+	if created, ok := getTime(node.Props["created"]); ok {
+		account.Created = &created
+	}
+
+	if since, ok := getTime(node.Props["suspended"]); ok {
+		account.Suspension = &types.Suspension{Since: since}
+		account.Suspension.By, _ = node.Props["suspended_by"].(string)
+		account.Suspension.Reason, _ = node.Props["suspension_reason"].(string)
+	}
+
+	return account, nil
+}
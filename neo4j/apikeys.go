@@ -0,0 +1,130 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//keyUseResolution is how stale an API key's last use may get before it is updated, so busy keys are not written to on
+//every request
+const keyUseResolution = time.Minute
+
+//CreateAPIKey stores the key for the user. Only the hash of the key itself is persisted. It returns ErrAlreadyExists if
+//the user already has a key with the same name and ErrLimitReached if they have max keys already
+func (c *Client) CreateAPIKey(user *types.User, key *types.APIKey, tokenHash string, max int) error {
+	c.log().Info("Creating API key", "key_id", key.ID, "username", user.Username)
+
+	_, err := c.write("CreateAPIKey", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (u:User { username: $username })
+			return size([(u)-[:OWNS]->(:APIKey) | 1]), EXISTS { MATCH (u)-[:OWNS]->(:APIKey { name: $name }) }`
+		result, err := t.Run(query, map[string]interface{}{"username": user.Username, "name": key.Name})
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single()
+		if err != nil {
+			return nil, ErrNotFound
+		}
+
+		if record.Values[1].(bool) {
+			return nil, ErrAlreadyExists
+		} else if record.Values[0].(int64) >= int64(max) {
+			return nil, ErrLimitReached
+		}
+
+		query = `MATCH (u:User { username: $username })
+			CREATE (u)-[:OWNS]->(:APIKey { id: $id, name: $name, prefix: $prefix, scopes: $scopes, token: $token,
+				created: $created })`
+		_, err = t.Run(query, map[string]interface{}{
+			"username": user.Username,
+			"id":       key.ID,
+			"name":     key.Name,
+			"prefix":   key.Prefix,
+			"scopes":   scopeStrings(key.Scopes),
+			"token":    tokenHash,
+			"created":  key.Created,
+		})
+		return nil, err
+	})
+	return err
+}
+
+//ListAPIKeys retrieves the user's API keys, oldest first
+func (c *Client) ListAPIKeys(user *types.User) ([]*types.APIKey, error) {
+	query := `MATCH (:User { username: $username })-[:OWNS]->(k:APIKey) return k ORDER BY k.created, k.id`
+	records, err := c.readTransaction("ListAPIKeys", query, map[string]interface{}{"username": user.Username})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*types.APIKey, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		keys = append(keys, getAPIKey(&node))
+	}
+
+	return keys, nil
+}
+
+//RevokeAPIKey deletes the user's API key with the given ID. It returns ErrNotFound if the user has no such key
+func (c *Client) RevokeAPIKey(user *types.User, id string) error {
+	c.log().Info("Revoking API key", "key_id", id, "username", user.Username)
+
+	query := `MATCH (:User { username: $username })-[:OWNS]->(k:APIKey { id: $id }) DETACH DELETE k return count(*)`
+	records, err := c.writeTransaction("RevokeAPIKey", query, map[string]interface{}{"username": user.Username, "id": id})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//UseAPIKey returns the key with the given hash and the user owning it, recording that it was used. It returns nil if
+//there is no such key
+func (c *Client) UseAPIKey(tokenHash string, now time.Time) (*types.User, *types.APIKey, error) {
+	query := `MATCH (u:User)-[:OWNS]->(k:APIKey { token: $token })
+		SET k.last_used = CASE WHEN k.last_used IS NULL OR k.last_used < $stale THEN $now ELSE k.last_used END
+		return u, k`
+	records, err := c.writeTransaction("UseAPIKey", query, map[string]interface{}{
+		"token": tokenHash,
+		"now":   now,
+		"stale": now.Add(-keyUseResolution),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	user, err := getUser(&node, map[string]bool{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := records[0].Values[1].(neo4j.Node)
+	return user, getAPIKey(&key), nil
+}
+
+func getAPIKey(node *neo4j.Node) *types.APIKey {
+	key := &types.APIKey{}
+	key.ID, _ = node.Props["id"].(string)
+	key.Name, _ = node.Props["name"].(string)
+	key.Prefix, _ = node.Props["prefix"].(string)
+	key.Created, _ = getTime(node.Props["created"])
+	if lastUsed, ok := getTime(node.Props["last_used"]); ok {
+		key.LastUsed = &lastUsed
+	}
+
+	key.Scopes = getScopes(node.Props["scopes"])
+	return key
+}
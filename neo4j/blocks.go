@@ -0,0 +1,138 @@
+package neo4j
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	blocked = "BLOCKED"
+	muted   = "MUTED"
+)
+
+//Block records that the blocker blocked the user. Any following between the two is removed in either direction, as
+//are the feed entries they fanned out to each other
+func (c *Client) Block(blocker, user *types.User) error {
+	c.log().Info("Blocking user", "blocker", blocker.Username, "username", user.Username)
+	if blocker.Username == user.Username {
+		return ErrForbidden
+	}
+
+	query := `MATCH (blocker:User { username: $blocker }), (user:User { username: $user })
+		MERGE (blocker)-[b:BLOCKED]->(user) ON CREATE SET b.created = $now
+		WITH blocker, user
+		OPTIONAL MATCH (blocker)-[f:FOLLOWS]-(user) DELETE f
+		WITH DISTINCT blocker, user
+		OPTIONAL MATCH (blocker)<-[:FOR]-(e:FeedEntry)-[:BY]->(user) DETACH DELETE e
+		WITH DISTINCT blocker, user
+		OPTIONAL MATCH (user)<-[:FOR]-(e:FeedEntry)-[:BY]->(blocker) DETACH DELETE e
+		return count(*)`
+	records, err := c.writeTransaction("Block", query, map[string]interface{}{
+		"blocker": blocker.Username,
+		"user":    user.Username,
+		"now":     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//Unblock removes the blocker's block on the user. Removed followings are not restored
+func (c *Client) Unblock(blocker, user *types.User) error {
+	c.log().Info("Unblocking user", "blocker", blocker.Username, "username", user.Username)
+	return c.removeUserRelationship("Unblock", blocked, blocker, user)
+}
+
+//Mute hides the user's activity from the muter's feed without affecting anything else
+func (c *Client) Mute(muter, user *types.User) error {
+	c.log().Info("Muting user", "muter", muter.Username, "username", user.Username)
+	if muter.Username == user.Username {
+		return ErrForbidden
+	}
+
+	query := `MATCH (muter:User { username: $muter }), (user:User { username: $user })
+		MERGE (muter)-[m:MUTED]->(user) ON CREATE SET m.created = $now return m`
+	records, err := c.writeTransaction("Mute", query, map[string]interface{}{
+		"muter": muter.Username,
+		"user":  user.Username,
+		"now":   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//Unmute removes the muter's mute on the user
+func (c *Client) Unmute(muter, user *types.User) error {
+	c.log().Info("Unmuting user", "muter", muter.Username, "username", user.Username)
+	return c.removeUserRelationship("Unmute", muted, muter, user)
+}
+
+//ListBlocked retrieves up to limit users the user blocked ordered by username, starting after the given username
+func (c *Client) ListBlocked(user *types.User, after string, limit int) ([]*types.User, error) {
+	return c.listUserRelationships("ListBlocked", blocked, user, after, limit)
+}
+
+//ListMuted retrieves up to limit users the user muted ordered by username, starting after the given username
+func (c *Client) ListMuted(user *types.User, after string, limit int) ([]*types.User, error) {
+	return c.listUserRelationships("ListMuted", muted, user, after, limit)
+}
+
+//IsBlocked reports whether either of the two users has blocked the other
+func (c *Client) IsBlocked(a, b *types.User) (bool, error) {
+	query := `MATCH (:User { username: $a })-[r:BLOCKED]-(:User { username: $b }) return count(r)`
+	records, err := c.readTransaction("IsBlocked", query, map[string]interface{}{"a": a.Username, "b": b.Username})
+	if err != nil {
+		return false, err
+	}
+
+	return records[0].Values[0].(int64) > 0, nil
+}
+
+func (c *Client) removeUserRelationship(op, relType string, from, to *types.User) error {
+	query := fmt.Sprintf(`MATCH (:User { username: $from })-[r:%s]->(:User { username: $to }) DELETE r return count(*)`, relType)
+	records, err := c.writeTransaction(op, query, map[string]interface{}{"from": from.Username, "to": to.Username})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (c *Client) listUserRelationships(op, relType string, user *types.User, after string, limit int) ([]*types.User, error) {
+	query := fmt.Sprintf(`MATCH (:User { username: $username })-[:%s]->(u:User) WHERE u.username > $after
+		return u.username ORDER BY u.username LIMIT $limit`, relType)
+	records, err := c.readTransaction(op, query, map[string]interface{}{
+		"username": user.Username,
+		"after":    after,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*types.User, 0, len(records))
+	for _, record := range records {
+		users = append(users, &types.User{Username: record.Values[0].(string)})
+	}
+
+	return users, nil
+}
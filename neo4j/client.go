@@ -1,21 +1,54 @@
 package neo4j
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
+	"github.com/danny-m08/music-match/auth"
 	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/errs"
 	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/tracing"
 	"github.com/danny-m08/music-match/types"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
-	session neo4j.Session
-	driver  neo4j.Driver
+	driver        neo4j.Driver
+	sessionConfig neo4j.SessionConfig
+	//ctx is the context operations are traced in, see WithContext
+	ctx context.Context
 }
 
+var (
+	//ErrNotFound is returned when the node an operation depends on does not exist
+	ErrNotFound = errs.New(errs.CodeNotFound, "not found")
+	//ErrAlreadyExists is returned when creating a node would break a uniqueness rule
+	ErrAlreadyExists = errs.New(errs.CodeAlreadyExists, "already exists")
+	//ErrUsernameTaken is returned when a user would get a username another user has
+	ErrUsernameTaken = errs.New(errs.CodeUsernameTaken, "username already taken")
+	//ErrEmailTaken is returned when a user would get an email address another user has
+	ErrEmailTaken = errs.New(errs.CodeEmailTaken, "email address already in use")
+	//ErrAlreadySold is returned when trying to purchase a listing that has been bought already
+	ErrAlreadySold = errs.New(errs.CodeAlreadySold, "listing has already been sold")
+	//ErrLimitReached is returned when an operation would exceed a usage limit
+	ErrLimitReached = errs.New(errs.CodeLimitReached, "limit reached")
+	//ErrSelfFollow is returned when a user tries to follow themselves
+	ErrSelfFollow = errs.New(errs.CodeSelfAction, "users cannot follow themselves")
+	//ErrForbidden is returned when a user tries to change something they do not own
+	ErrForbidden = errs.New(errs.CodeForbidden, "forbidden")
+	//ErrBlocked is returned when one of the users involved in an interaction has blocked the other
+	ErrBlocked = errs.New(errs.CodeBlocked, "blocked")
+	//ErrAlreadyVerified is returned when asking to verify an email address that has been verified already
+	ErrAlreadyVerified = errs.New(errs.CodeAlreadyVerified, "email address already verified")
+)
+
 const (
 	username = "username"
 	email    = "email"
@@ -58,24 +91,36 @@ func NewClient(conf *config.Neo4jConfig) (*Client, error) {
 		return nil, err
 	}
 
-	logging.Info(fmt.Sprintf("Creating Neo4j session to config %s at %s", conf.Database, conf.URI))
-	session := driver.NewSession(neo4j.SessionConfig{
-		AccessMode:   neo4j.AccessModeWrite,
-		DatabaseName: conf.Database,
-		FetchSize:    conf.BatchSize,
-	})
-
+	logging.Info("Connected to Neo4j database", "database", conf.Database, "uri", conf.URI)
 	return &Client{
-		session: session,
-		driver:  driver,
+		driver: driver,
+		sessionConfig: neo4j.SessionConfig{
+			AccessMode:   neo4j.AccessModeWrite,
+			DatabaseName: conf.Database,
+			FetchSize:    conf.BatchSize,
+		},
 	}, nil
 }
 
+//WithContext returns a copy of the client tracing its operations as children of the span in ctx, sharing the driver.
+//Operations of a client that was not given a context are not traced, which keeps polling such as readiness checks out
+//of the traces
+func (c *Client) WithContext(ctx context.Context) *Client {
+	bound := *c
+	bound.ctx = ctx
+	return &bound
+}
+
+//log returns the logger of the request or job the client was given by WithContext
+func (c *Client) log() *logging.Logger {
+	return logging.FromContext(c.ctx)
+}
+
 ////GetUser queries the DB for the user with the given types object
 func (c *Client) GetUser(user *types.User) (*types.User, error) {
-	query := fmt.Sprintf("MATCH (user:User) WHERE user.username = '%s' OR user.email = '%s' return user", user.Username, user.Email)
+	query := "MATCH (user:User) WHERE user.username = $username OR user.email = $email return user"
 
-	records, err := c.readTransaction(query)
+	records, err := c.readTransaction("GetUser", query, map[string]interface{}{"username": user.Username, "email": user.Email})
 	if err != nil {
 		return nil, err
 	}
@@ -85,39 +130,95 @@ func (c *Client) GetUser(user *types.User) (*types.User, error) {
 	}
 
 	entry := records[0].Values[0].(neo4j.Node)
-	return &types.User{
-		Email:    entry.Props["email"].(string),
-		Username: entry.Props["username"].(string),
-		Password: entry.Props["password"].(string),
-	}, nil
+	return getUser(&entry, map[string]bool{password: true})
 }
 
-//CreateFollowing creates a follower relationship from user -> follower in the Neo4j DB
+//CreateFollowing creates a follower relationship from user -> follower in the Neo4j DB. It returns ErrSelfFollow if
+//both are the same user, ErrAlreadyExists if the follower already follows the user, ErrBlocked if either of them
+//blocked the other and ErrNotFound if either of them does not exist
 func (c *Client) CreateFollowing(user, follower *types.User) error {
-	logging.Info(fmt.Sprintf("Creating Follower relationship with follower %s -> user %s", user.String(), follower.String()))
-	query := `MATCH (user:User), (follower:User) WHERE (user.email = '%s' AND follower.email = '%s') OR (user.username = '%s' AND follower.username = '%s') CREATE (follower)-[f:FOLLOWS]->(user) return type(f)`
-	query = fmt.Sprintf(query, user.Email, follower.Email, user.Username, follower.Username)
-	_, err := c.writeTransaction(query)
+	c.log().Info("Creating Follower relationship", "follower", follower.Username, "username", user.Username)
+	if user.Username == follower.Username {
+		return ErrSelfFollow
+	}
+
+	_, err := c.write("CreateFollowing", func(t neo4j.Transaction) (interface{}, error) {
+		params := map[string]interface{}{
+			"user":     user.Username,
+			"follower": follower.Username,
+			"now":      time.Now(),
+		}
+		// Writing to the follower first locks it, so a concurrent Block either sees the following to remove or is
+		// seen by the check
+		query := `MATCH (user:User { username: $user }), (follower:User { username: $follower })
+			SET follower.following = true
+			WITH user, follower REMOVE follower.following
+			WITH user, follower WHERE NOT EXISTS { MATCH (user)-[:BLOCKED]-(follower) }
+			MERGE (follower)-[f:FOLLOWS]->(user) ON CREATE SET f.created = $now return f.created = $now`
+		result, err := t.Run(query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single()
+		if err == nil {
+			if created, _ := record.Values[0].(bool); !created {
+				return nil, ErrAlreadyExists
+			}
+			return nil, nil
+		}
+
+		// Nothing was followed, either because the users blocked each other or because one of them does not exist
+		query = `MATCH (user:User { username: $user }), (follower:User { username: $follower }) return count(*)`
+		result, err = t.Run(query, params)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err = result.Single()
+		if err != nil {
+			return nil, err
+		} else if record.Values[0].(int64) > 0 {
+			return nil, ErrBlocked
+		}
+
+		return nil, ErrNotFound
+	})
 	return err
 }
 
-//Unfollow removes the FOLLOWS relationship between the 2 users starting from follower -> user
+//Unfollow removes the FOLLOWS relationship between the 2 users starting from follower -> user, along with the feed
+//entries the user fanned out to the follower. It returns ErrNotFound if the follower does not follow the user
 func (c *Client) Unfollow(user, follower *types.User) error {
-	logging.Info(fmt.Sprintf("Unfollow request to unfollow %s from %s", follower.String(), user.String()))
-	query := `MATCH (follower:User { username: '%s' })-[f:FOLLOWS]->(user:User { username: '%s' }) DELETE f`
-	query = fmt.Sprintf(query, follower.Username, user.Username)
-	_, err := c.writeTransaction(query)
-	return err
+	c.log().Info("Removing Follower relationship", "follower", follower.Username, "username", user.Username)
+	query := `MATCH (follower:User { username: $follower })-[f:FOLLOWS]->(user:User { username: $user }) DELETE f
+		WITH follower, user
+		OPTIONAL MATCH (follower)<-[:FOR]-(e:FeedEntry)-[:BY]->(user) DETACH DELETE e
+		return count(DISTINCT user)`
+	records, err := c.writeTransaction("Unfollow", query, map[string]interface{}{"user": user.Username, "follower": follower.Username})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
 
-//GetFollowers queries the database for all followers for the given user
-func (c *Client) GetFollowers(user *types.User) ([]*types.User, error) {
-	logging.Info("Retrieving followers for " + user.String())
+//GetFollowers queries the database for all followers for the given user. Followers who are blocked from or by the
+//viewer, who may be nil, are left out
+func (c *Client) GetFollowers(user, viewer *types.User) ([]*types.User, error) {
+	c.log().Info("Retrieving followers", "username", user.Username)
 	users := make([]*types.User, 0)
 
-	query := `MATCH (follower:User)-[f:FOLLOWS]->(user:User { username: '%s' }) return follower`
-	query = fmt.Sprintf(query, user.Username)
-	records, err := c.readTransaction(query)
+	query := `MATCH (follower:User)-[f:FOLLOWS]->(user:User { username: $username }) WHERE ` + notBlocked("follower") + `
+		return follower`
+	records, err := c.readTransaction("GetFollowers", query, map[string]interface{}{
+		"username": user.Username,
+		"viewer":   viewerParam(viewer),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -135,41 +236,150 @@ func (c *Client) GetFollowers(user *types.User) ([]*types.User, error) {
 
 //DeleteUser deletes a user from the database
 func (c *Client) DeleteUser(username, email string) error {
-	query := `Match (u:User {email: '%s'}) DETACH DELETE u`
-	query = fmt.Sprintf(query, email)
-	_, err := c.writeTransaction(query)
+	query := `Match (u:User {email: $email}) DETACH DELETE u`
+	_, err := c.writeTransaction("DeleteUser", query, map[string]interface{}{"email": email})
 	return err
 }
 
-//Insert User inserts the user into the database
+//InsertUser inserts the user into the database. New users start with an unverified email address and the user role
 func (c *Client) InsertUser(user *types.User) error {
-	query := fmt.Sprintf("CREATE (u:User %s)", user.String())
-
-	_, err := c.writeTransaction(query)
+	query := `CREATE (u:User { username: $username, email: $email, password: $password, verified: false, roles: $roles,
+		created: $created })`
+
+	_, err := c.writeTransaction("InsertUser", query, map[string]interface{}{
+		"username": user.Username,
+		"email":    user.Email,
+		"password": user.Password,
+		"roles":    []string{string(types.RoleUser)},
+		"created":  time.Now(),
+	})
 	return err
 }
 
 func (c *Client) CreateListing(listing *types.Listing) error {
-	query := "CREATE (l:Listing { id : '%s', price: '%s', date: '%s'}) return l"
-	logging.Info("Creating new listing: " + listing.String())
-	query = fmt.Sprintf(query, listing.ID, listing.Price.String(), listing.Created.String())
-	_, err := c.writeTransaction(query)
+	query := "CREATE (l:Listing { id: $id, price: $price, date: $date, track: $track, path: $path }) return l"
+	c.log().Info("Creating new listing", "listing", listing)
+
+	params := map[string]interface{}{
+		"id":    listing.ID,
+		"price": listing.Price.String(),
+		"date":  *listing.Created,
+		"track": nil,
+		"path":  nil,
+	}
+	if listing.Track != nil {
+		params["track"] = listing.Track.Name
+		params["path"] = listing.Track.Path
+	}
+
+	_, err := c.writeTransaction("CreateListing", query, params)
 	return err
 }
 
-//NewListing creates a new listing and sets the given user as the seller
+//GetListing retrieves the listing with the given ID along with its seller and, if it has been sold and the viewer may
+//see it, the transaction. It returns nil if no such listing exists, it has been removed or its seller has blocked the viewer, who may be nil
+//for anonymous reads
+func (c *Client) GetListing(id string, viewer *types.User) (*types.Listing, error) {
+	query := `MATCH (l:Listing { id: $id }) WHERE l.removed IS NULL OPTIONAL MATCH (seller:User)-[:SELLING]->(l)
+		WITH l, seller WHERE seller IS NULL OR ` + notBlockedBy("seller") + `
+		OPTIONAL MATCH (buyer:User)-[b:BOUGHT]->(l)
+		return l, seller, buyer, b, ` + listingCounts("l")
+	records, err := c.readTransaction("GetListing", query, map[string]interface{}{"id": id, "viewer": viewerParam(viewer)})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	listing, err := getListing(&node)
+	if err != nil {
+		return nil, err
+	}
+
+	getCounts(listing, records[0].Values[4:])
+
+	if seller, ok := records[0].Values[1].(neo4j.Node); ok {
+		listing.Seller, err = getUser(&seller, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bought, ok := records[0].Values[3].(neo4j.Relationship); ok {
+		listing.Sold = true
+		buyer := records[0].Values[2].(neo4j.Node)
+		if !canViewTransaction(viewer, listing.Seller, &buyer) {
+			return listing, nil
+		}
+
+		listing.Tx, err = getTransaction(&bought)
+		if err != nil {
+			return nil, err
+		}
+
+		listing.Tx.Buyer, err = getUser(&buyer, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return listing, nil
+}
+
+//canViewTransaction reports whether the viewer may see the details of a listing's sale, which only its buyer, its
+//seller and staff allowed to view transactions may
+func canViewTransaction(viewer, seller *types.User, buyer *neo4j.Node) bool {
+	if viewer == nil {
+		return false
+	}
+
+	return buyer.Props[username] == viewer.Username || (seller != nil && seller.Username == viewer.Username) ||
+		auth.Can(viewer, auth.PermViewTransactions)
+}
+
+//NewListing creates a new listing and sets the given user as the seller, granting them the seller role
 func (c *Client) CreateUserListing(user *types.User, l *types.Listing) error {
 	err := c.CreateListing(l)
 	if err != nil {
 		return err
 	}
 
-	query := `MATCH (u:User { username: '%s' }),(listing:Listing { id : '%s'}) CREATE (u)-[s:SELLING]->(listing) return s`
-	query = fmt.Sprintf(query, user.Username, l.ID)
-	_, err = c.writeTransaction(query)
+	query := `MATCH (u:User { username: $username }),(listing:Listing { id : $id }) CREATE (u)-[s:SELLING]->(listing)
+		SET u.roles = CASE WHEN 'seller' IN u.roles THEN u.roles ELSE coalesce(u.roles, ['user']) + 'seller' END return s`
+	_, err = c.writeTransaction("CreateUserListing", query, map[string]interface{}{"username": user.Username, "id": l.ID})
 	return err
 }
 
+//SetTrackPath sets the storage key of the track file of a listing the seller is selling. It returns ErrNotFound if the
+//seller has no such listing or it was removed, and ErrAlreadySold once the track was bought
+func (c *Client) SetTrackPath(seller *types.User, listingID, path string) error {
+	c.log().Info("Setting track of listing", "listing_id", listingID, "seller", seller.Username)
+	query := `MATCH (:User { username: $seller })-[:SELLING]->(l:Listing { id: $id }) WHERE l.removed IS NULL
+		OPTIONAL MATCH (l)<-[b:BOUGHT]-(:User) WITH l, count(b) AS sales
+		FOREACH (_ IN CASE WHEN sales = 0 THEN [1] ELSE [] END | SET l.path = $path) return sales`
+	records, err := c.writeTransaction("SetTrackPath", query, map[string]interface{}{
+		"seller": seller.Username,
+		"id":     listingID,
+		"path":   path,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	if records[0].Values[0].(int64) > 0 {
+		return ErrAlreadySold
+	}
+
+	return nil
+}
+
 //func (c *Client) GetListingsForUser(user *types.User) ([]*types.Listing, error) {
 //	query := `MATCH (u:User { username: '%s'}), (l:Listing)-(u)-[SELLING]->(l) return l`
 //	query = fmt.Sprintf(query, user.Username)
@@ -191,17 +401,19 @@ func (c *Client) CreateUserListing(user *types.User, l *types.Listing) error {
 
 //Sold marks the listing as sold in the DB
 func (c *Client) Sold(user *types.User, l *types.Listing) error {
-	query := `MATCH (u:User { username: '%s' }) CREATE (u)-[:BOUGHT {}]->(:Listing { id : '%s', date: '%s'})`
-	query = fmt.Sprintf(query, user.Username, l.ID, l.Created.String())
-	_, err := c.writeTransaction(query)
+	query := `MATCH (u:User { username: $username }) CREATE (u)-[:BOUGHT {}]->(:Listing { id : $id, date: $date })`
+	_, err := c.writeTransaction("Sold", query, map[string]interface{}{
+		"username": user.Username,
+		"id":       l.ID,
+		"date":     *l.Created,
+	})
 	return err
 }
 
 //IsSold checks if the given listing is sold and returns transaction details if sold
 func (c *Client) IsSold(l *types.Listing) (*types.Transaction, error) {
-	query := `MATCH (u:User)-[BOUGHT]->(l:Listing { id: '%s' }) return u, l`
-	query = fmt.Sprintf(query, l.ID)
-	records, err := c.readTransaction(query)
+	query := `MATCH (u:User)-[BOUGHT]->(l:Listing { id: $id }) return u, l`
+	records, err := c.readTransaction("IsSold", query, map[string]interface{}{"id": l.ID})
 	if err != nil {
 		return nil, err
 	}
@@ -211,7 +423,7 @@ func (c *Client) IsSold(l *types.Listing) (*types.Transaction, error) {
 		buyer := record.Values[0].(neo4j.Node)
 		txDetails := record.Values[1].(neo4j.Node)
 		tx.ID = txDetails.Props["id"].(string)
-		tx.Date, _ = time.Parse(dateFormat, txDetails.Props["date"].(string))
+		tx.Date, _ = getTime(txDetails.Props["date"])
 		tx.Buyer, err = getUser(&buyer, map[string]bool{})
 		if err != nil {
 			return nil, err
@@ -244,12 +456,16 @@ func (c *Client) IsSold(l *types.Listing) (*types.Transaction, error) {
 //	return res, nil
 //}
 
-//writeTransaction is a generic write operation on the database
-func (c *Client) writeTransaction(query string) ([]*neo4j.Record, error) {
-	records, err := c.session.WriteTransaction(
+//writeTransaction is a generic write operation on the database, run for the named operation
+func (c *Client) writeTransaction(op, query string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	records, err := c.write(op,
 		func(tx neo4j.Transaction) (interface{}, error) {
 
-			results, err := tx.Run(query, map[string]interface{}{})
+			results, err := tx.Run(query, params)
 			if err != nil {
 				return nil, err
 			}
@@ -264,11 +480,83 @@ func (c *Client) writeTransaction(query string) ([]*neo4j.Record, error) {
 	return records.([]*neo4j.Record), nil
 }
 
-func (c *Client) readTransaction(query string) ([]*neo4j.Record, error) {
-	records, err := c.session.ReadTransaction(
+//write runs the work in a write transaction. Sessions must not be shared between goroutines, so every transaction
+//gets a session of its own; the connections behind them are pooled by the driver. op names the Client method the
+//transaction is run for in metrics and traces
+func (c *Client) write(op string, work neo4j.TransactionWork) (interface{}, error) {
+	metrics.SessionOpened()
+	defer metrics.SessionClosed()
+	span := c.startSpan(op, "write")
+
+	session := c.driver.NewSession(c.sessionConfig)
+	defer session.Close()
+
+	start := time.Now()
+	result, err := session.WriteTransaction(work)
+	metrics.ObserveTransaction(op, "write", time.Since(start), err)
+	tracing.End(span, err)
+	return result, constraintError(err)
+}
+
+//constraintError turns the violation of a uniqueness constraint from init.cypher into ErrUsernameTaken, ErrEmailTaken
+//or ErrAlreadyExists. Checking before writing leaves a window for concurrent writes, which the constraints close
+func constraintError(err error) error {
+	var dbErr *neo4j.Neo4jError
+	if !errors.As(err, &dbErr) || dbErr.Code != "Neo.ClientError.Schema.ConstraintValidationFailed" {
+		return err
+	}
+
+	if strings.Contains(dbErr.Msg, "`User`") && strings.Contains(dbErr.Msg, "`username`") {
+		return errs.Wrap(ErrUsernameTaken.Code, ErrUsernameTaken.Message, err)
+	} else if strings.Contains(dbErr.Msg, "`User`") && strings.Contains(dbErr.Msg, "`email`") {
+		return errs.Wrap(ErrEmailTaken.Code, ErrEmailTaken.Message, err)
+	}
+
+	return errs.Wrap(ErrAlreadyExists.Code, ErrAlreadyExists.Message, err)
+}
+
+//read runs the work in a read transaction on a session of its own, for the operation named op
+func (c *Client) read(op string, work neo4j.TransactionWork) (interface{}, error) {
+	metrics.SessionOpened()
+	defer metrics.SessionClosed()
+	span := c.startSpan(op, "read")
+
+	session := c.driver.NewSession(c.sessionConfig)
+	defer session.Close()
+
+	start := time.Now()
+	result, err := session.ReadTransaction(work)
+	metrics.ObserveTransaction(op, "read", time.Since(start), err)
+	tracing.End(span, err)
+	return result, err
+}
+
+//startSpan starts the span of a transaction, named after the client operation. Queries and their parameters carry
+//user data and are left out
+func (c *Client) startSpan(op, mode string) trace.Span {
+	if c.ctx == nil {
+		return trace.SpanFromContext(context.Background())
+	}
+
+	_, span := tracing.Start(c.ctx, "neo4j "+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNeo4j,
+		semconv.DBName(c.sessionConfig.DatabaseName),
+		semconv.DBOperation(op),
+		attribute.String("db.neo4j.access_mode", mode),
+	))
+	return span
+}
+
+//readTransaction is a generic read operation on the database, run for the named operation
+func (c *Client) readTransaction(op, query string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	records, err := c.read(op,
 		func(tx neo4j.Transaction) (interface{}, error) {
 
-			results, err := tx.Run(query, map[string]interface{}{})
+			results, err := tx.Run(query, params)
 			if err != nil {
 				return nil, err
 			}
@@ -294,13 +582,33 @@ func getUser(node *neo4j.Node, required map[string]bool) (*types.User, error) {
 		return nil, errors.New("Unable to retrieve email for user")
 	}
 
-	if node.Props[password] == nil && required[password] {
+	if required[password] {
+		if node.Props[password] == nil {
+			return nil, errors.New("Unable to retrieve password for user")
+		}
 		user.Password = node.Props[password].(string)
-		return nil, errors.New("Unable to retrieve password for user")
 	}
 
 	user.Username = node.Props[username].(string)
-	user.Password = node.Props[email].(string)
+	user.Email = node.Props[email].(string)
+
+	// Accounts created before email verification was introduced count as verified
+	verified, ok := node.Props["verified"].(bool)
+	user.Verified = verified || !ok
+	user.TwoFactor, _ = node.Props["totp_enabled"].(bool)
+	user.Suspended = node.Props["suspended"] != nil
+
+	// Accounts created before roles were introduced hold the user role only
+	user.Roles = []types.Role{types.RoleUser}
+	if roles, ok := node.Props["roles"].([]interface{}); ok {
+		user.Roles = make([]types.Role, 0, len(roles))
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				user.Roles = append(user.Roles, types.Role(r))
+			}
+		}
+	}
+
 	return user, nil
 }
 
@@ -325,10 +633,10 @@ func getUser(node *neo4j.Node, required map[string]bool) (*types.User, error) {
 //}
 
 func (c *Client) Close() error {
-	err := c.session.Close()
-	if err != nil {
-		return err
-	}
-
 	return c.driver.Close()
 }
+
+//Ping checks that the database can be reached
+func (c *Client) Ping() error {
+	return c.driver.VerifyConnectivity()
+}
@@ -116,6 +116,50 @@ func TestE2E(t *testing.T) {
 			convey.Convey(fmt.Sprintf("If we create a following from %s -> %s we should get no errors and proper structs\n", follower.String(), user.String()), t, func() {
 				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldBeNil)
 			})
+
+			convey.Convey("If we try to create the same following again or follow ourselves we should get an error\n", t, func() {
+				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldEqual, neo4j.ErrAlreadyExists)
+				convey.So(client.CreateFollowing(&user, &user), convey.ShouldEqual, neo4j.ErrSelfFollow)
+			})
+		})
+
+		t.Run("ListFollows", func(t *testing.T) {
+			convey.Convey("If we list followers and followings we should get one page with the right counts\n", t, func() {
+				followers, err := client.ListFollowers(&user, nil, "", 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(followers), convey.ShouldEqual, 1)
+				convey.So(followers[0].User.Username, convey.ShouldEqual, follower.Username)
+				convey.So(followers[0].Mutual, convey.ShouldBeFalse)
+
+				following, err := client.ListFollowing(&follower, nil, "", 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(following), convey.ShouldEqual, 1)
+				convey.So(following[0].User.Username, convey.ShouldEqual, user.Username)
+
+				followerCount, followingCount, err := client.GetFollowCounts(&user, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(followerCount, convey.ShouldEqual, 1)
+				convey.So(followingCount, convey.ShouldEqual, 0)
+			})
+
+			convey.Convey("Followers blocked by the viewer should be left out of lists and counts\n", t, func() {
+				viewer := types.User{Username: "viewer", Password: "viewer123", Email: "viewer@gmail.com"}
+				convey.So(client.InsertUser(&viewer), convey.ShouldBeNil)
+				convey.So(client.Block(&viewer, &follower), convey.ShouldBeNil)
+
+				followers, err := client.GetFollowers(&user, &viewer)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(followers), convey.ShouldEqual, 0)
+
+				followerCount, _, err := client.GetFollowCounts(&user, &viewer)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(followerCount, convey.ShouldEqual, 0)
+
+				_, _, err = client.GetFollowCounts(&follower, &viewer)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+
+				convey.So(client.DeleteUser(viewer.Username, viewer.Email), convey.ShouldBeNil)
+			})
 		})
 
 		t.Run("GetFollowers", func(t *testing.T) {
@@ -123,12 +167,12 @@ func TestE2E(t *testing.T) {
 				user.Password = ""
 				follower.Password = ""
 
-				followers, err := client.GetFollowers(&user)
+				followers, err := client.GetFollowers(&user, nil)
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(len(followers), convey.ShouldEqual, 1)
 				convey.So(*followers[0], convey.ShouldResemble, follower)
 
-				followers, err = client.GetFollowers(&follower)
+				followers, err = client.GetFollowers(&follower, nil)
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(len(followers), convey.ShouldEqual, 0)
 			})
@@ -136,14 +180,40 @@ func TestE2E(t *testing.T) {
 
 		t.Run("Unfollow", func(t *testing.T) {
 			convey.Convey("If follower unfollows user, we should get no error and the user should no longer have any followers\n", t, func() {
+				convey.So(client.FanOut(&types.FeedItem{ID: "milestone:" + user.Username + ":1", Type: types.FeedMilestone,
+					Actor: &types.User{Username: user.Username}, Milestone: 1, Time: time.Now()}), convey.ShouldBeNil)
+				entries, err := client.GetFeedEntries(&follower, time.Now().Add(time.Hour), "", 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(entries, convey.ShouldHaveLength, 1)
+
 				convey.So(client.Unfollow(&user, &follower), convey.ShouldBeNil)
 
-				followers, err := client.GetFollowers(&user)
+				entries, err = client.GetFeedEntries(&follower, time.Now().Add(time.Hour), "", 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(entries, convey.ShouldBeEmpty)
+
+				followers, err := client.GetFollowers(&user, nil)
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(len(followers), convey.ShouldEqual, 0)
 			})
 		})
 
+		t.Run("Block", func(t *testing.T) {
+			convey.Convey("If a user blocks their follower the following should be removed and cannot be recreated until unblocked\n", t, func() {
+				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldBeNil)
+				convey.So(client.Block(&user, &follower), convey.ShouldBeNil)
+
+				followers, err := client.GetFollowers(&user, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(followers), convey.ShouldEqual, 0)
+				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldEqual, neo4j.ErrBlocked)
+
+				convey.So(client.Unblock(&user, &follower), convey.ShouldBeNil)
+				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldBeNil)
+				convey.So(client.Unfollow(&user, &follower), convey.ShouldBeNil)
+			})
+		})
+
 		t.Run("CreateListing", func(t *testing.T) {
 			convey.Convey("If a user creates a listing we should get no errors\n", t, func() {
 				convey.So(client.CreateUserListing(&user, &forSale), convey.ShouldBeNil)
@@ -154,6 +224,31 @@ func TestE2E(t *testing.T) {
 			})
 		})
 
+		t.Run("ListingCounts", func(t *testing.T) {
+			convey.Convey("Listings should carry their like, repost and comment counts in the feed as on their own\n", t, func() {
+				convey.So(client.CreateFollowing(&user, &follower), convey.ShouldBeNil)
+				convey.So(client.Like(&follower, forSale.ID), convey.ShouldBeNil)
+				convey.So(client.Repost(&follower, forSale.ID), convey.ShouldBeNil)
+				convey.So(client.CreateComment(&follower, &types.Comment{ID: types.GenerateID(), ListingID: forSale.ID,
+					Body: "Nice", Created: time.Now()}), convey.ShouldBeNil)
+
+				listing, err := client.GetListing(forSale.ID, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So([]int64{listing.Likes, listing.Reposts, listing.Comments}, convey.ShouldResemble, []int64{1, 1, 1})
+
+				items, err := client.GetFeed(&follower, time.Now().Add(time.Hour), "", 10, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(items, convey.ShouldHaveLength, 1)
+				convey.So(items[0].Listing.ID, convey.ShouldEqual, forSale.ID)
+				convey.So([]int64{items[0].Listing.Likes, items[0].Listing.Reposts, items[0].Listing.Comments},
+					convey.ShouldResemble, []int64{1, 1, 1})
+
+				convey.So(client.Unlike(&follower, forSale.ID), convey.ShouldBeNil)
+				convey.So(client.Unrepost(&follower, forSale.ID), convey.ShouldBeNil)
+				convey.So(client.Unfollow(&user, &follower), convey.ShouldBeNil)
+			})
+		})
+
 		t.Run("BuyListing", func(t *testing.T) {
 			convey.Convey("If a user buys a listing then we should get no error\n", t, func() {
 				convey.So(client.Sold(&follower, &forSale), convey.ShouldBeNil)
@@ -164,6 +259,244 @@ func TestE2E(t *testing.T) {
 			})
 		})
 
+		t.Run("Purchase", func(t *testing.T) {
+			convey.Convey("Purchases should count the seller's sales in the same transaction\n", t, func() {
+				for i := int64(1); i <= 2; i++ {
+					created := time.Now()
+					listing := &types.Listing{ID: types.GenerateID(), Price: price, Created: &created}
+					convey.So(client.CreateUserListing(&user, listing), convey.ShouldBeNil)
+
+					tx := &types.Transaction{ID: types.GenerateID(), Buyer: &follower, Date: created, OriginalPrice: price,
+						FinalPrice: price}
+					sales, err := client.Purchase(listing, tx, nil)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(sales, convey.ShouldEqual, i)
+
+					_, err = client.Purchase(listing, &types.Transaction{ID: types.GenerateID(), Buyer: &follower,
+						Date: created, OriginalPrice: price, FinalPrice: price}, nil)
+					convey.So(err, convey.ShouldEqual, neo4j.ErrAlreadySold)
+				}
+			})
+		})
+
+		t.Run("TransactionVisibility", func(t *testing.T) {
+			convey.Convey("Only the buyer, the seller and staff should see the transaction of a sold listing\n", t, func() {
+				bystander := types.User{Username: "bystander", Password: "bystander123", Email: "bystander@gmail.com"}
+				convey.So(client.InsertUser(&bystander), convey.ShouldBeNil)
+
+				created := time.Now()
+				listing := &types.Listing{ID: types.GenerateID(), Price: price, Created: &created}
+				convey.So(client.CreateUserListing(&user, listing), convey.ShouldBeNil)
+				_, err := client.Purchase(listing, &types.Transaction{ID: types.GenerateID(), Buyer: &follower,
+					Date: created, OriginalPrice: price, FinalPrice: price}, nil)
+				convey.So(err, convey.ShouldBeNil)
+
+				for _, viewer := range []*types.User{nil, &bystander} {
+					seen, err := client.GetListing(listing.ID, viewer)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(seen.Sold, convey.ShouldBeTrue)
+					convey.So(seen.Tx, convey.ShouldBeNil)
+				}
+
+				staff := &types.User{Username: "staff", Roles: []types.Role{types.RoleAdmin}}
+				for _, viewer := range []*types.User{&follower, &user, staff} {
+					seen, err := client.GetListing(listing.ID, viewer)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(seen.Tx, convey.ShouldNotBeNil)
+					convey.So(seen.Tx.Buyer.Username, convey.ShouldEqual, follower.Username)
+				}
+			})
+		})
+
+		t.Run("RecordDownload", func(t *testing.T) {
+			convey.Convey("Download grants should count once and only for their own transaction\n", t, func() {
+				var txIDs []string
+				for i := 0; i < 2; i++ {
+					created := time.Now()
+					listing := &types.Listing{ID: types.GenerateID(), Price: price, Created: &created}
+					convey.So(client.CreateUserListing(&user, listing), convey.ShouldBeNil)
+					tx := &types.Transaction{ID: types.GenerateID(), Buyer: &follower, Date: created,
+						OriginalPrice: price, FinalPrice: price}
+					_, err := client.Purchase(listing, tx, nil)
+					convey.So(err, convey.ShouldBeNil)
+					txIDs = append(txIDs, tx.ID)
+				}
+
+				convey.So(client.RecordDownload(txIDs[0], "grant-1", 2), convey.ShouldBeNil)
+				convey.So(client.RecordDownload(txIDs[0], "grant-1", 2), convey.ShouldBeNil)
+				convey.So(client.RecordDownload(txIDs[1], "grant-1", 2), convey.ShouldEqual, neo4j.ErrLimitReached)
+				convey.So(client.RecordDownload(txIDs[0], "grant-2", 2), convey.ShouldBeNil)
+				convey.So(client.RecordDownload(txIDs[0], "grant-3", 2), convey.ShouldEqual, neo4j.ErrLimitReached)
+			})
+		})
+
+		t.Run("Outbox", func(t *testing.T) {
+			convey.Convey("Delivered and failed emails should be pruned once they are old enough\n", t, func() {
+				created := time.Now().Add(-time.Hour)
+				sent := &types.Email{ID: types.GenerateID(), To: user.Username, Category: types.EmailAccount,
+					Subject: "Reset your password", Text: "link", HTML: "<a>link</a>", Created: created}
+				failed := &types.Email{ID: types.GenerateID(), To: user.Username, Category: types.EmailAccount,
+					Subject: "Verify your email", Text: "link", HTML: "<a>link</a>", Created: created}
+				convey.So(client.EnqueueEmails(sent, failed), convey.ShouldBeNil)
+
+				convey.So(client.MarkEmailSent(sent.ID, time.Now()), convey.ShouldBeNil)
+				convey.So(client.MarkEmailFailed(failed.ID, 8, nil, "mailbox unavailable"), convey.ShouldBeNil)
+
+				pruned, err := client.PruneEmails(created.Add(-time.Minute))
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(pruned, convey.ShouldEqual, 0)
+
+				pruned, err = client.PruneEmails(time.Now())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(pruned, convey.ShouldBeGreaterThanOrEqualTo, 2)
+			})
+		})
+
+		t.Run("ResetPassword", func(t *testing.T) {
+			convey.Convey("Reset tokens should set the password once and only before they expire\n", t, func() {
+				now := time.Now()
+				convey.So(client.CreatePasswordReset(&user, "expiring", now.Add(time.Hour)), convey.ShouldBeNil)
+				_, err := client.ResetPassword("expiring", "expired-hash", now.Add(2*time.Hour))
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+				_, err = client.ResetPassword("expiring", "expired-hash", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+
+				convey.So(client.CreatePasswordReset(&user, "single-use", now.Add(time.Hour)), convey.ShouldBeNil)
+				reset, err := client.ResetPassword("single-use", "new-hash", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(reset.Username, convey.ShouldEqual, user.Username)
+				_, err = client.ResetPassword("single-use", "other-hash", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+
+				stored, err := client.GetUser(&types.User{Username: user.Username})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(stored.Password, convey.ShouldEqual, "new-hash")
+			})
+
+			convey.Convey("A new reset token should replace the pending one\n", t, func() {
+				now := time.Now()
+				convey.So(client.CreatePasswordReset(&user, "first", now.Add(time.Hour)), convey.ShouldBeNil)
+				convey.So(client.CreatePasswordReset(&user, "second", now.Add(time.Hour)), convey.ShouldBeNil)
+				_, err := client.ResetPassword("first", "first-hash", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+				_, err = client.ResetPassword("second", "second-hash", now)
+				convey.So(err, convey.ShouldBeNil)
+			})
+
+			convey.Convey("Setting a new password should revoke the user's API keys\n", t, func() {
+				now := time.Now()
+				for i, change := range []func() error{
+					func() error {
+						if err := client.CreatePasswordReset(&user, "revoking", now.Add(time.Hour)); err != nil {
+							return err
+						}
+						_, err := client.ResetPassword("revoking", "second-hash", now)
+						return err
+					},
+					func() error { return client.ChangePassword(&user, "second-hash") },
+				} {
+					key := &types.APIKey{ID: types.GenerateID(), Name: fmt.Sprintf("script-%d", i), Prefix: "mm_",
+						Scopes: []types.Scope{types.ScopeReadSales}, Created: now}
+					convey.So(client.CreateAPIKey(&user, key, "key-"+key.ID, 10), convey.ShouldBeNil)
+					owner, _, err := client.UseAPIKey("key-"+key.ID, now)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(owner, convey.ShouldNotBeNil)
+
+					convey.So(change(), convey.ShouldBeNil)
+					owner, _, err = client.UseAPIKey("key-"+key.ID, now)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(owner, convey.ShouldBeNil)
+				}
+			})
+
+			convey.Convey("Upgrading a password should only replace the value it was read as\n", t, func() {
+				convey.So(client.UpgradePassword(&user, "stale", "upgraded"), convey.ShouldBeNil)
+				stored, err := client.GetUser(&types.User{Username: user.Username})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(stored.Password, convey.ShouldEqual, "second-hash")
+
+				convey.So(client.UpgradePassword(&user, "second-hash", "upgraded"), convey.ShouldBeNil)
+				stored, err = client.GetUser(&types.User{Username: user.Username})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(stored.Password, convey.ShouldEqual, "upgraded")
+			})
+		})
+
+		t.Run("Notifications", func(t *testing.T) {
+			convey.Convey("Notifications should be delivered to their recipient and stay unread until marked read\n", t, func() {
+				now := time.Now().Truncate(time.Millisecond)
+				first := &types.Notification{ID: types.GenerateID(), Type: types.NotifyFollow,
+					Actor: &types.User{Username: follower.Username}, Created: now}
+				second := &types.Notification{ID: types.GenerateID(), Type: types.NotifySale,
+					Actor: &types.User{Username: follower.Username}, ListingID: forSale.ID, Created: now.Add(time.Second)}
+				convey.So(client.CreateNotification(&user, first), convey.ShouldBeNil)
+				convey.So(client.CreateNotification(&user, second), convey.ShouldBeNil)
+
+				notifications, err := client.ListNotifications(&user, now.Add(time.Hour), "", false, 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(notifications, convey.ShouldHaveLength, 2)
+				convey.So(notifications[0].ID, convey.ShouldEqual, second.ID)
+				convey.So(notifications[0].ListingID, convey.ShouldEqual, forSale.ID)
+				convey.So(notifications[0].Read, convey.ShouldBeFalse)
+				convey.So(notifications[1].ID, convey.ShouldEqual, first.ID)
+				convey.So(notifications[1].Actor.Username, convey.ShouldEqual, follower.Username)
+
+				since, err := client.GetNotificationsSince(&user, first.ID, 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(since, convey.ShouldHaveLength, 1)
+				convey.So(since[0].ID, convey.ShouldEqual, second.ID)
+
+				convey.So(client.MarkNotificationsRead(&user, []string{first.ID}), convey.ShouldBeNil)
+				unread, err := client.CountUnreadNotifications(&user)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(unread, convey.ShouldEqual, 1)
+
+				notifications, err = client.ListNotifications(&user, now.Add(time.Hour), "", true, 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(notifications, convey.ShouldHaveLength, 1)
+				convey.So(notifications[0].ID, convey.ShouldEqual, second.ID)
+
+				convey.So(client.MarkNotificationsRead(&user, nil), convey.ShouldBeNil)
+				unread, err = client.CountUnreadNotifications(&user)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(unread, convey.ShouldEqual, 0)
+			})
+
+			convey.Convey("Notifications by unknown users should not be stored\n", t, func() {
+				err := client.CreateNotification(&user, &types.Notification{ID: types.GenerateID(), Type: types.NotifyFollow,
+					Actor: &types.User{Username: "nobody"}, Created: time.Now()})
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+			})
+		})
+
+		t.Run("VerifyEmail", func(t *testing.T) {
+			convey.Convey("Verification tokens should verify the address once and only before they expire\n", t, func() {
+				now := time.Now()
+				convey.So(client.CreateVerification(&user, "expiring", now.Add(time.Hour)), convey.ShouldBeNil)
+				_, err := client.VerifyEmail("expiring", now.Add(2*time.Hour))
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+				_, err = client.VerifyEmail("expiring", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+
+				convey.So(client.CreateVerification(&user, "replaced", now.Add(time.Hour)), convey.ShouldBeNil)
+				convey.So(client.CreateVerification(&user, "single-use", now.Add(time.Hour)), convey.ShouldBeNil)
+				_, err = client.VerifyEmail("replaced", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+
+				verified, err := client.VerifyEmail("single-use", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(verified.Username, convey.ShouldEqual, user.Username)
+				convey.So(verified.Verified, convey.ShouldBeTrue)
+				_, err = client.VerifyEmail("single-use", now)
+				convey.So(err, convey.ShouldEqual, neo4j.ErrNotFound)
+			})
+
+			convey.Convey("Verified addresses should not get new tokens\n", t, func() {
+				err := client.CreateVerification(&user, "again", time.Now().Add(time.Hour))
+				convey.So(err, convey.ShouldEqual, neo4j.ErrAlreadyVerified)
+			})
+		})
+
 		t.Run("DeleteUser", func(t *testing.T) {
 			convey.Convey("If we try to delete a user we should get no error and the user should no longer exist in the DB\n", t, func() {
 				convey.So(client.DeleteUser(follower.Username, follower.Email), convey.ShouldBeNil)
@@ -172,7 +505,7 @@ func TestE2E(t *testing.T) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(usr, convey.ShouldBeNil)
 
-				followers, err := client.GetFollowers(&user)
+				followers, err := client.GetFollowers(&user, nil)
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(len(followers), convey.ShouldEqual, 0)
 			})
@@ -0,0 +1,136 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateComment adds the comment to its listing, as a reply if it has a parent. It returns ErrNotFound if the listing
+//does not exist or has been removed or the parent is not a comment on the same listing, and ErrBlocked if the author
+//is blocked from or by the seller or the author of the parent
+func (c *Client) CreateComment(author *types.User, comment *types.Comment) error {
+	c.log().Info("Creating comment", "comment_id", comment.ID, "author", author.Username,
+		"listing_id", comment.ListingID)
+
+	query := `MATCH (u:User { username: $username })
+		return EXISTS { MATCH (:Listing { id: $listing })<-[:SELLING]-(:User)-[:BLOCKED]-(u) } OR
+		EXISTS { MATCH (:Comment { id: $parent })<-[:WROTE]-(:User)-[:BLOCKED]-(u) }`
+	records, err := c.readTransaction("CreateComment", query, map[string]interface{}{
+		"username": author.Username,
+		"listing":  comment.ListingID,
+		"parent":   comment.ParentID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	} else if isBlocked, _ := records[0].Values[0].(bool); isBlocked {
+		return ErrBlocked
+	}
+
+	query = `MATCH (u:User { username: $username }), (l:Listing { id: $listing }) WHERE l.removed IS NULL
+		CREATE (u)-[:WROTE]->(c:Comment { id: $id, body: $body, created: $created })-[:ON]->(l) return c`
+	if comment.ParentID != "" {
+		query = `MATCH (u:User { username: $username }), (l:Listing { id: $listing })<-[:ON]-(parent:Comment { id: $parent })
+			WHERE l.removed IS NULL
+			CREATE (u)-[:WROTE]->(c:Comment { id: $id, body: $body, created: $created })-[:ON]->(l), (c)-[:REPLY_TO]->(parent) return c`
+	}
+
+	records, err = c.writeTransaction("CreateComment", query, map[string]interface{}{
+		"username": author.Username,
+		"listing":  comment.ListingID,
+		"parent":   comment.ParentID,
+		"id":       comment.ID,
+		"body":     comment.Body,
+		"created":  comment.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//DeleteComment deletes a comment on behalf of the user. Authors can delete their own comments and sellers can delete
+//any comment on their listings. Anyone else gets ErrForbidden unless moderation is given, in which case the comment is
+//deleted and the entry recorded in the audit log. The comment keeps its place in the thread so replies to it are not
+//lost
+func (c *Client) DeleteComment(user *types.User, id string, moderation *types.AuditEntry) error {
+	c.log().Info("Deleting comment", "comment_id", id, "username", user.Username)
+
+	_, err := c.write("DeleteComment", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (author:User)-[:WROTE]->(c:Comment { id: $id })-[:ON]->(l:Listing) WHERE c.deleted IS NULL
+			OPTIONAL MATCH (seller:User)-[:SELLING]->(l) return author.username, seller.username`
+		result, err := t.Run(query, map[string]interface{}{"id": id})
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := result.Single()
+		if err != nil {
+			return nil, ErrNotFound
+		}
+
+		owner := record.Values[0] == user.Username || record.Values[1] == user.Username
+		if !owner && moderation == nil {
+			return nil, ErrForbidden
+		}
+
+		query = `MATCH (c:Comment { id: $id }) SET c.deleted = true, c.deleted_by = $username REMOVE c.body`
+		_, err = t.Run(query, map[string]interface{}{"id": id, "username": user.Username})
+		if err != nil || owner {
+			return nil, err
+		}
+
+		return nil, audit(t, moderation)
+	})
+	return err
+}
+
+//GetComments retrieves up to limit comments on the listing, oldest first, starting after the comment with the given
+//creation time and ID. Comments by users blocked from or by the viewer are left out, as are all comments on listings
+//whose seller blocked the viewer
+func (c *Client) GetComments(listingID string, viewer *types.User, after time.Time, afterID string, limit int) ([]*types.Comment, error) {
+	query := `MATCH (author:User)-[:WROTE]->(c:Comment)-[:ON]->(l:Listing { id: $listing })
+		WHERE (c.created > $after OR (c.created = $after AND c.id > $id)) AND ` + notBlocked("author") + `
+		AND NOT EXISTS { MATCH (l)<-[:SELLING]-(seller:User) WHERE NOT ` + notBlockedBy("seller") + ` }
+		OPTIONAL MATCH (c)-[:REPLY_TO]->(parent:Comment)
+		return c, author.username, parent.id ORDER BY c.created, c.id LIMIT $limit`
+	records, err := c.readTransaction("GetComments", query, map[string]interface{}{
+		"listing": listingID,
+		"viewer":  viewerParam(viewer),
+		"after":   after,
+		"id":      afterID,
+		"limit":   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]*types.Comment, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		comment := &types.Comment{ListingID: listingID}
+		comment.ID, _ = node.Props["id"].(string)
+		comment.Created, _ = getTime(node.Props["created"])
+		comment.Deleted, _ = node.Props["deleted"].(bool)
+		comment.ParentID, _ = record.Values[2].(string)
+
+		if !comment.Deleted {
+			comment.Body, _ = node.Props["body"].(string)
+			comment.Author = &types.User{Username: record.Values[1].(string)}
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments, nil
+}
@@ -1,3 +1,33 @@
 CREATE CONSTRAINT unique_email IF NOT EXISTS for (user:User) require user.email IS Unique;
 CREATE CONSTRAINT unique_username IF NOT EXISTS for (user:User) require user.username IS UNIQUE;
-CREATE CONSTRAINT unique_listing_ID IF NOT EXISTS for (listing:Listing) require listing.ID IS UNIQUE;
\ No newline at end of file
+CREATE CONSTRAINT unique_listing_ID IF NOT EXISTS for (listing:Listing) require listing.ID IS UNIQUE;
+CREATE CONSTRAINT unique_discount_ID IF NOT EXISTS for (discount:Discount) require discount.id IS UNIQUE;
+CREATE CONSTRAINT unique_session_token IF NOT EXISTS for (session:Session) require session.token IS UNIQUE;
+CREATE INDEX feed_entry_time IF NOT EXISTS for (entry:FeedEntry) on (entry.time);
+CREATE CONSTRAINT unique_comment_ID IF NOT EXISTS for (comment:Comment) require comment.id IS UNIQUE;
+CREATE CONSTRAINT unique_conversation_ID IF NOT EXISTS for (conversation:Conversation) require conversation.id IS UNIQUE;
+CREATE CONSTRAINT unique_conversation_key IF NOT EXISTS for (conversation:Conversation) require conversation.key IS UNIQUE;
+CREATE CONSTRAINT unique_message_ID IF NOT EXISTS for (message:Message) require message.id IS UNIQUE;
+CREATE CONSTRAINT unique_notification_ID IF NOT EXISTS for (notification:Notification) require notification.id IS UNIQUE;
+CREATE CONSTRAINT unique_email_ID IF NOT EXISTS for (email:Email) require email.id IS UNIQUE;
+CREATE INDEX email_outbox IF NOT EXISTS for (email:Email) on (email.status, email.next_attempt);
+CREATE CONSTRAINT unique_verification_token IF NOT EXISTS for (verification:Verification) require verification.token IS UNIQUE;
+CREATE CONSTRAINT unique_password_reset_token IF NOT EXISTS for (reset:PasswordReset) require reset.token IS UNIQUE;
+CREATE CONSTRAINT unique_login_challenge_token IF NOT EXISTS for (challenge:LoginChallenge) require challenge.token IS UNIQUE;
+CREATE CONSTRAINT unique_login_throttle_key IF NOT EXISTS for (throttle:LoginThrottle) require throttle.key IS UNIQUE;
+CREATE CONSTRAINT unique_audit_entry_ID IF NOT EXISTS for (entry:AuditEntry) require entry.id IS UNIQUE;
+CREATE INDEX audit_entry_created IF NOT EXISTS for (entry:AuditEntry) on (entry.created);
+CREATE CONSTRAINT unique_api_key_token IF NOT EXISTS for (key:APIKey) require key.token IS UNIQUE;
+CREATE CONSTRAINT unique_oauth_client_ID IF NOT EXISTS for (client:OAuthClient) require client.id IS UNIQUE;
+CREATE CONSTRAINT unique_oauth_code_token IF NOT EXISTS for (code:OAuthCode) require code.token IS UNIQUE;
+CREATE CONSTRAINT unique_oauth_token IF NOT EXISTS for (token:OAuthToken) require token.token IS UNIQUE;
+CREATE INDEX oauth_token_grant IF NOT EXISTS for (token:OAuthToken) on (token.grant);
+CREATE CONSTRAINT unique_oauth_code_grant IF NOT EXISTS for (code:OAuthCode) require code.grant IS UNIQUE;
+CREATE CONSTRAINT unique_download_grant IF NOT EXISTS for (grant:DownloadGrant) require grant.id IS UNIQUE;
+// Dates used to be written as Go time strings, which neither sort nor compare with the DateTimes written since
+MATCH (l:Listing) WHERE l.date =~ '[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2}([.][0-9]+)? [+-][0-9]{4} .*' WITH l, split(l.date, ' ') AS parts SET l.date = datetime(parts[0] + 'T' + parts[1] + left(parts[2], 3) + ':' + right(parts[2], 2));
+MATCH ()-[b:BOUGHT]->() WHERE b.date =~ '[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2}([.][0-9]+)? [+-][0-9]{4} .*' WITH b, split(b.date, ' ') AS parts SET b.date = datetime(parts[0] + 'T' + parts[1] + left(parts[2], 3) + ':' + right(parts[2], 2));
+// Download grants used to be kept in a list on the transaction, where nothing kept them unique across transactions
+MATCH ()-[b:BOUGHT]->() WHERE b.grants IS NOT NULL UNWIND b.grants AS grant MERGE (g:DownloadGrant { id: grant }) ON CREATE SET g.transaction = b.id WITH DISTINCT b REMOVE b.grants;
+// Delivered emails used to keep their subject and bodies, which hold links such as password resets
+MATCH (e:Email) WHERE e.status IN ['sent', 'failed'] REMOVE e.subject, e.text, e.html;
@@ -0,0 +1,269 @@
+package neo4j
+
+import (
+	"github.com/danny-m08/music-match/discount"
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateDiscount creates a discount offered by the seller. If the discount is tied to a listing the seller must be the
+//one selling it, otherwise ErrNotFound is returned. Discount codes are unique per seller
+func (c *Client) CreateDiscount(seller *types.User, d *types.Discount) error {
+	c.log().Info("Creating discount", "discount_id", d.ID, "seller", seller.Username)
+
+	params := map[string]interface{}{
+		"seller":  seller.Username,
+		"listing": d.ListingID,
+		"code":    d.Code,
+		"props": map[string]interface{}{
+			"id":              d.ID,
+			"code":            optionalString(d.Code),
+			"type":            string(d.Type),
+			"value":           d.Value,
+			"max_uses":        d.MaxUses,
+			"per_buyer_limit": d.PerBuyerLimit,
+			"uses":            d.Uses,
+			"starts":          optionalTime(d.Starts),
+			"expires":         optionalTime(d.Expires),
+		},
+	}
+
+	if d.Code != "" {
+		taken, err := c.readTransaction("CreateDiscount", `MATCH (:User { username: $seller })-[:OFFERS]->(d:Discount { code: $code }) return d`, params)
+		if err != nil {
+			return err
+		}
+
+		if len(taken) > 0 {
+			return ErrAlreadyExists
+		}
+	}
+
+	query := `MATCH (u:User { username: $seller }) CREATE (u)-[:OFFERS]->(d:Discount $props) return d`
+	if d.ListingID != "" {
+		query = `MATCH (u:User { username: $seller })-[:SELLING]->(l:Listing { id: $listing }) CREATE (u)-[:OFFERS]->(d:Discount $props)-[:APPLIES_TO]->(l) return d`
+	}
+
+	records, err := c.writeTransaction("CreateDiscount", query, params)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetDiscounts retrieves every discount offered by the seller
+func (c *Client) GetDiscounts(seller *types.User) ([]*types.Discount, error) {
+	query := `MATCH (:User { username: $seller })-[:OFFERS]->(d:Discount) OPTIONAL MATCH (d)-[:APPLIES_TO]->(l:Listing) return d, l.id`
+	records, err := c.readTransaction("GetDiscounts", query, map[string]interface{}{"seller": seller.Username})
+	if err != nil {
+		return nil, err
+	}
+
+	discounts := make([]*types.Discount, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		discounts = append(discounts, getDiscount(&node, record.Values[1]))
+	}
+
+	return discounts, nil
+}
+
+//DeleteDiscount removes one of the seller's discounts. Transactions that already used it keep their discount details
+func (c *Client) DeleteDiscount(seller *types.User, id string) error {
+	query := `MATCH (:User { username: $seller })-[:OFFERS]->(d:Discount { id: $id }) DETACH DELETE d return count(*)`
+	records, err := c.writeTransaction("DeleteDiscount", query, map[string]interface{}{"seller": seller.Username, "id": id})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetDiscountByCode looks up a discount code offered by the listing's seller. It returns nil if the seller has no such
+//code
+func (c *Client) GetDiscountByCode(listing *types.Listing, code string) (*types.Discount, error) {
+	query := `MATCH (:Listing { id: $listing })<-[:SELLING]-(:User)-[:OFFERS]->(d:Discount { code: $code }) OPTIONAL MATCH (d)-[:APPLIES_TO]->(l:Listing) return d, l.id`
+	records, err := c.readTransaction("GetDiscountByCode", query, map[string]interface{}{"listing": listing.ID, "code": code})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	return getDiscount(&node, records[0].Values[1]), nil
+}
+
+//GetSales retrieves the scheduled sales the listing's seller runs that cover the listing, whether they are active or
+//not
+func (c *Client) GetSales(listing *types.Listing) ([]*types.Discount, error) {
+	query := `MATCH (listing:Listing { id: $listing })<-[:SELLING]-(:User)-[:OFFERS]->(d:Discount) WHERE d.code IS NULL
+		OPTIONAL MATCH (d)-[:APPLIES_TO]->(l:Listing) WITH d, l, listing WHERE l IS NULL OR l = listing return d, l.id`
+	records, err := c.readTransaction("GetSales", query, map[string]interface{}{"listing": listing.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	sales := make([]*types.Discount, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		sales = append(sales, getDiscount(&node, record.Values[1]))
+	}
+
+	return sales, nil
+}
+
+//CountDiscountUses returns how many purchases the buyer has made with the given discount
+func (c *Client) CountDiscountUses(buyer *types.User, discountID string) (int64, error) {
+	query := `MATCH (:User { username: $buyer })-[b:BOUGHT { discount_id: $discount }]->(:Listing) return count(b)`
+	records, err := c.readTransaction("CountDiscountUses", query, map[string]interface{}{"buyer": buyer.Username, "discount": discountID})
+	if err != nil {
+		return 0, err
+	}
+
+	return records[0].Values[0].(int64), nil
+}
+
+//Purchase records the sale of the listing to the transaction's buyer and returns how many sales its seller has made,
+//this one included. When a discount is used its use count is incremented in the same transaction and its usage limits
+//are checked again, so concurrent checkouts can neither buy the same listing twice nor redeem a discount more often
+//than allowed. Listings removed since they were read cannot be bought and are reported as not found, and ErrBlocked is
+//returned if the buyer and seller have blocked each other in either direction. The emails about the sale are queued in
+//the same transaction
+func (c *Client) Purchase(listing *types.Listing, tx *types.Transaction, d *types.Discount, emails ...*types.Email) (int64, error) {
+	c.log().Info("Recording purchase", "transaction_id", tx.ID, "listing_id", listing.ID, "buyer", tx.Buyer.Username)
+
+	sales, err := c.write("Purchase",
+		func(t neo4j.Transaction) (interface{}, error) {
+			// Writing to the listing first locks it until this transaction commits
+			result, err := t.Run(`MATCH (l:Listing { id: $listing }) WHERE l.removed IS NULL SET l.sold = true return l`,
+				map[string]interface{}{"listing": listing.ID})
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err = result.Single(); err != nil {
+				return nil, ErrNotFound
+			}
+
+			result, err = t.Run(`MATCH ()-[b:BOUGHT]->(:Listing { id: $listing }) return count(b)`, map[string]interface{}{"listing": listing.ID})
+			if err != nil {
+				return nil, err
+			}
+
+			record, err := result.Single()
+			if err != nil {
+				return nil, err
+			}
+
+			if record.Values[0].(int64) > 0 {
+				return nil, ErrAlreadySold
+			}
+
+			result, err = t.Run(`MATCH (:Listing { id: $listing })<-[:SELLING]-(:User)-[:BLOCKED]-(:User { username: $buyer })
+				return count(*)`, map[string]interface{}{"listing": listing.ID, "buyer": tx.Buyer.Username})
+			if err != nil {
+				return nil, err
+			}
+
+			record, err = result.Single()
+			if err != nil {
+				return nil, err
+			}
+
+			if record.Values[0].(int64) > 0 {
+				return nil, ErrBlocked
+			}
+
+			if d != nil {
+				err = redeemDiscount(t, tx.Buyer, d)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			query := `MATCH (buyer:User { username: $buyer }), (l:Listing { id: $listing }) CREATE (buyer)-[b:BOUGHT $props]->(l) return b`
+			result, err = t.Run(query, map[string]interface{}{
+				"buyer":   tx.Buyer.Username,
+				"listing": listing.ID,
+				"props":   transactionProps(tx),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err = result.Single(); err != nil {
+				return nil, ErrNotFound
+			}
+
+			// Writing to the seller makes concurrent sales of their listings take turns, so every sale sees a
+			// different count
+			result, err = t.Run(`MATCH (seller:User)-[:SELLING]->(:Listing { id: $listing }) SET seller.last_sale = $date
+				WITH seller MATCH (seller)-[:SELLING]->(:Listing)<-[b:BOUGHT]-(:User) return count(b)`,
+				map[string]interface{}{"listing": listing.ID, "date": tx.Date})
+			if err != nil {
+				return nil, err
+			}
+
+			record, err = result.Single()
+			if err != nil {
+				return nil, err
+			}
+
+			return record.Values[0].(int64), enqueueEmails(t, emails)
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	return sales.(int64), nil
+}
+
+//redeemDiscount increments the use count of the discount and checks its usage limits inside the purchase transaction
+func redeemDiscount(t neo4j.Transaction, buyer *types.User, d *types.Discount) error {
+	result, err := t.Run(`MATCH (d:Discount { id: $id }) SET d.uses = d.uses + 1 return d.uses`, map[string]interface{}{"id": d.ID})
+	if err != nil {
+		return err
+	}
+
+	record, err := result.Single()
+	if err != nil {
+		return ErrNotFound
+	}
+
+	if d.MaxUses > 0 && record.Values[0].(int64) > d.MaxUses {
+		return discount.ErrUsedUp
+	}
+
+	if d.PerBuyerLimit == 0 {
+		return nil
+	}
+
+	result, err = t.Run(`MATCH (:User { username: $buyer })-[b:BOUGHT { discount_id: $id }]->(:Listing) return count(b)`,
+		map[string]interface{}{"buyer": buyer.Username, "id": d.ID})
+	if err != nil {
+		return err
+	}
+
+	record, err = result.Single()
+	if err != nil {
+		return err
+	}
+
+	if record.Values[0].(int64) >= d.PerBuyerLimit {
+		return discount.ErrBuyerLimit
+	}
+
+	return nil
+}
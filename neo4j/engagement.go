@@ -0,0 +1,117 @@
+package neo4j
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	liked    = "LIKED"
+	reposted = "REPOSTED"
+)
+
+//Like records that the user likes the listing. It returns ErrAlreadyExists if they already do
+func (c *Client) Like(user *types.User, listingID string) error {
+	return c.react("Like", liked, user, listingID)
+}
+
+//Unlike removes the user's like from the listing
+func (c *Client) Unlike(user *types.User, listingID string) error {
+	return c.unreact("Unlike", liked, user, listingID)
+}
+
+//ListLikes retrieves up to limit users who like the listing ordered by username, starting after the given username
+func (c *Client) ListLikes(listingID string, viewer *types.User, after string, limit int) ([]*types.User, error) {
+	return c.listReactions("ListLikes", liked, listingID, viewer, after, limit)
+}
+
+//Repost shares the listing with the user's followers. It returns ErrAlreadyExists if they already reposted it
+func (c *Client) Repost(user *types.User, listingID string) error {
+	return c.react("Repost", reposted, user, listingID)
+}
+
+//Unrepost removes the user's repost of the listing
+func (c *Client) Unrepost(user *types.User, listingID string) error {
+	return c.unreact("Unrepost", reposted, user, listingID)
+}
+
+//ListReposts retrieves up to limit users who reposted the listing ordered by username, starting after the given
+//username
+func (c *Client) ListReposts(listingID string, viewer *types.User, after string, limit int) ([]*types.User, error) {
+	return c.listReactions("ListReposts", reposted, listingID, viewer, after, limit)
+}
+
+//react creates a relationship of the given type from the user to the listing. Removed listings and those whose seller
+//is blocked from or by the user cannot be reacted to and are reported as not found
+func (c *Client) react(op, relType string, user *types.User, listingID string) error {
+	c.log().Info("Creating relationship", "relationship", relType, "username", user.Username, "listing_id", listingID)
+
+	now := time.Now()
+	query := fmt.Sprintf(`MATCH (u:User { username: $viewer }), (l:Listing { id: $listing })
+		WHERE l.removed IS NULL AND NOT EXISTS { MATCH (l)<-[:SELLING]-(:User)-[:BLOCKED]-(u) }
+		MERGE (u)-[r:%s]->(l) ON CREATE SET r.created = $now return r.created = $now`, relType)
+	records, err := c.writeTransaction(op, query, map[string]interface{}{
+		"viewer":  user.Username,
+		"listing": listingID,
+		"now":     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	if created, _ := records[0].Values[0].(bool); !created {
+		return ErrAlreadyExists
+	}
+
+	return nil
+}
+
+func (c *Client) unreact(op, relType string, user *types.User, listingID string) error {
+	c.log().Info("Removing relationship", "relationship", relType, "username", user.Username, "listing_id", listingID)
+
+	query := fmt.Sprintf(`MATCH (:User { username: $username })-[r:%s]->(:Listing { id: $listing }) DELETE r return count(*)`, relType)
+	records, err := c.writeTransaction(op, query, map[string]interface{}{"username": user.Username, "listing": listingID})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 || records[0].Values[0].(int64) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (c *Client) listReactions(op, relType, listingID string, viewer *types.User, after string, limit int) ([]*types.User, error) {
+	query := fmt.Sprintf(`MATCH (u:User)-[:%s]->(:Listing { id: $listing }) WHERE u.username > $after AND %s
+		return u ORDER BY u.username LIMIT $limit`, relType, notBlocked("u"))
+	records, err := c.readTransaction(op, query, map[string]interface{}{
+		"listing": listingID,
+		"viewer":  viewerParam(viewer),
+		"after":   after,
+		"limit":   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*types.User, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		user, err := getUser(&node, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &types.User{Username: user.Username})
+	}
+
+	return users, nil
+}
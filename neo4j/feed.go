@@ -0,0 +1,145 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//GetFeed builds a page of the user's feed from the activity of the users they follow: their new listings, reposts
+//and the sale milestones they reached. Items are ordered newest first and the page starts after the item with the
+//given time and ID. Muted users and reposts of listings whose seller blocked the user are left out
+func (c *Client) GetFeed(user *types.User, before time.Time, beforeID string, limit int, milestones []int64) ([]*types.FeedItem, error) {
+	query := `MATCH (me:User { username: $viewer })-[:FOLLOWS]->(actor:User)
+		WHERE NOT EXISTS { MATCH (me)-[:MUTED]->(actor) } AND ` + notBlocked("actor") + `
+		CALL {
+			WITH actor
+			MATCH (actor)-[:SELLING]->(l:Listing) WHERE l.removed IS NULL
+			return 'listing' AS type, l AS listing, l.date AS time, 'listing:' + l.id AS id, null AS milestone
+			UNION
+			WITH actor
+			MATCH (actor)-[r:REPOSTED]->(l:Listing) WHERE l.removed IS NULL AND NOT EXISTS { MATCH (l)<-[:SELLING]-(seller:User) WHERE NOT ` + notBlockedBy("seller") + ` }
+			return 'repost' AS type, l AS listing, r.created AS time, 'repost:' + actor.username + ':' + l.id AS id, null AS milestone
+			UNION
+			WITH actor
+			MATCH (actor)-[:SELLING]->(:Listing)<-[b:BOUGHT]-(:User)
+			WITH actor, b.date AS date ORDER BY date
+			WITH actor, collect(date) AS dates
+			UNWIND $milestones AS m
+			WITH actor, m, dates WHERE size(dates) >= m
+			return 'milestone' AS type, null AS listing, dates[m - 1] AS time, 'milestone:' + actor.username + ':' + toString(m) AS id, m AS milestone
+		}
+		WITH actor, type, listing, time, id, milestone WHERE time < $before OR (time = $before AND id < $id)
+		return type, actor, listing, time, id, milestone, ` + listingCounts("listing") + `
+		ORDER BY time DESC, id DESC LIMIT $limit`
+
+	records, err := c.readTransaction("GetFeed", query, map[string]interface{}{
+		"viewer":     user.Username,
+		"before":     before,
+		"id":         beforeID,
+		"limit":      limit,
+		"milestones": milestones,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getFeedItems(records)
+}
+
+//GetFeedEntries retrieves a page of the feed entries fanned out to the user by FanOut, newest first, starting after
+//the item with the given time and ID. Entries by muted users and about listings whose seller blocked the user are
+//left out
+func (c *Client) GetFeedEntries(user *types.User, before time.Time, beforeID string, limit int) ([]*types.FeedItem, error) {
+	query := `MATCH (me:User { username: $viewer })<-[:FOR]-(f:FeedEntry)-[:BY]->(actor:User)
+		WHERE (f.time < $before OR (f.time = $before AND f.id < $id)) AND NOT EXISTS { MATCH (me)-[:MUTED]->(actor) }
+		AND ` + notBlocked("actor") + `
+		OPTIONAL MATCH (f)-[:ABOUT]->(listing:Listing)
+		WITH f, actor, listing WHERE listing IS NULL OR NOT EXISTS { MATCH (listing)<-[:SELLING]-(seller:User) WHERE NOT ` + notBlockedBy("seller") + ` }
+		return f.type, actor, listing, f.time, f.id, f.milestone, ` + listingCounts("listing") + `
+		ORDER BY f.time DESC, f.id DESC LIMIT $limit`
+
+	records, err := c.readTransaction("GetFeedEntries", query, map[string]interface{}{
+		"viewer": user.Username,
+		"before": before,
+		"id":     beforeID,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getFeedItems(records)
+}
+
+//FanOut copies the item into the feed of every follower of its actor
+func (c *Client) FanOut(item *types.FeedItem) error {
+	c.log().Info("Fanning out feed item", "item_type", item.Type, "item_id", item.ID, "actor", item.Actor.Username)
+
+	query := `MATCH (actor:User { username: $actor })<-[:FOLLOWS]-(follower:User)
+		CREATE (follower)<-[:FOR]-(f:FeedEntry { id: $id, type: $type, time: $time, milestone: $milestone })-[:BY]->(actor)
+		WITH f
+		MATCH (l:Listing { id: $listing })
+		CREATE (f)-[:ABOUT]->(l)`
+	if item.Listing == nil {
+		query = `MATCH (actor:User { username: $actor })<-[:FOLLOWS]-(follower:User)
+			CREATE (follower)<-[:FOR]-(f:FeedEntry { id: $id, type: $type, time: $time, milestone: $milestone })-[:BY]->(actor)`
+	}
+
+	params := map[string]interface{}{
+		"actor":     item.Actor.Username,
+		"id":        item.ID,
+		"type":      string(item.Type),
+		"time":      item.Time,
+		"milestone": nil,
+		"listing":   nil,
+	}
+	if item.Milestone > 0 {
+		params["milestone"] = item.Milestone
+	}
+	if item.Listing != nil {
+		params["listing"] = item.Listing.ID
+	}
+
+	_, err := c.writeTransaction("FanOut", query, params)
+	return err
+}
+
+//PruneFeedEntries deletes the feed entries of events that happened before the given time
+func (c *Client) PruneFeedEntries(before time.Time) error {
+	query := `MATCH (f:FeedEntry) WHERE f.time < $before DETACH DELETE f`
+	_, err := c.writeTransaction("PruneFeedEntries", query, map[string]interface{}{"before": before})
+	return err
+}
+
+func getFeedItems(records []*neo4j.Record) ([]*types.FeedItem, error) {
+	items := make([]*types.FeedItem, 0, len(records))
+	for _, record := range records {
+		actorNode := record.Values[1].(neo4j.Node)
+		actor, err := getUser(&actorNode, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		item := &types.FeedItem{
+			Type:  types.FeedItemType(record.Values[0].(string)),
+			Actor: &types.User{Username: actor.Username},
+		}
+		item.Time, _ = getTime(record.Values[3])
+		item.ID, _ = record.Values[4].(string)
+		item.Milestone, _ = record.Values[5].(int64)
+
+		if node, ok := record.Values[2].(neo4j.Node); ok {
+			item.Listing, err = getListing(&node)
+			if err != nil {
+				return nil, err
+			}
+			getCounts(item.Listing, record.Values[6:])
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
@@ -0,0 +1,77 @@
+package neo4j
+
+import (
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//ListFollowers retrieves up to limit followers of the user ordered by username, starting after the given username.
+//Users who are blocked from or by the viewer are left out
+func (c *Client) ListFollowers(user, viewer *types.User, after string, limit int) ([]*types.Follow, error) {
+	query := `MATCH (other:User)-[f:FOLLOWS]->(user:User { username: $username }) WHERE other.username > $after AND ` + notBlocked("other") + `
+		return other, f.created, EXISTS { MATCH (user)-[:FOLLOWS]->(other) } ORDER BY other.username LIMIT $limit`
+	return c.listFollows("ListFollowers", query, user, viewer, after, limit)
+}
+
+//ListFollowing retrieves up to limit users the user follows ordered by username, starting after the given username.
+//Users who are blocked from or by the viewer are left out
+func (c *Client) ListFollowing(user, viewer *types.User, after string, limit int) ([]*types.Follow, error) {
+	query := `MATCH (user:User { username: $username })-[f:FOLLOWS]->(other:User) WHERE other.username > $after AND ` + notBlocked("other") + `
+		return other, f.created, EXISTS { MATCH (other)-[:FOLLOWS]->(user) } ORDER BY other.username LIMIT $limit`
+	return c.listFollows("ListFollowing", query, user, viewer, after, limit)
+}
+
+func (c *Client) listFollows(op, query string, user, viewer *types.User, after string, limit int) ([]*types.Follow, error) {
+	records, err := c.readTransaction(op, query, map[string]interface{}{
+		"username": user.Username,
+		"viewer":   viewerParam(viewer),
+		"after":    after,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	follows := make([]*types.Follow, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		other, err := getUser(&node, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		follow := &types.Follow{
+			User:   &types.User{Username: other.Username},
+			Mutual: record.Values[2].(bool),
+		}
+		if since, ok := getTime(record.Values[1]); ok {
+			follow.Since = &since
+		}
+
+		follows = append(follows, follow)
+	}
+
+	return follows, nil
+}
+
+//GetFollowCounts returns how many followers the user has and how many users they follow, leaving out users who are
+//blocked from or by the viewer. It returns ErrNotFound if the user does not exist or they and the viewer have blocked
+//each other
+func (c *Client) GetFollowCounts(user, viewer *types.User) (int64, int64, error) {
+	query := `MATCH (user:User { username: $username }) WHERE ` + notBlocked("user") + `
+		return size([(user)<-[:FOLLOWS]-(other:User) WHERE ` + notBlocked("other") + ` | 1]),
+		size([(user)-[:FOLLOWS]->(other:User) WHERE ` + notBlocked("other") + ` | 1])`
+	records, err := c.readTransaction("GetFollowCounts", query, map[string]interface{}{
+		"username": user.Username,
+		"viewer":   viewerParam(viewer),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(records) == 0 {
+		return 0, 0, ErrNotFound
+	}
+
+	return records[0].Values[0].(int64), records[0].Values[1].(int64), nil
+}
@@ -0,0 +1,275 @@
+package neo4j
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateConversation starts a conversation between the creator and the other participants. A 1:1 conversation that
+//already exists between the two users is returned instead of creating a new one. It returns ErrNotFound if any of
+//the participants does not exist and ErrBlocked if any two of them have blocked each other
+func (c *Client) CreateConversation(creator *types.User, others []*types.User, group bool) (*types.Conversation, error) {
+	usernames := []string{creator.Username}
+	for _, other := range others {
+		usernames = append(usernames, other.Username)
+	}
+	sort.Strings(usernames)
+	c.log().Info("Creating conversation", "usernames", usernames)
+
+	query := `MATCH (u:User) WHERE u.username IN $usernames
+		return count(u), EXISTS { MATCH (a:User)-[:BLOCKED]-(b:User) WHERE a.username IN $usernames AND b.username IN $usernames }`
+	params := map[string]interface{}{
+		"usernames": usernames,
+		"id":        types.GenerateID(),
+		"key":       strings.Join(usernames, "|"),
+		"now":       time.Now(),
+	}
+
+	records, err := c.readTransaction("CreateConversation", query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if records[0].Values[0].(int64) != int64(len(usernames)) {
+		return nil, ErrNotFound
+	} else if isBlocked, _ := records[0].Values[1].(bool); isBlocked {
+		return nil, ErrBlocked
+	}
+
+	query = `MERGE (c:Conversation { key: $key }) ON CREATE SET c.id = $id, c.group = false, c.created = $now, c.updated = $now`
+	if group {
+		query = `CREATE (c:Conversation { id: $id, group: true, created: $now, updated: $now })`
+	}
+	query += ` WITH c UNWIND $usernames AS username
+		MATCH (u:User { username: username }) MERGE (u)-[p:PARTICIPATES]->(c) ON CREATE SET p.joined = $now
+		return DISTINCT c.id`
+
+	records, err = c.writeTransaction("CreateConversation", query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetConversation(creator, records[0].Values[0].(string))
+}
+
+//GetConversation retrieves a conversation the user participates in. It returns ErrNotFound if there is no such
+//conversation or the user is not part of it
+func (c *Client) GetConversation(user *types.User, id string) (*types.Conversation, error) {
+	query := `MATCH (:User { username: $username })-[me:PARTICIPATES]->(c:Conversation { id: $id })` + conversationReturn
+	records, err := c.readTransaction("GetConversation", query, map[string]interface{}{"username": user.Username, "id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return getConversation(records[0]), nil
+}
+
+//ListConversations retrieves up to limit of the user's conversations, most recently active first, starting after the
+//conversation with the given update time and ID
+func (c *Client) ListConversations(user *types.User, before time.Time, beforeID string, limit int) ([]*types.Conversation, error) {
+	query := `MATCH (:User { username: $username })-[me:PARTICIPATES]->(c:Conversation)
+		WHERE c.updated < $before OR (c.updated = $before AND c.id < $id)
+		WITH me, c ORDER BY c.updated DESC, c.id DESC LIMIT $limit` + conversationReturn + ` ORDER BY c.updated DESC, c.id DESC`
+	records, err := c.readTransaction("ListConversations", query, map[string]interface{}{
+		"username": user.Username,
+		"before":   before,
+		"id":       beforeID,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conversations := make([]*types.Conversation, 0, len(records))
+	for _, record := range records {
+		conversations = append(conversations, getConversation(record))
+	}
+
+	return conversations, nil
+}
+
+//conversationReturn completes a query that bound a conversation to c and the requesting user's participation to me
+const conversationReturn = `
+	MATCH (p:User)-[:PARTICIPATES]->(c)
+	WITH me, c, collect(p.username) AS participants
+	return c, participants, size([(m:Message)-[:IN]->(c) WHERE me.last_read IS NULL OR m.created > me.last_read | 1])`
+
+func getConversation(record *neo4j.Record) *types.Conversation {
+	node := record.Values[0].(neo4j.Node)
+	conversation := &types.Conversation{}
+	conversation.ID, _ = node.Props["id"].(string)
+	conversation.Group, _ = node.Props["group"].(bool)
+	conversation.Created, _ = getTime(node.Props["created"])
+	conversation.Updated, _ = getTime(node.Props["updated"])
+	conversation.Unread, _ = record.Values[2].(int64)
+
+	for _, username := range record.Values[1].([]interface{}) {
+		conversation.Participants = append(conversation.Participants, &types.User{Username: username.(string)})
+	}
+
+	return conversation
+}
+
+//SendMessage adds the message to its conversation and marks the conversation read for the sender. It returns
+//ErrNotFound if the sender is not part of the conversation or an attachment references a listing they cannot see,
+//and ErrBlocked if the sender and another participant have blocked each other
+func (c *Client) SendMessage(sender *types.User, message *types.Message) error {
+	c.log().Info("Sending message", "message_id", message.ID, "sender", sender.Username,
+		"conversation_id", message.ConversationID)
+
+	listingIDs := make([]string, 0, len(message.Attachments))
+	attachments := make([]map[string]interface{}, 0, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		listingIDs = append(listingIDs, attachment.ListingID)
+		attachments = append(attachments, map[string]interface{}{
+			"type":    string(attachment.Type),
+			"listing": attachment.ListingID,
+		})
+	}
+
+	params := map[string]interface{}{
+		"viewer":       sender.Username,
+		"conversation": message.ConversationID,
+		"listings":     listingIDs,
+		"attachments":  attachments,
+		"id":           message.ID,
+		"body":         message.Body,
+		"created":      message.Created,
+	}
+
+	query := `MATCH (s:User { username: $viewer })-[:PARTICIPATES]->(c:Conversation { id: $conversation })
+		return EXISTS { MATCH (s)-[:BLOCKED]-(:User)-[:PARTICIPATES]->(c) },
+		size([(l:Listing) WHERE l.id IN $listings AND l.removed IS NULL AND NOT EXISTS { MATCH (l)<-[:SELLING]-(seller:User) WHERE NOT ` + notBlockedBy("seller") + ` } | l.id])`
+	records, err := c.readTransaction("SendMessage", query, params)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	} else if isBlocked, _ := records[0].Values[0].(bool); isBlocked {
+		return ErrBlocked
+	} else if records[0].Values[1].(int64) != int64(len(uniqueStrings(listingIDs))) {
+		return ErrNotFound
+	}
+
+	query = `MATCH (s:User { username: $viewer })-[p:PARTICIPATES]->(c:Conversation { id: $conversation })
+		CREATE (s)-[:SENT]->(m:Message { id: $id, body: $body, created: $created })-[:IN]->(c)
+		SET c.updated = $created, p.last_read = $created
+		WITH m UNWIND $attachments AS attachment
+		MATCH (l:Listing { id: attachment.listing })
+		CREATE (m)-[:ATTACHES { type: attachment.type }]->(l)
+		return l`
+	records, err = c.writeTransaction("SendMessage", query, params)
+	if err != nil {
+		return err
+	}
+
+	for i, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		if message.Attachments[i].Type == types.AttachTrack {
+			if name, ok := node.Props["track"].(string); ok {
+				message.Attachments[i].Track = &types.Track{Name: name}
+			}
+		}
+	}
+
+	message.ReadBy = []string{sender.Username}
+	return nil
+}
+
+//GetMessages retrieves up to limit messages of a conversation, newest first, starting after the message with the
+//given creation time and ID. The caller must make sure the user participates in the conversation
+func (c *Client) GetMessages(conversationID string, before time.Time, beforeID string, limit int) ([]*types.Message, error) {
+	query := `MATCH (sender:User)-[:SENT]->(m:Message)-[:IN]->(c:Conversation { id: $conversation })
+		WHERE m.created < $before OR (m.created = $before AND m.id < $id)
+		WITH c, sender, m ORDER BY m.created DESC, m.id DESC LIMIT $limit
+		OPTIONAL MATCH (m)-[a:ATTACHES]->(l:Listing)
+		WITH c, sender, m, collect(CASE WHEN l IS NULL THEN null ELSE { type: a.type, listing: l.id, track: l.track } END) AS attachments
+		return m, sender.username, attachments, [(p:User)-[r:PARTICIPATES]->(c) WHERE r.last_read >= m.created | p.username]
+		ORDER BY m.created DESC, m.id DESC`
+	records, err := c.readTransaction("GetMessages", query, map[string]interface{}{
+		"conversation": conversationID,
+		"before":       before,
+		"id":           beforeID,
+		"limit":        limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*types.Message, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		message := &types.Message{
+			ConversationID: conversationID,
+			Sender:         &types.User{Username: record.Values[1].(string)},
+			ReadBy:         []string{},
+		}
+		message.ID, _ = node.Props["id"].(string)
+		message.Body, _ = node.Props["body"].(string)
+		message.Created, _ = getTime(node.Props["created"])
+
+		for _, value := range record.Values[2].([]interface{}) {
+			props := value.(map[string]interface{})
+			attachment := &types.Attachment{ListingID: props["listing"].(string)}
+			if t, ok := props["type"].(string); ok {
+				attachment.Type = types.AttachmentType(t)
+			}
+			if name, ok := props["track"].(string); ok && attachment.Type == types.AttachTrack {
+				attachment.Track = &types.Track{Name: name}
+			}
+			message.Attachments = append(message.Attachments, attachment)
+		}
+
+		for _, username := range record.Values[3].([]interface{}) {
+			message.ReadBy = append(message.ReadBy, username.(string))
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+//MarkRead records that the user has read the conversation up to the given time. Read receipts never move backwards
+func (c *Client) MarkRead(user *types.User, conversationID string, read time.Time) error {
+	query := `MATCH (:User { username: $username })-[p:PARTICIPATES]->(:Conversation { id: $conversation })
+		SET p.last_read = CASE WHEN p.last_read IS NULL OR p.last_read < $read THEN $read ELSE p.last_read END
+		return p`
+	records, err := c.writeTransaction("MarkRead", query, map[string]interface{}{
+		"username":     user.Username,
+		"conversation": conversationID,
+		"read":         read,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,159 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateNotification stores the notification for the recipient. The notification is linked to its listing if it has
+//one. It returns ErrNotFound if the recipient or the actor does not exist
+func (c *Client) CreateNotification(recipient *types.User, n *types.Notification) error {
+	c.log().Info("Creating notification", "recipient", recipient.Username, "notification_type", n.Type,
+		"actor", n.Actor.Username)
+
+	query := `MATCH (recipient:User { username: $recipient }), (actor:User { username: $actor })
+		CREATE (recipient)<-[:FOR]-(n:Notification { id: $id, type: $type, comment: $comment, tx: $tx, read: false, created: $created })-[:BY]->(actor)
+		WITH n OPTIONAL MATCH (l:Listing { id: $listing })
+		FOREACH (listing IN CASE WHEN l IS NULL THEN [] ELSE [l] END | CREATE (n)-[:ABOUT]->(listing))
+		return n.id`
+	records, err := c.writeTransaction("CreateNotification", query, map[string]interface{}{
+		"recipient": recipient.Username,
+		"actor":     n.Actor.Username,
+		"id":        n.ID,
+		"type":      string(n.Type),
+		"listing":   n.ListingID,
+		"comment":   optionalString(n.CommentID),
+		"tx":        optionalString(n.TransactionID),
+		"created":   n.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//ListNotifications retrieves up to limit of the user's notifications, newest first, starting after the notification
+//with the given creation time and ID. Notifications by users blocked from or by the user are left out
+func (c *Client) ListNotifications(user *types.User, before time.Time, beforeID string, unreadOnly bool, limit int) ([]*types.Notification, error) {
+	query := `MATCH (:User { username: $viewer })<-[:FOR]-(n:Notification)-[:BY]->(actor:User)
+		WHERE (n.created < $before OR (n.created = $before AND n.id < $id)) AND (NOT $unread OR NOT n.read)
+		AND ` + notBlocked("actor") + notificationReturn + ` ORDER BY n.created DESC, n.id DESC LIMIT $limit`
+	records, err := c.readTransaction("ListNotifications", query, map[string]interface{}{
+		"viewer": user.Username,
+		"before": before,
+		"id":     beforeID,
+		"unread": unreadOnly,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getNotifications(records), nil
+}
+
+//GetNotificationsSince retrieves up to limit of the user's notifications created after the one with the given ID,
+//oldest first. Nothing is returned if the user has no notification with that ID
+func (c *Client) GetNotificationsSince(user *types.User, lastID string, limit int) ([]*types.Notification, error) {
+	query := `MATCH (me:User { username: $viewer })<-[:FOR]-(last:Notification { id: $id })
+		MATCH (me)<-[:FOR]-(n:Notification)-[:BY]->(actor:User)
+		WHERE (n.created > last.created OR (n.created = last.created AND n.id > last.id)) AND ` + notBlocked("actor") +
+		notificationReturn + ` ORDER BY n.created, n.id LIMIT $limit`
+	records, err := c.readTransaction("GetNotificationsSince", query, map[string]interface{}{
+		"viewer": user.Username,
+		"id":     lastID,
+		"limit":  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getNotifications(records), nil
+}
+
+//CountUnreadNotifications counts the user's unread notifications
+func (c *Client) CountUnreadNotifications(user *types.User) (int64, error) {
+	query := `MATCH (:User { username: $viewer })<-[:FOR]-(n:Notification { read: false })-[:BY]->(actor:User)
+		WHERE ` + notBlocked("actor") + ` return count(n)`
+	records, err := c.readTransaction("CountUnreadNotifications", query, map[string]interface{}{"viewer": user.Username})
+	if err != nil {
+		return 0, err
+	}
+
+	return records[0].Values[0].(int64), nil
+}
+
+//MarkNotificationsRead marks the user's notifications with the given IDs as read, or all of them if ids is nil
+func (c *Client) MarkNotificationsRead(user *types.User, ids []string) error {
+	query := `MATCH (:User { username: $username })<-[:FOR]-(n:Notification { read: false })
+		WHERE $ids IS NULL OR n.id IN $ids
+		SET n.read = true`
+
+	var idsParam interface{}
+	if ids != nil {
+		idsParam = ids
+	}
+
+	_, err := c.writeTransaction("MarkNotificationsRead", query, map[string]interface{}{"username": user.Username, "ids": idsParam})
+	return err
+}
+
+//notificationReturn completes a query that bound a notification to n and its actor to actor
+const notificationReturn = `
+	OPTIONAL MATCH (n)-[:ABOUT]->(l:Listing)
+	return n, actor.username, l.id`
+
+func getNotifications(records []*neo4j.Record) []*types.Notification {
+	notifications := make([]*types.Notification, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		n := &types.Notification{Actor: &types.User{Username: record.Values[1].(string)}}
+		n.ID, _ = node.Props["id"].(string)
+		n.CommentID, _ = node.Props["comment"].(string)
+		n.TransactionID, _ = node.Props["tx"].(string)
+		n.Read, _ = node.Props["read"].(bool)
+		n.Created, _ = getTime(node.Props["created"])
+		n.ListingID, _ = record.Values[2].(string)
+
+		if t, ok := node.Props["type"].(string); ok {
+			n.Type = types.NotificationType(t)
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications
+}
+
+//CommentRecipients returns the seller of the listing a comment is on and, for replies, the author of the parent
+//comment. parent is nil for top level comments
+func (c *Client) CommentRecipients(comment *types.Comment) (seller, parent *types.User, err error) {
+	query := `MATCH (seller:User)-[:SELLING]->(:Listing { id: $listing })
+		OPTIONAL MATCH (parent:User)-[:WROTE]->(:Comment { id: $parent })
+		return seller.username, parent.username`
+	records, err := c.readTransaction("CommentRecipients", query, map[string]interface{}{
+		"listing": comment.ListingID,
+		"parent":  comment.ParentID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil, ErrNotFound
+	}
+
+	seller = &types.User{Username: records[0].Values[0].(string)}
+	if username, ok := records[0].Values[1].(string); ok {
+		parent = &types.User{Username: username}
+	}
+
+	return seller, parent, nil
+}
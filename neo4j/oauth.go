@@ -0,0 +1,352 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/oauth"
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateOAuthClient stores a client registered by its owner. Only the hash of a confidential client's secret is stored
+func (c *Client) CreateOAuthClient(client *oauth.Client) error {
+	query := `MATCH (u:User { username: $owner })
+		CREATE (u)-[:REGISTERED]->(:OAuthClient { id: $id, name: $name, redirect_uris: $redirect_uris,
+			confidential: $confidential, secret: $secret, created: $created }) return u`
+	records, err := c.writeTransaction("CreateOAuthClient", query, map[string]interface{}{
+		"owner":         client.Owner,
+		"id":            client.ID,
+		"name":          client.Name,
+		"redirect_uris": client.RedirectURIs,
+		"confidential":  client.Confidential,
+		"secret":        optionalString(client.SecretHash),
+		"created":       client.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetOAuthClient retrieves the client with the given ID, or nil if there is none
+func (c *Client) GetOAuthClient(id string) (*oauth.Client, error) {
+	query := `MATCH (owner:User)-[:REGISTERED]->(c:OAuthClient { id: $id }) return c, owner.username`
+	records, err := c.readTransaction("GetOAuthClient", query, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return getOAuthClient(records[0]), nil
+}
+
+//ListOAuthClients retrieves the clients the user registered, oldest first
+func (c *Client) ListOAuthClients(owner string) ([]*oauth.Client, error) {
+	query := `MATCH (owner:User { username: $owner })-[:REGISTERED]->(c:OAuthClient) return c, owner.username ORDER BY c.created`
+	records, err := c.readTransaction("ListOAuthClients", query, map[string]interface{}{"owner": owner})
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]*oauth.Client, 0, len(records))
+	for _, record := range records {
+		clients = append(clients, getOAuthClient(record))
+	}
+
+	return clients, nil
+}
+
+//DeleteOAuthClient deletes the user's client along with the consents, codes and tokens given to it, reporting whether
+//it existed
+func (c *Client) DeleteOAuthClient(owner, id string) (bool, error) {
+	query := `MATCH (:User { username: $owner })-[:REGISTERED]->(c:OAuthClient { id: $id })
+		OPTIONAL MATCH (c)<-[:ISSUED_TO]-(issued)
+		DETACH DELETE issued
+		WITH DISTINCT c
+		DETACH DELETE c
+		return count(*)`
+	records, err := c.writeTransaction("DeleteOAuthClient", query, map[string]interface{}{"owner": owner, "id": id})
+	if err != nil {
+		return false, err
+	}
+
+	return len(records) > 0 && records[0].Values[0].(int64) > 0, nil
+}
+
+//SaveConsent creates or replaces the scopes the user allowed the client
+func (c *Client) SaveConsent(consent *oauth.Consent) error {
+	query := `MATCH (u:User { username: $username }), (c:OAuthClient { id: $client })
+		MERGE (u)-[k:CONSENTED]->(c) SET k.scopes = $scopes, k.granted = $granted return k`
+	records, err := c.writeTransaction("SaveConsent", query, map[string]interface{}{
+		"username": consent.Username,
+		"client":   consent.ClientID,
+		"scopes":   scopeStrings(consent.Scopes),
+		"granted":  consent.Granted,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetConsent retrieves the consent of the user to the client, or nil if there is none
+func (c *Client) GetConsent(username, clientID string) (*oauth.Consent, error) {
+	query := `MATCH (u:User { username: $username })-[k:CONSENTED]->(c:OAuthClient { id: $client })
+		return u.username, c.id, c.name, k.scopes, k.granted`
+	records, err := c.readTransaction("GetConsent", query, map[string]interface{}{"username": username, "client": clientID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return getConsent(records[0]), nil
+}
+
+//ListConsents retrieves the consents the user gave, oldest first
+func (c *Client) ListConsents(username string) ([]*oauth.Consent, error) {
+	query := `MATCH (u:User { username: $username })-[k:CONSENTED]->(c:OAuthClient)
+		return u.username, c.id, c.name, k.scopes, k.granted ORDER BY k.granted`
+	records, err := c.readTransaction("ListConsents", query, map[string]interface{}{"username": username})
+	if err != nil {
+		return nil, err
+	}
+
+	consents := make([]*oauth.Consent, 0, len(records))
+	for _, record := range records {
+		consents = append(consents, getConsent(record))
+	}
+
+	return consents, nil
+}
+
+//DeleteConsent withdraws the user's consent to the client along with the codes and tokens issued under it,
+//reporting whether there was one
+func (c *Client) DeleteConsent(username, clientID string) (bool, error) {
+	query := `MATCH (u:User { username: $username })-[k:CONSENTED]->(c:OAuthClient { id: $client })
+		DELETE k
+		WITH u, c
+		OPTIONAL MATCH (u)<-[:FOR]-(issued)-[:ISSUED_TO]->(c)
+		DETACH DELETE issued
+		return count(DISTINCT c)`
+	records, err := c.writeTransaction("DeleteConsent", query, map[string]interface{}{"username": username, "client": clientID})
+	if err != nil {
+		return false, err
+	}
+
+	return len(records) > 0 && records[0].Values[0].(int64) > 0, nil
+}
+
+//CreateAuthorizationCode stores an authorization code by its hash
+func (c *Client) CreateAuthorizationCode(code *oauth.Code) error {
+	query := `MATCH (u:User { username: $username }), (c:OAuthClient { id: $client })
+		CREATE (u)<-[:FOR]-(:OAuthCode { token: $token, redirect_uri: $redirect_uri, redirect_uri_given: $redirect_uri_given,
+			scopes: $scopes, challenge: $challenge, expires: $expires })-[:ISSUED_TO]->(c) return c`
+	records, err := c.writeTransaction("CreateAuthorizationCode", query, map[string]interface{}{
+		"username":           code.Username,
+		"client":             code.ClientID,
+		"token":              code.Hash,
+		"redirect_uri":       code.RedirectURI,
+		"redirect_uri_given": code.RedirectURIGiven,
+		"scopes":             scopeStrings(code.Scopes),
+		"challenge":          code.Challenge,
+		"expires":            code.Expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//TakeAuthorizationCode marks the code with the given hash as exchanged for the grant unless it was already and returns
+//it as it was before, or nil if there is none. Taken codes are kept until they expire so reusing one can be detected.
+//Grants are unique, so one already given to another code returns ErrAlreadyExists
+func (c *Client) TakeAuthorizationCode(hash, grant string) (*oauth.Code, error) {
+	// Writing to the code first locks it, so only one exchange can find it untaken
+	query := `MATCH (u:User)<-[:FOR]-(code:OAuthCode { token: $token })-[:ISSUED_TO]->(c:OAuthClient)
+		SET code.taking = true
+		WITH u, c, code, properties(code) AS props
+		SET code.grant = coalesce(code.grant, $grant)
+		REMOVE code.taking
+		return u.username, c.id, props`
+	records, err := c.writeTransaction("TakeAuthorizationCode", query, map[string]interface{}{"token": hash, "grant": grant})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	props := records[0].Values[2].(map[string]interface{})
+	code := &oauth.Code{
+		Hash:     hash,
+		Username: records[0].Values[0].(string),
+		ClientID: records[0].Values[1].(string),
+		Scopes:   getScopes(props["scopes"]),
+	}
+	code.RedirectURI, _ = props["redirect_uri"].(string)
+	code.RedirectURIGiven, _ = props["redirect_uri_given"].(bool)
+	code.Challenge, _ = props["challenge"].(string)
+	code.Grant, _ = props["grant"].(string)
+	code.Expires, _ = getTime(props["expires"])
+	return code, nil
+}
+
+//CreateOAuthToken stores an access or refresh token by its hash
+func (c *Client) CreateOAuthToken(token *oauth.Token) error {
+	query := `MATCH (u:User { username: $username }), (c:OAuthClient { id: $client })
+		CREATE (u)<-[:FOR]-(:OAuthToken { token: $token, refresh: $refresh, grant: $grant, scopes: $scopes,
+			expires: $expires })-[:ISSUED_TO]->(c) return c`
+	records, err := c.writeTransaction("CreateOAuthToken", query, map[string]interface{}{
+		"username": token.Username,
+		"client":   token.ClientID,
+		"token":    token.Hash,
+		"refresh":  token.Refresh,
+		"grant":    token.Grant,
+		"scopes":   scopeStrings(token.Scopes),
+		"expires":  token.Expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetOAuthToken retrieves the token with the given hash, or nil if there is none
+func (c *Client) GetOAuthToken(hash string) (*oauth.Token, error) {
+	query := `MATCH (u:User)<-[:FOR]-(t:OAuthToken { token: $token })-[:ISSUED_TO]->(c:OAuthClient)
+		return u.username, c.id, properties(t)`
+	records, err := c.readTransaction("GetOAuthToken", query, map[string]interface{}{"token": hash})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return getOAuthToken(hash, records[0]), nil
+}
+
+//TakeOAuthToken deletes the token with the given hash and returns it, or nil if there is none
+func (c *Client) TakeOAuthToken(hash string) (*oauth.Token, error) {
+	query := `MATCH (u:User)<-[:FOR]-(t:OAuthToken { token: $token })-[:ISSUED_TO]->(c:OAuthClient)
+		WITH u, c, properties(t) AS props, t
+		DETACH DELETE t
+		return u.username, c.id, props`
+	records, err := c.writeTransaction("TakeOAuthToken", query, map[string]interface{}{"token": hash})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return getOAuthToken(hash, records[0]), nil
+}
+
+//RevokeGrant deletes every token issued under the grant
+func (c *Client) RevokeGrant(grant string) error {
+	query := `MATCH (t:OAuthToken { grant: $grant }) DETACH DELETE t`
+	_, err := c.writeTransaction("RevokeGrant", query, map[string]interface{}{"grant": grant})
+	return err
+}
+
+//PruneOAuth deletes the authorization codes and tokens that expired before the given time
+func (c *Client) PruneOAuth(before time.Time) error {
+	query := `MATCH (n) WHERE (n:OAuthCode OR n:OAuthToken) AND n.expires < $before DETACH DELETE n`
+	_, err := c.writeTransaction("PruneOAuth", query, map[string]interface{}{"before": before})
+	return err
+}
+
+func getOAuthClient(record *neo4j.Record) *oauth.Client {
+	node := record.Values[0].(neo4j.Node)
+	client := &oauth.Client{Owner: record.Values[1].(string)}
+	client.ID, _ = node.Props["id"].(string)
+	client.Name, _ = node.Props["name"].(string)
+	client.Confidential, _ = node.Props["confidential"].(bool)
+	client.SecretHash, _ = node.Props["secret"].(string)
+	client.Created, _ = getTime(node.Props["created"])
+
+	uris, _ := node.Props["redirect_uris"].([]interface{})
+	for _, uri := range uris {
+		if u, ok := uri.(string); ok {
+			client.RedirectURIs = append(client.RedirectURIs, u)
+		}
+	}
+
+	return client
+}
+
+func getConsent(record *neo4j.Record) *oauth.Consent {
+	consent := &oauth.Consent{
+		Username: record.Values[0].(string),
+		ClientID: record.Values[1].(string),
+		Scopes:   getScopes(record.Values[3]),
+	}
+	consent.ClientName, _ = record.Values[2].(string)
+	consent.Granted, _ = getTime(record.Values[4])
+	return consent
+}
+
+func getOAuthToken(hash string, record *neo4j.Record) *oauth.Token {
+	props := record.Values[2].(map[string]interface{})
+	token := &oauth.Token{
+		Hash:     hash,
+		Username: record.Values[0].(string),
+		ClientID: record.Values[1].(string),
+		Scopes:   getScopes(props["scopes"]),
+	}
+	token.Refresh, _ = props["refresh"].(bool)
+	token.Grant, _ = props["grant"].(string)
+	token.Expires, _ = getTime(props["expires"])
+	return token
+}
+
+func scopeStrings(scopes []types.Scope) []string {
+	values := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		values = append(values, string(scope))
+	}
+
+	return values
+}
+
+func getScopes(prop interface{}) []types.Scope {
+	values, _ := prop.([]interface{})
+	scopes := make([]types.Scope, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			scopes = append(scopes, types.Scope(s))
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,192 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	emailPending = "pending"
+	emailSent    = "sent"
+	emailFailed  = "failed"
+)
+
+//enqueueEmails adds the emails to the outbox as part of the transaction of the change they are about. Emails whose
+//recipient does not want their category, or no longer exists, are dropped
+func enqueueEmails(t neo4j.Transaction, emails []*types.Email) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	params := make([]map[string]interface{}, 0, len(emails))
+	for _, e := range emails {
+		params = append(params, map[string]interface{}{
+			"id":       e.ID,
+			"to":       e.To,
+			"category": string(e.Category),
+			"subject":  e.Subject,
+			"text":     e.Text,
+			"html":     e.HTML,
+			"created":  e.Created,
+		})
+	}
+
+	query := `UNWIND $emails AS e
+		MATCH (u:User { username: e.to })
+		WHERE e.category = $account OR coalesce(u['email_' + e.category], true)
+		CREATE (:Email { id: e.id, category: e.category, subject: e.subject, text: e.text, html: e.html, address: u.email,
+			status: $pending, attempts: 0, created: e.created, next_attempt: e.created })-[:TO]->(u)`
+	_, err := t.Run(query, map[string]interface{}{
+		"emails":  params,
+		"account": string(types.EmailAccount),
+		"pending": emailPending,
+	})
+	return err
+}
+
+//EnqueueEmails adds emails that are not tied to any other change to the outbox
+func (c *Client) EnqueueEmails(emails ...*types.Email) error {
+	_, err := c.write("EnqueueEmails", func(t neo4j.Transaction) (interface{}, error) {
+		return nil, enqueueEmails(t, emails)
+	})
+	return err
+}
+
+//ClaimEmails returns up to limit pending emails due at now, oldest first, and postpones them until lease so no other
+//worker picks them up while they are being sent
+func (c *Client) ClaimEmails(now, lease time.Time, limit int) ([]*types.Email, error) {
+	query := `MATCH (e:Email { status: $pending })-[:TO]->(u:User) WHERE e.next_attempt <= $now
+		WITH e, u ORDER BY e.next_attempt LIMIT $limit
+		SET e.next_attempt = $lease
+		return e, u.username`
+	records, err := c.writeTransaction("ClaimEmails", query, map[string]interface{}{
+		"pending": emailPending,
+		"now":     now,
+		"lease":   lease,
+		"limit":   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	emails := make([]*types.Email, 0, len(records))
+	for _, record := range records {
+		node := record.Values[0].(neo4j.Node)
+		e := &types.Email{To: record.Values[1].(string)}
+		e.ID, _ = node.Props["id"].(string)
+		e.Address, _ = node.Props["address"].(string)
+		e.Subject, _ = node.Props["subject"].(string)
+		e.Text, _ = node.Props["text"].(string)
+		e.HTML, _ = node.Props["html"].(string)
+		e.Attempts, _ = node.Props["attempts"].(int64)
+		e.Created, _ = getTime(node.Props["created"])
+
+		if category, ok := node.Props["category"].(string); ok {
+			e.Category = types.EmailCategory(category)
+		}
+
+		emails = append(emails, e)
+	}
+
+	return emails, nil
+}
+
+//CountOverdueEmails returns how many pending emails were due before the given time
+func (c *Client) CountOverdueEmails(before time.Time) (int64, error) {
+	query := `MATCH (e:Email { status: $pending }) WHERE e.next_attempt < $before return count(e)`
+	records, err := c.readTransaction("CountOverdueEmails", query, map[string]interface{}{"pending": emailPending, "before": before})
+	if err != nil {
+		return 0, err
+	}
+
+	return records[0].Values[0].(int64), nil
+}
+
+//MarkEmailSent records the delivery of an email. Its subject and bodies are removed, they hold links such as password
+//resets that must not outlive the delivery
+func (c *Client) MarkEmailSent(id string, sent time.Time) error {
+	query := `MATCH (e:Email { id: $id }) SET e.status = $sent_status, e.sent = $sent, e.attempts = e.attempts + 1
+		REMOVE e.subject, e.text, e.html`
+	_, err := c.writeTransaction("MarkEmailSent", query, map[string]interface{}{"id": id, "sent_status": emailSent, "sent": sent})
+	return err
+}
+
+//MarkEmailFailed records a failed delivery attempt. The email is retried at next, or marked failed for good if next is
+//nil, in which case its subject and bodies are removed like those of sent emails
+func (c *Client) MarkEmailFailed(id string, attempts int64, next *time.Time, reason string) error {
+	query := `MATCH (e:Email { id: $id })
+		SET e.attempts = $attempts, e.last_error = $reason, e.status = $status, e.next_attempt = coalesce($next, e.next_attempt)
+		WITH e WHERE e.status = $failed REMOVE e.subject, e.text, e.html`
+
+	params := map[string]interface{}{
+		"id":       id,
+		"attempts": attempts,
+		"reason":   reason,
+		"status":   emailPending,
+		"next":     nil,
+		"failed":   emailFailed,
+	}
+	if next != nil {
+		params["next"] = *next
+	} else {
+		params["status"] = emailFailed
+	}
+
+	_, err := c.writeTransaction("MarkEmailFailed", query, params)
+	return err
+}
+
+//PruneEmails deletes the sent and failed emails created before the given time and returns how many there were
+func (c *Client) PruneEmails(before time.Time) (int64, error) {
+	query := `MATCH (e:Email) WHERE e.status IN [$sent, $failed] AND e.created < $before
+		DETACH DELETE e return count(e)`
+	records, err := c.writeTransaction("PruneEmails", query, map[string]interface{}{
+		"sent":   emailSent,
+		"failed": emailFailed,
+		"before": before,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return records[0].Values[0].(int64), nil
+}
+
+//GetEmailPreferences retrieves which optional emails the user wants. Every category is wanted unless the user opted out
+func (c *Client) GetEmailPreferences(user *types.User) (*types.EmailPreferences, error) {
+	query := `MATCH (u:User { username: $username }) return coalesce(u.email_receipts, true), coalesce(u.email_sales, true)`
+	records, err := c.readTransaction("GetEmailPreferences", query, map[string]interface{}{"username": user.Username})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &types.EmailPreferences{
+		Receipts: records[0].Values[0].(bool),
+		Sales:    records[0].Values[1].(bool),
+	}, nil
+}
+
+//SetEmailPreferences replaces the user's email preferences
+func (c *Client) SetEmailPreferences(user *types.User, prefs *types.EmailPreferences) error {
+	query := `MATCH (u:User { username: $username }) SET u.email_receipts = $receipts, u.email_sales = $sales return u`
+	records, err := c.writeTransaction("SetEmailPreferences", query, map[string]interface{}{
+		"username": user.Username,
+		"receipts": prefs.Receipts,
+		"sales":    prefs.Sales,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//revokeCredentials is the body of a subquery that ends all of the sessions of the user u and revokes their API keys
+//along with the codes, tokens and consents of the OAuth clients acting for them. A new password has to lock out
+//whoever learnt the old one, whichever credential they made with it
+const revokeCredentials = `WITH u OPTIONAL MATCH (u)<-[:AUTHENTICATES]-(s:Session) DETACH DELETE s
+	WITH DISTINCT u OPTIONAL MATCH (u)-[:OWNS]->(k:APIKey) DETACH DELETE k
+	WITH DISTINCT u OPTIONAL MATCH (u)<-[:FOR]-(issued) WHERE issued:OAuthCode OR issued:OAuthToken DETACH DELETE issued
+	WITH DISTINCT u OPTIONAL MATCH (u)-[consent:CONSENTED]->(:OAuthClient) DELETE consent`
+
+//CreatePasswordReset replaces the user's password reset token with the one with the given hash and queues the emails
+//carrying it in the same transaction. Only the hash of the token is persisted
+func (c *Client) CreatePasswordReset(user *types.User, tokenHash string, expires time.Time, emails ...*types.Email) error {
+	c.log().Info("Creating password reset", "username", user.Username)
+
+	_, err := c.write("CreatePasswordReset", func(t neo4j.Transaction) (interface{}, error) {
+		query := `MATCH (u:User { username: $username })
+			OPTIONAL MATCH (u)<-[:RESETS]-(old:PasswordReset)
+			DETACH DELETE old
+			WITH DISTINCT u
+			CREATE (:PasswordReset { token: $token, created: $created, expires: $expires })-[:RESETS]->(u)
+			return u`
+		result, err := t.Run(query, map[string]interface{}{
+			"username": user.Username,
+			"token":    tokenHash,
+			"created":  time.Now(),
+			"expires":  expires,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = result.Single(); err != nil {
+			return nil, ErrNotFound
+		}
+
+		return nil, enqueueEmails(t, emails)
+	})
+	return err
+}
+
+//ResetPassword sets the password of the user the reset token with the given hash belongs to, uses up the token and
+//ends all of the user's sessions, API keys and OAuth grants. It returns ErrNotFound if there is no such token or it
+//expired
+func (c *Client) ResetPassword(tokenHash, password string, now time.Time) (*types.User, error) {
+	query := `MATCH (r:PasswordReset { token: $token })-[:RESETS]->(u:User)
+		WITH r, u, r.expires > $now AS valid
+		DETACH DELETE r
+		WITH u, valid
+		CALL {
+			WITH u, valid
+			WITH u WHERE valid
+			SET u.password = $password
+			` + revokeCredentials + `
+		}
+		return u, valid`
+	records, err := c.writeTransaction("ResetPassword", query, map[string]interface{}{"token": tokenHash, "password": password, "now": now})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || !records[0].Values[1].(bool) {
+		return nil, ErrNotFound
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	return getUser(&node, map[string]bool{})
+}
+
+//UpgradePassword replaces the user's stored password by its hash, unless it changed since it was read. It is used to
+//hash the passwords stored before they were hashed as their users log in
+func (c *Client) UpgradePassword(user *types.User, stored, hash string) error {
+	query := `MATCH (u:User { username: $username }) WHERE u.password = $stored SET u.password = $hash`
+	_, err := c.writeTransaction("UpgradePassword", query, map[string]interface{}{
+		"username": user.Username,
+		"stored":   stored,
+		"hash":     hash,
+	})
+	return err
+}
+
+//ChangePassword sets the user's password and ends all of their sessions, API keys and OAuth grants along with any
+//pending password reset
+func (c *Client) ChangePassword(user *types.User, password string) error {
+	query := `MATCH (u:User { username: $username }) SET u.password = $password
+		WITH u OPTIONAL MATCH (u)<-[:RESETS]-(r:PasswordReset)
+		DETACH DELETE r
+		WITH DISTINCT u
+		CALL {
+			` + revokeCredentials + `
+		}
+		return u`
+	records, err := c.writeTransaction("ChangePassword", query, map[string]interface{}{"username": user.Username, "password": password})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
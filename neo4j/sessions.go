@@ -0,0 +1,51 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateSession stores a login session for the user. Only the hash of the session token is persisted
+func (c *Client) CreateSession(user *types.User, tokenHash string, expires time.Time) error {
+	query := `MATCH (u:User { username: $username }) CREATE (s:Session { token: $token, created: $created, expires: $expires })-[:AUTHENTICATES]->(u) return s`
+	records, err := c.writeTransaction("CreateSession", query, map[string]interface{}{
+		"username": user.Username,
+		"token":    tokenHash,
+		"created":  time.Now(),
+		"expires":  expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//GetSessionUser returns the user an unexpired session token hash belongs to, or nil if there is no such session
+func (c *Client) GetSessionUser(tokenHash string) (*types.User, error) {
+	query := `MATCH (s:Session { token: $token })-[:AUTHENTICATES]->(u:User) WHERE s.expires > datetime() return u`
+	records, err := c.readTransaction("GetSessionUser", query, map[string]interface{}{"token": tokenHash})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	return getUser(&node, map[string]bool{})
+}
+
+//DeleteSession removes the session with the given token hash
+func (c *Client) DeleteSession(tokenHash string) error {
+	query := `MATCH (s:Session { token: $token }) DETACH DELETE s`
+	_, err := c.writeTransaction("DeleteSession", query, map[string]interface{}{"token": tokenHash})
+	return err
+}
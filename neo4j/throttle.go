@@ -0,0 +1,62 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/throttle"
+)
+
+//ReserveAttempt counts a login attempt under the key as a failure unless the key has to wait after its previous
+//failures, starting over if the last one is older than window. Together with ReleaseAttempt and ClearAttempts it lets
+//the database act as the throttle store shared by all instances
+func (c *Client) ReserveAttempt(key string, now time.Time, window time.Duration, delays []time.Duration) (*throttle.Record, bool, error) {
+	// Writing to the throttle first locks it, so concurrent attempts read the failures one after the other
+	query := `MERGE (t:LoginThrottle { key: $key }) SET t.locked = true
+		WITH t, CASE WHEN t.last IS NULL OR t.last < $cutoff THEN 0 ELSE t.failures END AS failures
+		WITH t, failures, failures = 0 OR
+			t.last + duration({ milliseconds: $delays[CASE WHEN failures < size($delays) THEN failures ELSE size($delays) - 1 END] }) <= $now AS counted
+		SET t.failures = CASE WHEN counted THEN failures + 1 ELSE failures END,
+			t.last = CASE WHEN counted THEN $now ELSE t.last END
+		REMOVE t.locked
+		return t.failures, t.last, counted`
+
+	millis := make([]int64, 0, len(delays))
+	for _, delay := range delays {
+		millis = append(millis, delay.Milliseconds())
+	}
+
+	records, err := c.writeTransaction("ReserveAttempt", query, map[string]interface{}{
+		"key":    key,
+		"now":    now,
+		"cutoff": now.Add(-window),
+		"delays": millis,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	record := &throttle.Record{Failures: records[0].Values[0].(int64)}
+	record.Last, _ = getTime(records[0].Values[1])
+	return record, records[0].Values[2].(bool), nil
+}
+
+//ReleaseAttempt takes back a failure counted under the key by ReserveAttempt
+func (c *Client) ReleaseAttempt(key string) error {
+	query := `MATCH (t:LoginThrottle { key: $key }) WHERE t.failures > 0 SET t.failures = t.failures - 1`
+	_, err := c.writeTransaction("ReleaseAttempt", query, map[string]interface{}{"key": key})
+	return err
+}
+
+//ClearAttempts forgets the failed login attempts recorded under the key
+func (c *Client) ClearAttempts(key string) error {
+	query := `MATCH (t:LoginThrottle { key: $key }) DELETE t`
+	_, err := c.writeTransaction("ClearAttempts", query, map[string]interface{}{"key": key})
+	return err
+}
+
+//PruneAttempts deletes the failed login attempts last made before the given time
+func (c *Client) PruneAttempts(before time.Time) error {
+	query := `MATCH (t:LoginThrottle) WHERE t.last < $before DELETE t`
+	_, err := c.writeTransaction("PruneAttempts", query, map[string]interface{}{"before": before})
+	return err
+}
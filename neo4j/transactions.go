@@ -0,0 +1,105 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//GetSellerTransactions retrieves the sales of the seller's listings made in the period [from, to), oldest first
+func (c *Client) GetSellerTransactions(seller *types.User, from, to time.Time) ([]*types.Transaction, error) {
+	query := `MATCH (:User { username: $seller })-[:SELLING]->(:Listing)<-[b:BOUGHT]-(buyer:User)
+		WHERE b.date >= $from AND b.date < $to return b, buyer ORDER BY b.date`
+	records, err := c.readTransaction("GetSellerTransactions", query, map[string]interface{}{
+		"seller": seller.Username,
+		"from":   from,
+		"to":     to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]*types.Transaction, 0, len(records))
+	for _, record := range records {
+		bought := record.Values[0].(neo4j.Relationship)
+		tx, err := getTransaction(&bought)
+		if err != nil {
+			return nil, err
+		}
+
+		buyer := record.Values[1].(neo4j.Node)
+		tx.Buyer, err = getUser(&buyer, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+//GetPurchase retrieves the listing bought in the given transaction, with the transaction and its buyer filled in. It
+//returns nil if no such transaction exists
+func (c *Client) GetPurchase(txID string) (*types.Listing, error) {
+	query := `MATCH (buyer:User)-[b:BOUGHT { id: $id }]->(l:Listing) return l, b, buyer`
+	records, err := c.readTransaction("GetPurchase", query, map[string]interface{}{"id": txID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	listing, err := getListing(&node)
+	if err != nil {
+		return nil, err
+	}
+
+	bought := records[0].Values[1].(neo4j.Relationship)
+	listing.Sold = true
+	listing.Tx, err = getTransaction(&bought)
+	if err != nil {
+		return nil, err
+	}
+
+	buyer := records[0].Values[2].(neo4j.Node)
+	listing.Tx.Buyer, err = getUser(&buyer, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+//RecordDownload counts a download of the track bought in the given transaction through the download link with the
+//given grant ID. Every link counts once however many requests it serves, so resumed and ranged downloads are not
+//counted again. Grants are unique across transactions, so a link can never use up another transaction's downloads. It
+//returns ErrLimitReached if the transaction has already been downloaded through max other links, or if the grant
+//belongs to another transaction
+func (c *Client) RecordDownload(txID, grant string, max int64) error {
+	c.log().Info("Recording download", "transaction_id", txID, "grant", grant)
+	// Writing to the transaction first locks it, so concurrent requests with a new link only count it once
+	query := `MATCH ()-[b:BOUGHT { id: $id }]->()
+		SET b.recording = true
+		WITH b OPTIONAL MATCH (g:DownloadGrant { id: $grant })
+		WITH b, g REMOVE b.recording
+		WITH b, g WHERE coalesce(g.transaction, $id) = $id AND (g IS NOT NULL OR coalesce(b.downloads, 0) < $max)
+		FOREACH (_ IN CASE WHEN g IS NULL THEN [1] ELSE [] END |
+			CREATE (:DownloadGrant { id: $grant, transaction: $id })
+			SET b.downloads = coalesce(b.downloads, 0) + 1)
+		return b.downloads`
+	records, err := c.writeTransaction("RecordDownload", query, map[string]interface{}{"id": txID, "grant": grant, "max": max})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrLimitReached
+	}
+
+	return nil
+}
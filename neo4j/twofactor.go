@@ -0,0 +1,162 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//GetTwoFactor retrieves the user's TOTP enrollment
+func (c *Client) GetTwoFactor(user *types.User) (*types.TwoFactor, error) {
+	query := `MATCH (u:User { username: $username })
+		return coalesce(u.totp_enabled, false), u.totp_secret, u.totp_pending, coalesce(u.totp_last_step, 0), size(coalesce(u.totp_recovery, []))`
+	records, err := c.readTransaction("GetTwoFactor", query, map[string]interface{}{"username": user.Username})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	values := records[0].Values
+	tf := &types.TwoFactor{
+		Enabled:       values[0].(bool),
+		LastStep:      values[3].(int64),
+		RecoveryCodes: int(values[4].(int64)),
+	}
+	tf.Secret, _ = values[1].(string)
+	tf.Pending, _ = values[2].(string)
+
+	return tf, nil
+}
+
+//StartTwoFactor stores the encrypted secret of a new TOTP enrollment until it is confirmed. It returns
+//ErrAlreadyExists if two-factor authentication is enabled already
+func (c *Client) StartTwoFactor(user *types.User, secret string) error {
+	query := `MATCH (u:User { username: $username }) WHERE NOT coalesce(u.totp_enabled, false)
+		SET u.totp_pending = $secret return u`
+	records, err := c.writeTransaction("StartTwoFactor", query, map[string]interface{}{"username": user.Username, "secret": secret})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrAlreadyExists
+	}
+
+	return nil
+}
+
+//EnableTwoFactor turns the pending enrollment into the user's second factor, replacing the recovery codes with the
+//given hashes. step is the time step of the code that confirmed the enrollment, which cannot be used again
+func (c *Client) EnableTwoFactor(user *types.User, secret string, recoveryHashes []string, step int64) error {
+	query := `MATCH (u:User { username: $username }) WHERE u.totp_pending = $secret
+		SET u.totp_enabled = true, u.totp_secret = $secret, u.totp_recovery = $recovery, u.totp_last_step = $step
+		REMOVE u.totp_pending
+		return u`
+	records, err := c.writeTransaction("EnableTwoFactor", query, map[string]interface{}{
+		"username": user.Username,
+		"secret":   secret,
+		"recovery": recoveryHashes,
+		"step":     step,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//DisableTwoFactor removes the user's TOTP enrollment and recovery codes
+func (c *Client) DisableTwoFactor(user *types.User) error {
+	query := `MATCH (u:User { username: $username })
+		SET u.totp_enabled = false
+		REMOVE u.totp_secret, u.totp_pending, u.totp_recovery, u.totp_last_step`
+	_, err := c.writeTransaction("DisableTwoFactor", query, map[string]interface{}{"username": user.Username})
+	return err
+}
+
+//UseTOTPStep records that the code of the time step was used. It returns false if a code of that or a later step was
+//used before, so every code works only once
+func (c *Client) UseTOTPStep(user *types.User, step int64) (bool, error) {
+	query := `MATCH (u:User { username: $username }) WHERE coalesce(u.totp_last_step, 0) < $step
+		SET u.totp_last_step = $step return u`
+	records, err := c.writeTransaction("UseTOTPStep", query, map[string]interface{}{"username": user.Username, "step": step})
+	if err != nil {
+		return false, err
+	}
+
+	return len(records) > 0, nil
+}
+
+//UseRecoveryCode uses up the recovery code with the given hash. It returns false if the user has no such code
+func (c *Client) UseRecoveryCode(user *types.User, codeHash string) (bool, error) {
+	query := `MATCH (u:User { username: $username }) WHERE $code IN coalesce(u.totp_recovery, [])
+		SET u.totp_recovery = [c IN u.totp_recovery WHERE c <> $code] return u`
+	records, err := c.writeTransaction("UseRecoveryCode", query, map[string]interface{}{"username": user.Username, "code": codeHash})
+	if err != nil {
+		return false, err
+	}
+
+	return len(records) > 0, nil
+}
+
+//CreateLoginChallenge stores the hash of the token a user who passed the first login step completes the second with
+func (c *Client) CreateLoginChallenge(user *types.User, tokenHash string, expires time.Time) error {
+	query := `MATCH (u:User { username: $username })
+		CREATE (:LoginChallenge { token: $token, attempts: 0, expires: $expires })-[:CHALLENGES]->(u) return u`
+	records, err := c.writeTransaction("CreateLoginChallenge", query, map[string]interface{}{
+		"username": user.Username,
+		"token":    tokenHash,
+		"expires":  expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+//AttemptLoginChallenge counts an attempt at the second login step and returns the user the challenge with the given
+//hash belongs to, and whether the challenge can still be completed. Challenges that expired or ran out of attempts are
+//deleted. ErrNotFound is returned if there is no challenge with the hash
+func (c *Client) AttemptLoginChallenge(tokenHash string, now time.Time, maxAttempts int64) (*types.User, bool, error) {
+	query := `MATCH (l:LoginChallenge { token: $token })-[:CHALLENGES]->(u:User)
+		SET l.attempts = l.attempts + 1
+		WITH l, u, l.expires > $now AND l.attempts <= $max AS valid
+		FOREACH (_ IN CASE WHEN valid THEN [] ELSE [1] END | DETACH DELETE l)
+		return u, valid`
+	records, err := c.writeTransaction("AttemptLoginChallenge", query, map[string]interface{}{"token": tokenHash, "now": now, "max": maxAttempts})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(records) == 0 {
+		return nil, false, ErrNotFound
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	user, err := getUser(&node, map[string]bool{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return user, records[0].Values[1].(bool), nil
+}
+
+//DeleteLoginChallenge removes the challenge with the given hash once it has been completed
+func (c *Client) DeleteLoginChallenge(tokenHash string) error {
+	query := `MATCH (l:LoginChallenge { token: $token }) DETACH DELETE l`
+	_, err := c.writeTransaction("DeleteLoginChallenge", query, map[string]interface{}{"token": tokenHash})
+	return err
+}
@@ -1 +1,219 @@
 package neo4j
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//getListing builds a listing from a Listing node. Seller and transaction details live on relationships and have to be
+//filled in by the caller
+func getListing(node *neo4j.Node) (*types.Listing, error) {
+	id, ok := node.Props["id"].(string)
+	if !ok {
+		return nil, errors.New("Unable to retrieve id for listing")
+	}
+
+	listing := &types.Listing{ID: id}
+	listing.Sold, _ = node.Props["sold"].(bool)
+
+	if price, ok := node.Props["price"].(string); ok {
+		amount, err := parseAmount(price)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse price for listing %s: %w", id, err)
+		}
+		listing.Price = amount
+	}
+
+	if created, ok := getTime(node.Props["date"]); ok {
+		listing.Created = &created
+	}
+
+	if name, ok := node.Props["track"].(string); ok {
+		listing.Track = &types.Track{Name: name}
+		listing.Track.Path, _ = node.Props["path"].(string)
+	}
+
+	return listing, nil
+}
+
+//listingCounts projects the like, repost and comment counts of the listing bound to the variable l, in the order
+//getCounts reads them. Every query returning listings to users includes it, so they show the same counts everywhere
+func listingCounts(l string) string {
+	return `size([(` + l + `)<-[:LIKED]-(:User) | 1]), size([(` + l + `)<-[:REPOSTED]-(:User) | 1]),
+		size([(` + l + `)<-[:ON]-(comment:Comment) WHERE comment.deleted IS NULL | 1])`
+}
+
+//getCounts fills in the listing's counts from the values projected by listingCounts
+func getCounts(listing *types.Listing, values []interface{}) {
+	listing.Likes, _ = values[0].(int64)
+	listing.Reposts, _ = values[1].(int64)
+	listing.Comments, _ = values[2].(int64)
+}
+
+//getTransaction builds a transaction from the properties of a BOUGHT relationship. The buyer has to be filled in by
+//the caller
+func getTransaction(relationship *neo4j.Relationship) (*types.Transaction, error) {
+	var err error
+	tx := &types.Transaction{}
+
+	tx.ID, _ = relationship.Props["id"].(string)
+	tx.DiscountID, _ = relationship.Props["discount_id"].(string)
+	tx.Downloads, _ = relationship.Props["downloads"].(int64)
+	tx.Date, _ = getTime(relationship.Props["date"])
+
+	amounts := map[string]*currency.Amount{
+		"original_price": &tx.OriginalPrice,
+		"discount":       &tx.Discount,
+		"final_price":    &tx.FinalPrice,
+	}
+
+	if name, ok := relationship.Props["tax_name"].(string); ok {
+		tax := &types.Tax{Name: name}
+		tax.Country, _ = relationship.Props["tax_country"].(string)
+		tax.Region, _ = relationship.Props["tax_region"].(string)
+		tax.Rate, _ = relationship.Props["tax_rate"].(string)
+		tax.Inclusive, _ = relationship.Props["tax_inclusive"].(bool)
+		amounts["tax_net"] = &tax.Net
+		amounts["tax_amount"] = &tax.Amount
+		amounts["tax_gross"] = &tax.Gross
+		tx.Tax = tax
+	}
+
+	for prop, amount := range amounts {
+		value, ok := relationship.Props[prop].(string)
+		if !ok {
+			continue
+		}
+
+		*amount, err = parseAmount(value)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse %s for transaction %s: %w", prop, tx.ID, err)
+		}
+	}
+
+	return tx, nil
+}
+
+//transactionProps converts a transaction into the properties of a BOUGHT relationship
+func transactionProps(tx *types.Transaction) map[string]interface{} {
+	props := map[string]interface{}{
+		"id":             tx.ID,
+		"date":           tx.Date,
+		"original_price": tx.OriginalPrice.String(),
+		"discount":       tx.Discount.String(),
+		"final_price":    tx.FinalPrice.String(),
+		"discount_id":    optionalString(tx.DiscountID),
+	}
+
+	if tx.Tax != nil {
+		props["tax_name"] = tx.Tax.Name
+		props["tax_country"] = tx.Tax.Country
+		props["tax_region"] = optionalString(tx.Tax.Region)
+		props["tax_rate"] = tx.Tax.Rate
+		props["tax_inclusive"] = tx.Tax.Inclusive
+		props["tax_net"] = tx.Tax.Net.String()
+		props["tax_amount"] = tx.Tax.Amount.String()
+		props["tax_gross"] = tx.Tax.Gross.String()
+	}
+
+	return props
+}
+
+//getDiscount builds a discount from a Discount node and the ID of the listing it applies to, if any
+func getDiscount(node *neo4j.Node, listingID interface{}) *types.Discount {
+	d := &types.Discount{}
+
+	d.ID, _ = node.Props["id"].(string)
+	d.Code, _ = node.Props["code"].(string)
+	d.Value, _ = node.Props["value"].(string)
+	d.MaxUses, _ = node.Props["max_uses"].(int64)
+	d.PerBuyerLimit, _ = node.Props["per_buyer_limit"].(int64)
+	d.Uses, _ = node.Props["uses"].(int64)
+	d.ListingID, _ = listingID.(string)
+
+	if t, ok := node.Props["type"].(string); ok {
+		d.Type = types.DiscountType(t)
+	}
+
+	if starts, ok := getTime(node.Props["starts"]); ok {
+		d.Starts = &starts
+	}
+
+	if expires, ok := getTime(node.Props["expires"]); ok {
+		d.Expires = &expires
+	}
+
+	return d
+}
+
+//parseAmount parses an amount stored in the "<number> <currency code>" format produced by currency.Amount.String
+func parseAmount(value string) (currency.Amount, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return currency.Amount{}, fmt.Errorf("invalid amount %q", value)
+	}
+
+	return currency.NewAmount(fields[0], fields[1])
+}
+
+//getTime reads a time property stored either as a native DateTime or as a string in dateFormat, which init.cypher
+//migrates to DateTimes. Strings written from times carrying a monotonic clock reading end with it, it is ignored
+func getTime(prop interface{}) (time.Time, bool) {
+	switch value := prop.(type) {
+	case time.Time:
+		return value, true
+	case string:
+		if i := strings.Index(value, " m="); i >= 0 {
+			value = value[:i]
+		}
+		t, err := time.Parse(dateFormat, value)
+		return t, err == nil
+	}
+
+	return time.Time{}, false
+}
+
+//optionalTime converts an optional time into a query parameter, leaving the property unset when t is nil
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return *t
+}
+
+//optionalString converts an optional string into a query parameter, leaving the property unset when s is empty
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+//viewerParam converts the user a read is made on behalf of into the $viewer query parameter used by notBlocked and
+//notBlockedBy. Anonymous reads pass nil
+func viewerParam(viewer *types.User) interface{} {
+	if viewer == nil {
+		return nil
+	}
+
+	return viewer.Username
+}
+
+//notBlocked is a Cypher predicate that holds when neither the user bound to variable nor the viewer has blocked the
+//other
+func notBlocked(variable string) string {
+	return fmt.Sprintf("($viewer IS NULL OR NOT EXISTS { MATCH (%s)-[:BLOCKED]-(:User { username: $viewer }) })", variable)
+}
+
+//notBlockedBy is a Cypher predicate that holds when the user bound to variable has not blocked the viewer
+func notBlockedBy(variable string) string {
+	return fmt.Sprintf("($viewer IS NULL OR NOT EXISTS { MATCH (%s)-[:BLOCKED]->(:User { username: $viewer }) })", variable)
+}
@@ -0,0 +1,143 @@
+package neo4j
+
+import (
+	"time"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+//CreateVerification replaces the user's email verification token with the one with the given hash and queues the
+//emails carrying it in the same transaction. Only the hash of the token is persisted. It returns ErrAlreadyVerified
+//if the user's email address is verified already
+func (c *Client) CreateVerification(user *types.User, tokenHash string, expires time.Time, emails ...*types.Email) error {
+	c.log().Info("Creating email verification", "username", user.Username)
+
+	_, err := c.write("CreateVerification", func(t neo4j.Transaction) (interface{}, error) {
+		err := createVerification(t, user, tokenHash, expires)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, enqueueEmails(t, emails)
+	})
+	return err
+}
+
+func createVerification(t neo4j.Transaction, user *types.User, tokenHash string, expires time.Time) error {
+	query := `MATCH (u:User { username: $username })
+		OPTIONAL MATCH (u)<-[:VERIFIES]-(old:Verification)
+		DETACH DELETE old
+		WITH DISTINCT u WHERE u.verified = false
+		CREATE (:Verification { token: $token, email: u.email, created: $created, expires: $expires })-[:VERIFIES]->(u)
+		return u`
+	result, err := t.Run(query, map[string]interface{}{
+		"username": user.Username,
+		"token":    tokenHash,
+		"created":  time.Now(),
+		"expires":  expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	records, err := result.Collect()
+	if err != nil {
+		return err
+	}
+
+	if len(records) > 0 {
+		return nil
+	}
+
+	result, err = t.Run(`MATCH (u:User { username: $username }) return u`, map[string]interface{}{"username": user.Username})
+	if err != nil {
+		return err
+	}
+
+	if _, err = result.Single(); err != nil {
+		return ErrNotFound
+	}
+
+	return ErrAlreadyVerified
+}
+
+//VerifyEmail marks the email address the token with the given hash was sent to as verified and uses up the token.
+//It returns ErrNotFound if there is no such token, it expired or the user has changed their address since
+func (c *Client) VerifyEmail(tokenHash string, now time.Time) (*types.User, error) {
+	query := `MATCH (v:Verification { token: $token })-[:VERIFIES]->(u:User)
+		WITH v, u, v.expires > $now AND v.email = u.email AS valid
+		FOREACH (_ IN CASE WHEN valid THEN [1] ELSE [] END | SET u.verified = true)
+		DETACH DELETE v
+		return u, valid`
+	records, err := c.writeTransaction("VerifyEmail", query, map[string]interface{}{"token": tokenHash, "now": now})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || !records[0].Values[1].(bool) {
+		return nil, ErrNotFound
+	}
+
+	node := records[0].Values[0].(neo4j.Node)
+	return getUser(&node, map[string]bool{})
+}
+
+//ChangeEmail changes the user's email address, which has to be verified again, and creates the verification token
+//for the new address with the emails carrying it in the same transaction. It returns ErrEmailTaken if another user
+//has the address
+func (c *Client) ChangeEmail(user *types.User, address, tokenHash string, expires time.Time, emails ...*types.Email) error {
+	c.log().Info("Changing email address", "username", user.Username)
+
+	_, err := c.write("ChangeEmail", func(t neo4j.Transaction) (interface{}, error) {
+		result, err := t.Run(`MATCH (u:User { email: $email }) WHERE u.username <> $username return u`,
+			map[string]interface{}{"email": address, "username": user.Username})
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Next() {
+			return nil, ErrEmailTaken
+		}
+
+		result, err = t.Run(`MATCH (u:User { username: $username }) SET u.email = $email, u.verified = false return u`,
+			map[string]interface{}{"email": address, "username": user.Username})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = result.Single(); err != nil {
+			return nil, ErrNotFound
+		}
+
+		err = createVerification(t, user, tokenHash, expires)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, enqueueEmails(t, emails)
+	})
+	return err
+}
+
+//PurgeUnverifiedUsers deletes the accounts created before the given time that never verified their email address,
+//along with their sessions, tokens, comments and everything queued for them. It returns how many were deleted
+func (c *Client) PurgeUnverifiedUsers(before time.Time) (int64, error) {
+	query := `MATCH (u:User { verified: false }) WHERE u.created < $before
+		OPTIONAL MATCH (u)<-[:AUTHENTICATES|VERIFIES|FOR|TO]-(owned)
+		OPTIONAL MATCH (u)-[:WROTE]->(comment:Comment)
+		DETACH DELETE owned, comment
+		WITH DISTINCT u
+		DETACH DELETE u
+		return count(u)`
+	records, err := c.writeTransaction("PurgeUnverifiedUsers", query, map[string]interface{}{"before": before})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	return records[0].Values[0].(int64), nil
+}
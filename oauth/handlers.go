@@ -0,0 +1,136 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/danny-m08/music-match/logging"
+)
+
+//decision is the user's answer to an authorization request
+type decision struct {
+	Approve bool `json:"approve"`
+}
+
+//redirectResponse tells the frontend where to send the user after they answered an authorization request
+type redirectResponse struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+//ServeAuthorize serves the authorization endpoint for the signed in user. The request parameters are read from the
+//query string. GET describes the request so the frontend can ask for consent, or skip asking when the user already
+//gave it, and POST takes the user's decision and returns the address to send them back to the client with
+func (p *Provider) ServeAuthorize(w http.ResponseWriter, req *http.Request, username string) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authorization, err := p.Authorize(req.URL.Query(), username)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, authorization)
+		return
+	}
+
+	answer := &decision{}
+	err = json.NewDecoder(req.Body).Decode(answer)
+	if err != nil {
+		writeError(w, newError("invalid_request", "unable to read decision: "+err.Error()))
+		return
+	}
+
+	if !answer.Approve {
+		writeJSON(w, http.StatusOK, redirectResponse{RedirectURI: p.Deny(authorization)})
+		return
+	}
+
+	uri, err := p.Approve(authorization, username)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, redirectResponse{RedirectURI: uri})
+}
+
+//ServeToken serves the token endpoint. Confidential clients authenticate with HTTP Basic authentication or the
+//client_secret parameter
+func (p *Provider) ServeToken(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		writeError(w, newError("invalid_request", err.Error()))
+		return
+	}
+
+	clientID, clientSecret, _ := req.BasicAuth()
+	response, err := p.Exchange(req.PostForm, clientID, clientSecret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//ServeRevoke serves the token revocation endpoint. It answers 200 for unknown tokens as well, as RFC 7009 requires
+func (p *Provider) ServeRevoke(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		writeError(w, newError("invalid_request", err.Error()))
+		return
+	}
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+
+	err := p.Revoke(req.PostForm.Get("token"), clientID, clientSecret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+//writeError writes an OAuth error response, hiding the details of anything but protocol errors
+func writeError(w http.ResponseWriter, err error) {
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		logging.Error("Unable to process OAuth request", "error", err)
+		oauthErr = &Error{Code: "server_error", status: http.StatusInternalServerError}
+	}
+
+	if oauthErr.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="music-match"`)
+	}
+
+	writeJSON(w, oauthErr.status, oauthErr)
+}
+
+//writeJSON writes a JSON response that must not be cached, as token responses carry credentials
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logging.Error("Unable to write OAuth response", "error", err)
+	}
+}
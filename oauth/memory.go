@@ -0,0 +1,254 @@
+package oauth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//MemoryStore keeps everything in memory. It is meant for tests and trying the flow out locally, everything is lost
+//on restart
+type MemoryStore struct {
+	mu       sync.Mutex
+	clients  map[string]*Client
+	consents map[string]*Consent
+	codes    map[string]*Code
+	tokens   map[string]*Token
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		clients:  make(map[string]*Client),
+		consents: make(map[string]*Consent),
+		codes:    make(map[string]*Code),
+		tokens:   make(map[string]*Token),
+	}
+}
+
+func consentKey(username, clientID string) string {
+	return username + "|" + clientID
+}
+
+func (s *MemoryStore) CreateOAuthClient(client *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *client
+	s.clients[client.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) GetOAuthClient(id string) (*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := *client
+	return &copied, nil
+}
+
+func (s *MemoryStore) ListOAuthClients(owner string) ([]*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var clients []*Client
+	for _, client := range s.clients {
+		if client.Owner == owner {
+			copied := *client
+			clients = append(clients, &copied)
+		}
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Created.Before(clients[j].Created) })
+	return clients, nil
+}
+
+func (s *MemoryStore) DeleteOAuthClient(owner, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[id]
+	if !ok || client.Owner != owner {
+		return false, nil
+	}
+
+	delete(s.clients, id)
+	s.deleteIssued(func(clientID, _ string) bool { return clientID == id })
+	for key, consent := range s.consents {
+		if consent.ClientID == id {
+			delete(s.consents, key)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *MemoryStore) SaveConsent(consent *Consent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *consent
+	s.consents[consentKey(consent.Username, consent.ClientID)] = &copied
+	return nil
+}
+
+func (s *MemoryStore) GetConsent(username, clientID string) (*Consent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	consent, ok := s.consents[consentKey(username, clientID)]
+	if !ok {
+		return nil, nil
+	}
+
+	return s.withClientName(consent), nil
+}
+
+func (s *MemoryStore) ListConsents(username string) ([]*Consent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var consents []*Consent
+	for _, consent := range s.consents {
+		if consent.Username == username {
+			consents = append(consents, s.withClientName(consent))
+		}
+	}
+
+	sort.Slice(consents, func(i, j int) bool { return consents[i].Granted.Before(consents[j].Granted) })
+	return consents, nil
+}
+
+func (s *MemoryStore) withClientName(consent *Consent) *Consent {
+	copied := *consent
+	if client, ok := s.clients[consent.ClientID]; ok {
+		copied.ClientName = client.Name
+	}
+
+	return &copied
+}
+
+func (s *MemoryStore) DeleteConsent(username, clientID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := consentKey(username, clientID)
+	if _, ok := s.consents[key]; !ok {
+		return false, nil
+	}
+
+	delete(s.consents, key)
+	s.deleteIssued(func(c, u string) bool { return c == clientID && u == username })
+	return true, nil
+}
+
+func (s *MemoryStore) CreateAuthorizationCode(code *Code) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *code
+	s.codes[code.Hash] = &copied
+	return nil
+}
+
+func (s *MemoryStore) TakeAuthorizationCode(hash, grant string) (*Code, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.codes[hash]
+	if !ok {
+		return nil, nil
+	}
+
+	taken := *code
+	if code.Grant == "" {
+		code.Grant = grant
+	}
+	return &taken, nil
+}
+
+func (s *MemoryStore) CreateOAuthToken(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *token
+	s.tokens[token.Hash] = &copied
+	return nil
+}
+
+func (s *MemoryStore) GetOAuthToken(hash string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := *token
+	return &copied, nil
+}
+
+func (s *MemoryStore) TakeOAuthToken(hash string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, nil
+	}
+
+	delete(s.tokens, hash)
+	return token, nil
+}
+
+func (s *MemoryStore) RevokeGrant(grant string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.Grant == grant {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) PruneOAuth(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, code := range s.codes {
+		if code.Expires.Before(before) {
+			delete(s.codes, hash)
+		}
+	}
+
+	for hash, token := range s.tokens {
+		if token.Expires.Before(before) {
+			delete(s.tokens, hash)
+		}
+	}
+
+	return nil
+}
+
+//deleteIssued deletes the codes and tokens issued to the clients and users matched by issuedTo
+func (s *MemoryStore) deleteIssued(issuedTo func(clientID, username string) bool) {
+	for hash, code := range s.codes {
+		if issuedTo(code.ClientID, code.Username) {
+			delete(s.codes, hash)
+		}
+	}
+
+	for hash, token := range s.tokens {
+		if issuedTo(token.ClientID, token.Username) {
+			delete(s.tokens, hash)
+		}
+	}
+}
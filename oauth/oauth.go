@@ -0,0 +1,677 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	//AccessTokenPrefix starts every access token, telling them apart from session tokens and API keys
+	AccessTokenPrefix  = "mma_"
+	refreshTokenPrefix = "mmr_"
+	clientSecretPrefix = "mms_"
+
+	defaultCodeTTL    = 5 * time.Minute
+	defaultAccessTTL  = time.Hour
+	defaultRefreshTTL = 30 * 24 * time.Hour
+
+	maxRedirectURIs = 5
+	maxNameLength   = 100
+	//Code verifiers are between 43 and 128 characters long, see RFC 7636 section 4.1
+	minVerifierLength = 43
+	maxVerifierLength = 128
+)
+
+//Client is an app registered to act on behalf of users. Confidential clients authenticate with a secret, public ones
+//such as plugins running on a user's machine cannot keep one and rely on PKCE alone
+type Client struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name"`
+	Owner        string    `json:"owner"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	SecretHash   string    `json:"-"`
+	Created      time.Time `json:"created"`
+}
+
+//Consent records the scopes a user allowed a client
+type Consent struct {
+	Username   string        `json:"-"`
+	ClientID   string        `json:"client_id"`
+	ClientName string        `json:"client_name,omitempty"`
+	Scopes     []types.Scope `json:"scopes"`
+	Granted    time.Time     `json:"granted"`
+}
+
+//Code is an authorization code waiting to be exchanged for tokens. Challenge is the PKCE code challenge.
+//RedirectURIGiven is set when the authorization request named the redirect URI, which the token request then has to
+//repeat. Grant is the grant the code was exchanged for, empty until it is
+type Code struct {
+	Hash             string
+	ClientID         string
+	Username         string
+	RedirectURI      string
+	RedirectURIGiven bool
+	Scopes           []types.Scope
+	Challenge        string
+	Expires          time.Time
+	Grant            string
+}
+
+//Token is an access or refresh token. Tokens issued from the same authorization share a Grant so the whole chain can
+//be revoked at once
+type Token struct {
+	Hash     string
+	Refresh  bool
+	Grant    string
+	ClientID string
+	Username string
+	Scopes   []types.Scope
+	Expires  time.Time
+}
+
+//Allows reports whether the token was granted the scope
+func (t *Token) Allows(scope types.Scope) bool {
+	return hasScope(t.Scopes, scope)
+}
+
+//Store keeps the clients, consents, codes and tokens
+type Store interface {
+	CreateOAuthClient(client *Client) error
+	//GetOAuthClient returns the client with the given ID, or nil if there is none
+	GetOAuthClient(id string) (*Client, error)
+	ListOAuthClients(owner string) ([]*Client, error)
+	//DeleteOAuthClient deletes the owner's client along with everything issued to it, reporting whether it existed
+	DeleteOAuthClient(owner, id string) (bool, error)
+	//SaveConsent creates or replaces the consent of the user to the client
+	SaveConsent(consent *Consent) error
+	//GetConsent returns the consent of the user to the client, or nil if there is none
+	GetConsent(username, clientID string) (*Consent, error)
+	ListConsents(username string) ([]*Consent, error)
+	//DeleteConsent withdraws the consent and revokes the tokens issued under it, reporting whether it existed
+	DeleteConsent(username, clientID string) (bool, error)
+	CreateAuthorizationCode(code *Code) error
+	//TakeAuthorizationCode marks the code with the given hash as exchanged for the grant unless it was already and
+	//returns it as it was before, or nil if there is none
+	TakeAuthorizationCode(hash, grant string) (*Code, error)
+	CreateOAuthToken(token *Token) error
+	//GetOAuthToken returns the token with the given hash, or nil if there is none
+	GetOAuthToken(hash string) (*Token, error)
+	//TakeOAuthToken deletes the token with the given hash and returns it, or nil if there is none
+	TakeOAuthToken(hash string) (*Token, error)
+	//RevokeGrant deletes every token issued under the grant
+	RevokeGrant(grant string) error
+	//PruneOAuth deletes the codes and tokens that expired before the given time
+	PruneOAuth(before time.Time) error
+}
+
+//Error is an OAuth error response as defined by RFC 6749 section 5.2
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newError(code, description string) *Error {
+	status := 400
+	if code == "invalid_client" {
+		status = 401
+	}
+
+	return &Error{Code: code, Description: description, status: status}
+}
+
+//Provider is the OAuth 2 authorization server. It implements the authorization code grant with PKCE and refresh
+//tokens, rotating refresh tokens every time they are used
+type Provider struct {
+	store      Store
+	codeTTL    time.Duration
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewProvider(conf *config.OAuthConfig, store Store) *Provider {
+	provider := &Provider{
+		store:      store,
+		codeTTL:    conf.CodeTTL,
+		accessTTL:  conf.AccessTTL,
+		refreshTTL: conf.RefreshTTL,
+		now:        time.Now,
+	}
+
+	if provider.codeTTL == 0 {
+		provider.codeTTL = defaultCodeTTL
+	}
+	if provider.accessTTL == 0 {
+		provider.accessTTL = defaultAccessTTL
+	}
+	if provider.refreshTTL == 0 {
+		provider.refreshTTL = defaultRefreshTTL
+	}
+
+	return provider
+}
+
+//RegisterClient registers a client owned by the user and returns it with its secret, which is empty for public
+//clients and cannot be retrieved again
+func (p *Provider) RegisterClient(owner, name string, redirectURIs []string, confidential bool) (*Client, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, "", newError("invalid_client_metadata", fmt.Sprintf("name must be between 1 and %d characters", maxNameLength))
+	}
+
+	if len(redirectURIs) == 0 || len(redirectURIs) > maxRedirectURIs {
+		return nil, "", newError("invalid_redirect_uri", fmt.Sprintf("between 1 and %d redirect URIs are required", maxRedirectURIs))
+	}
+
+	for _, uri := range redirectURIs {
+		if err := validRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+
+	client := &Client{
+		ID:           types.GenerateID() + types.GenerateID(),
+		Name:         name,
+		Owner:        owner,
+		RedirectURIs: redirectURIs,
+		Confidential: confidential,
+		Created:      p.now(),
+	}
+
+	var secret string
+	if confidential {
+		token, err := auth.NewToken()
+		if err != nil {
+			return nil, "", err
+		}
+		secret = clientSecretPrefix + token
+		client.SecretHash = auth.HashToken(secret)
+	}
+
+	err := p.store.CreateOAuthClient(client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return client, secret, nil
+}
+
+//validRedirectURI accepts absolute URIs without a fragment. Plain http is only allowed for loopback addresses, which
+//native apps listen on, and private-use schemes such as com.example.plugin:/callback are allowed for native apps
+//that register one, see RFC 8252
+func validRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return newError("invalid_redirect_uri", "redirect URIs must be absolute and have no fragment")
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if ip := net.ParseIP(u.Hostname()); u.Hostname() == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+		return newError("invalid_redirect_uri", "http redirect URIs are only allowed for loopback addresses")
+	case "javascript", "data", "file":
+		return newError("invalid_redirect_uri", u.Scheme+" redirect URIs are not allowed")
+	}
+
+	if !strings.Contains(u.Scheme, ".") {
+		return newError("invalid_redirect_uri", "private-use schemes must be in reverse domain name notation")
+	}
+
+	return nil
+}
+
+//Authorization is a validated authorization request waiting for the user's decision. Consented is set when the user
+//already allowed the client every requested scope
+type Authorization struct {
+	Client           *Client       `json:"client"`
+	RedirectURI      string        `json:"redirect_uri"`
+	RedirectURIGiven bool          `json:"-"`
+	Scopes           []types.Scope `json:"scopes"`
+	State            string        `json:"state,omitempty"`
+	Challenge        string        `json:"-"`
+	Consented        bool          `json:"consented"`
+}
+
+//Authorize validates an authorization request made on behalf of the user
+func (p *Provider) Authorize(params url.Values, username string) (*Authorization, error) {
+	client, err := p.store.GetOAuthClient(params.Get("client_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, newError("invalid_client", "unknown client")
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	} else if !contains(client.RedirectURIs, redirectURI) {
+		return nil, newError("invalid_request", "redirect_uri is not registered for this client")
+	}
+
+	if params.Get("response_type") != "code" {
+		return nil, newError("unsupported_response_type", "only the code response type is supported")
+	}
+
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		return nil, newError("invalid_request", "a code_challenge using the S256 method is required")
+	}
+
+	scopes, err := parseScopes(params.Get("scope"))
+	if err != nil {
+		return nil, err
+	}
+
+	authorization := &Authorization{
+		Client:           client,
+		RedirectURI:      redirectURI,
+		RedirectURIGiven: params.Get("redirect_uri") != "",
+		Scopes:           scopes,
+		State:            params.Get("state"),
+		Challenge:        params.Get("code_challenge"),
+	}
+
+	consent, err := p.store.GetConsent(username, client.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization.Consented = consent != nil && covers(consent.Scopes, scopes)
+	return authorization, nil
+}
+
+//Approve records the user's consent to the authorization and issues an authorization code, returning the address the
+//user has to be sent back to. Scopes consented to earlier are kept
+func (p *Provider) Approve(a *Authorization, username string) (string, error) {
+	code, err := auth.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	consent, err := p.store.GetConsent(username, a.Client.ID)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := a.Scopes
+	if consent != nil {
+		for _, scope := range consent.Scopes {
+			if !hasScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	now := p.now()
+	err = p.store.SaveConsent(&Consent{Username: username, ClientID: a.Client.ID, Scopes: scopes, Granted: now})
+	if err != nil {
+		return "", err
+	}
+
+	err = p.store.CreateAuthorizationCode(&Code{
+		Hash:             auth.HashToken(code),
+		ClientID:         a.Client.ID,
+		Username:         username,
+		RedirectURI:      a.RedirectURI,
+		RedirectURIGiven: a.RedirectURIGiven,
+		Scopes:           a.Scopes,
+		Challenge:        a.Challenge,
+		Expires:          now.Add(p.codeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return redirect(a, url.Values{"code": {code}}), nil
+}
+
+//Deny returns the address the user has to be sent back to after turning the authorization down
+func (p *Provider) Deny(a *Authorization) string {
+	return redirect(a, url.Values{"error": {"access_denied"}})
+}
+
+func redirect(a *Authorization, params url.Values) string {
+	if a.State != "" {
+		params.Set("state", a.State)
+	}
+
+	u, _ := url.Parse(a.RedirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+//TokenResponse is a successful token response as defined by RFC 6749 section 5.1
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+//Exchange handles a token request for the authorization_code or refresh_token grant. The client authenticates with
+//the credentials given, which are empty for public clients
+func (p *Provider) Exchange(params url.Values, clientID, clientSecret string) (*TokenResponse, error) {
+	if clientID == "" {
+		clientID = params.Get("client_id")
+	}
+	if clientSecret == "" {
+		clientSecret = params.Get("client_secret")
+	}
+
+	client, err := p.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch params.Get("grant_type") {
+	case "authorization_code":
+		return p.exchangeCode(client, params)
+	case "refresh_token":
+		return p.refresh(client, params)
+	}
+
+	return nil, newError("unsupported_grant_type", "only the authorization_code and refresh_token grants are supported")
+}
+
+func (p *Provider) authenticateClient(id, secret string) (*Client, error) {
+	client, err := p.store.GetOAuthClient(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, newError("invalid_client", "unknown client")
+	}
+
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, newError("invalid_client", "client authentication failed")
+	}
+
+	return client, nil
+}
+
+//exchangeCode issues tokens for an authorization code. Codes are single use: a code used again may have been stolen,
+//so the tokens already issued for it are revoked, see RFC 6749 section 4.1.2. The redirect URI has to be repeated if
+//the authorization request named it, see section 4.1.3
+func (p *Provider) exchangeCode(client *Client, params url.Values) (*TokenResponse, error) {
+	grant, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := p.store.TakeAuthorizationCode(auth.HashToken(params.Get("code")), grant)
+	if err != nil {
+		return nil, err
+	}
+
+	if code == nil || code.ClientID != client.ID || p.now().After(code.Expires) {
+		return nil, newError("invalid_grant", "invalid or expired authorization code")
+	}
+
+	if code.Grant != "" {
+		err = p.store.RevokeGrant(code.Grant)
+		if err != nil {
+			return nil, err
+		}
+		return nil, newError("invalid_grant", "invalid or expired authorization code")
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if (code.RedirectURIGiven || redirectURI != "") && redirectURI != code.RedirectURI {
+		return nil, newError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+
+	if !verifyChallenge(params.Get("code_verifier"), code.Challenge) {
+		return nil, newError("invalid_grant", "code_verifier does not match the code challenge")
+	}
+
+	return p.issue(client.ID, code.Username, grant, code.Scopes)
+}
+
+//refresh rotates a refresh token, optionally narrowing its scopes. Refresh tokens are single use, using one again
+//fails as it has already been replaced
+func (p *Provider) refresh(client *Client, params url.Values) (*TokenResponse, error) {
+	hash := auth.HashToken(params.Get("refresh_token"))
+	token, err := p.store.GetOAuthToken(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil || !token.Refresh || token.ClientID != client.ID || p.now().After(token.Expires) {
+		return nil, newError("invalid_grant", "invalid or expired refresh token")
+	}
+
+	scopes := token.Scopes
+	if requested := params.Get("scope"); requested != "" {
+		scopes, err = parseScopes(requested)
+		if err != nil {
+			return nil, err
+		}
+
+		if !covers(token.Scopes, scopes) {
+			return nil, newError("invalid_scope", "refresh tokens cannot be given scopes they were not granted")
+		}
+	}
+
+	// Taking the token makes sure concurrent requests cannot both rotate it
+	token, err = p.store.TakeOAuthToken(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil {
+		return nil, newError("invalid_grant", "invalid or expired refresh token")
+	}
+
+	return p.issue(client.ID, token.Username, token.Grant, scopes)
+}
+
+func (p *Provider) issue(clientID, username, grant string, scopes []types.Scope) (*TokenResponse, error) {
+	access, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	access = AccessTokenPrefix + access
+	refresh = refreshTokenPrefix + refresh
+	now := p.now()
+
+	err = p.store.CreateOAuthToken(&Token{
+		Hash:     auth.HashToken(access),
+		Grant:    grant,
+		ClientID: clientID,
+		Username: username,
+		Scopes:   scopes,
+		Expires:  now.Add(p.accessTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.store.CreateOAuthToken(&Token{
+		Hash:     auth.HashToken(refresh),
+		Refresh:  true,
+		Grant:    grant,
+		ClientID: clientID,
+		Username: username,
+		Scopes:   scopes,
+		Expires:  now.Add(p.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(p.accessTTL.Seconds()),
+		RefreshToken: refresh,
+		Scope:        formatScopes(scopes),
+	}, nil
+}
+
+//Revoke revokes a token as described in RFC 7009. Revoking a refresh token also revokes the access tokens issued
+//with it. Unknown tokens are ignored
+func (p *Provider) Revoke(token, clientID, clientSecret string) error {
+	client, err := p.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := p.store.GetOAuthToken(auth.HashToken(token))
+	if err != nil || revoked == nil || revoked.ClientID != client.ID {
+		return err
+	}
+
+	if revoked.Refresh {
+		return p.store.RevokeGrant(revoked.Grant)
+	}
+
+	_, err = p.store.TakeOAuthToken(revoked.Hash)
+	return err
+}
+
+//Authenticate returns the access token a bearer token is, or nil if it is not a valid one
+func (p *Provider) Authenticate(token string) (*Token, error) {
+	if !strings.HasPrefix(token, AccessTokenPrefix) {
+		return nil, nil
+	}
+
+	access, err := p.store.GetOAuthToken(auth.HashToken(token))
+	if err != nil || access == nil {
+		return nil, err
+	}
+
+	if access.Refresh || p.now().After(access.Expires) {
+		return nil, nil
+	}
+
+	return access, nil
+}
+
+//Clients returns the clients the user registered
+func (p *Provider) Clients(owner string) ([]*Client, error) {
+	return p.store.ListOAuthClients(owner)
+}
+
+//DeleteClient deletes the user's client, reporting whether it existed
+func (p *Provider) DeleteClient(owner, id string) (bool, error) {
+	return p.store.DeleteOAuthClient(owner, id)
+}
+
+//Consents returns the consents the user gave
+func (p *Provider) Consents(username string) ([]*Consent, error) {
+	return p.store.ListConsents(username)
+}
+
+//Withdraw withdraws the user's consent to the client, revoking its tokens, and reports whether there was one
+func (p *Provider) Withdraw(username, clientID string) (bool, error) {
+	return p.store.DeleteConsent(username, clientID)
+}
+
+//Prune deletes the codes and tokens that have expired
+func (p *Provider) Prune() error {
+	return p.store.PruneOAuth(p.now())
+}
+
+//verifyChallenge checks the code verifier hashes to the S256 code challenge
+func verifyChallenge(verifier, challenge string) bool {
+	if len(verifier) < minVerifierLength || len(verifier) > maxVerifierLength {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+//parseScopes parses a space separated scope parameter. At least one known scope is required
+func parseScopes(scope string) ([]types.Scope, error) {
+	var scopes []types.Scope
+	for _, s := range strings.Fields(scope) {
+		if !types.ValidScope(types.Scope(s)) {
+			return nil, newError("invalid_scope", "unknown scope "+s)
+		}
+		if !hasScope(scopes, types.Scope(s)) {
+			scopes = append(scopes, types.Scope(s))
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, newError("invalid_scope", "at least one scope is required")
+	}
+
+	return scopes, nil
+}
+
+func formatScopes(scopes []types.Scope) string {
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		names = append(names, string(scope))
+	}
+
+	return strings.Join(names, " ")
+}
+
+func hasScope(scopes []types.Scope, scope types.Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+//covers reports whether granted includes every requested scope
+func covers(granted, requested []types.Scope) bool {
+	for _, scope := range requested {
+		if !hasScope(granted, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,276 @@
+package oauth_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/oauth"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testUser     = "producer"
+	testRedirect = "http://127.0.0.1:5000/callback"
+	testVerifier = "dBjftJeZ4CVP-mJ0kxT8P0B3f7uN0L3hFQUHHkzB3Yk-0123456789"
+)
+
+//testClient plays the partner app and the user's browser against the provider's endpoints
+type testClient struct {
+	server   *httptest.Server
+	clientID string
+	secret   string
+}
+
+func newTestServer(provider *oauth.Provider) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/authorize", func(w http.ResponseWriter, req *http.Request) {
+		provider.ServeAuthorize(w, req, testUser)
+	})
+	mux.HandleFunc("/oauth/token", provider.ServeToken)
+	mux.HandleFunc("/oauth/revoke", provider.ServeRevoke)
+	return httptest.NewServer(mux)
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+//authorize asks for the scope and answers the consent prompt, returning the authorization and where the user is
+//sent back to
+func (c *testClient) authorize(scope string, approve bool) (map[string]interface{}, *url.URL) {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {testRedirect},
+		"scope":                 {scope},
+		"state":                 {"xyz"},
+		"code_challenge":        {challenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+	address := c.server.URL + "/oauth/authorize?" + query.Encode()
+
+	response, err := http.Get(address)
+	convey.So(err, convey.ShouldBeNil)
+	defer response.Body.Close()
+	convey.So(response.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+	authorization := map[string]interface{}{}
+	convey.So(json.NewDecoder(response.Body).Decode(&authorization), convey.ShouldBeNil)
+
+	body, _ := json.Marshal(map[string]bool{"approve": approve})
+	answer, err := http.Post(address, "application/json", bytes.NewReader(body))
+	convey.So(err, convey.ShouldBeNil)
+	defer answer.Body.Close()
+
+	redirect := struct {
+		RedirectURI string `json:"redirect_uri"`
+	}{}
+	convey.So(json.NewDecoder(answer.Body).Decode(&redirect), convey.ShouldBeNil)
+
+	u, err := url.Parse(redirect.RedirectURI)
+	convey.So(err, convey.ShouldBeNil)
+	return authorization, u
+}
+
+//codeGrant returns the parameters of a token request exchanging the code, repeating the redirect URI authorize sent
+func codeGrant(code, verifier string) url.Values {
+	return url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {testRedirect},
+	}
+}
+
+//token posts a token request and returns the status code and decoded response
+func (c *testClient) token(params url.Values) (int, map[string]interface{}) {
+	params.Set("client_id", c.clientID)
+	params.Set("client_secret", c.secret)
+	return c.post("/oauth/token", params)
+}
+
+func (c *testClient) post(path string, params url.Values) (int, map[string]interface{}) {
+	response, err := http.Post(c.server.URL+path, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()))
+	convey.So(err, convey.ShouldBeNil)
+	defer response.Body.Close()
+
+	decoded := map[string]interface{}{}
+	_ = json.NewDecoder(response.Body).Decode(&decoded)
+	return response.StatusCode, decoded
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	convey.Convey("Running the authorization code flow...", t, func() {
+		provider := oauth.NewProvider(&config.OAuthConfig{}, oauth.NewMemoryStore())
+		server := newTestServer(provider)
+		defer server.Close()
+
+		client, secret, err := provider.RegisterClient("partner", "DAW plugin", []string{testRedirect}, true)
+		convey.So(err, convey.ShouldBeNil)
+		app := &testClient{server: server, clientID: client.ID, secret: secret}
+
+		authorization, redirect := app.authorize("listings:read sales:read", true)
+		code := redirect.Query().Get("code")
+
+		convey.Convey("The user should be asked for consent the first time only\n", func() {
+			convey.So(authorization["consented"], convey.ShouldBeFalse)
+			convey.So(redirect.Query().Get("state"), convey.ShouldEqual, "xyz")
+			convey.So(code, convey.ShouldNotBeEmpty)
+
+			again, _ := app.authorize("listings:read", true)
+			convey.So(again["consented"], convey.ShouldBeTrue)
+
+			wider, _ := app.authorize("listings:write", true)
+			convey.So(wider["consented"], convey.ShouldBeFalse)
+		})
+
+		convey.Convey("The code should be exchanged for scoped tokens once\n", func() {
+			status, tokens := app.token(codeGrant(code, testVerifier))
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			convey.So(tokens["scope"], convey.ShouldEqual, "listings:read sales:read")
+
+			access, err := provider.Authenticate(tokens["access_token"].(string))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(access.Username, convey.ShouldEqual, testUser)
+			convey.So(access.Allows(types.ScopeReadSales), convey.ShouldBeTrue)
+			convey.So(access.Allows(types.ScopeManageListings), convey.ShouldBeFalse)
+
+			status, response := app.token(codeGrant(code, testVerifier))
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_grant")
+		})
+
+		convey.Convey("Reusing a code should revoke the tokens issued for it\n", func() {
+			_, tokens := app.token(codeGrant(code, testVerifier))
+
+			status, response := app.token(codeGrant(code, testVerifier))
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_grant")
+
+			access, err := provider.Authenticate(tokens["access_token"].(string))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(access, convey.ShouldBeNil)
+
+			status, _ = app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens["refresh_token"].(string)}})
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("The redirect URI sent to authorize should be required and match\n", func() {
+			params := codeGrant(code, testVerifier)
+			params.Del("redirect_uri")
+			status, response := app.token(params)
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_grant")
+
+			_, redirect := app.authorize("listings:read", true)
+			params = codeGrant(redirect.Query().Get("code"), testVerifier)
+			params.Set("redirect_uri", "https://example.com/other")
+			status, response = app.token(params)
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_grant")
+		})
+
+		convey.Convey("A wrong code verifier should be rejected\n", func() {
+			status, response := app.token(codeGrant(code, testVerifier+"x"))
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_grant")
+		})
+
+		convey.Convey("A wrong client secret should be rejected\n", func() {
+			app.secret = "wrong"
+			status, response := app.token(codeGrant(code, testVerifier))
+			convey.So(status, convey.ShouldEqual, http.StatusUnauthorized)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_client")
+		})
+
+		convey.Convey("Refresh tokens should only be narrowed to scopes they were granted\n", func() {
+			_, tokens := app.token(codeGrant(code, testVerifier))
+			refresh := tokens["refresh_token"].(string)
+
+			status, response := app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}, "scope": {"listings:write"}})
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(response["error"], convey.ShouldEqual, "invalid_scope")
+
+			status, _ = app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}, "scope": {"listings:read"}})
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+		})
+
+		convey.Convey("Refreshing should issue new tokens and use the old refresh token up\n", func() {
+			_, tokens := app.token(codeGrant(code, testVerifier))
+			refresh := tokens["refresh_token"].(string)
+
+			status, refreshed := app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}, "scope": {"sales:read"}})
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+			convey.So(refreshed["scope"], convey.ShouldEqual, "sales:read")
+
+			status, _ = app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refresh}})
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("Revoking a refresh token should revoke its access tokens\n", func() {
+			_, tokens := app.token(codeGrant(code, testVerifier))
+
+			status, _ := app.post("/oauth/revoke", url.Values{"token": {tokens["refresh_token"].(string)}, "client_id": {app.clientID}, "client_secret": {app.secret}})
+			convey.So(status, convey.ShouldEqual, http.StatusOK)
+
+			access, err := provider.Authenticate(tokens["access_token"].(string))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(access, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Withdrawing consent should revoke the client's tokens\n", func() {
+			_, tokens := app.token(codeGrant(code, testVerifier))
+
+			withdrawn, err := provider.Withdraw(testUser, client.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(withdrawn, convey.ShouldBeTrue)
+
+			access, _ := provider.Authenticate(tokens["access_token"].(string))
+			convey.So(access, convey.ShouldBeNil)
+
+			status, _ := app.token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens["refresh_token"].(string)}})
+			convey.So(status, convey.ShouldEqual, http.StatusBadRequest)
+		})
+
+		convey.Convey("Denying the request should send the user back with an error\n", func() {
+			_, denied := app.authorize("listings:read", false)
+			convey.So(denied.Query().Get("error"), convey.ShouldEqual, "access_denied")
+			convey.So(denied.Query().Get("code"), convey.ShouldBeEmpty)
+		})
+	})
+}
+
+func TestClientRegistration(t *testing.T) {
+	convey.Convey("Registering clients...", t, func() {
+		provider := oauth.NewProvider(&config.OAuthConfig{}, oauth.NewMemoryStore())
+
+		convey.Convey("Public clients should get no secret\n", func() {
+			client, secret, err := provider.RegisterClient("partner", "Storefront", []string{"https://shop.example.com/callback"}, false)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(secret, convey.ShouldBeEmpty)
+			convey.So(client.Confidential, convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Unsafe redirect URIs should be rejected\n", func() {
+			for _, uri := range []string{"http://example.com/callback", "https://example.com/#fragment", "javascript:alert(1)", "myapp:/callback", "/relative"} {
+				_, _, err := provider.RegisterClient("partner", "App", []string{uri}, false)
+				convey.So(err, convey.ShouldNotBeNil)
+			}
+		})
+
+		convey.Convey("Loopback and private-use redirect URIs should be accepted for native apps\n", func() {
+			_, _, err := provider.RegisterClient("partner", "Plugin", []string{"http://localhost:8080/cb", "com.example.plugin:/callback"}, false)
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
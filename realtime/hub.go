@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"sync"
+)
+
+//subscriptionBuffer is how many events a subscriber can fall behind before further events are dropped for it
+const subscriptionBuffer = 32
+
+//Event is pushed to connected users as it happens
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+//Subscription receives the events published to a user while it is open
+type Subscription struct {
+	Events <-chan *Event
+
+	events chan *Event
+	hub    *Hub
+	user   string
+}
+
+//Hub delivers events to the subscriptions of connected users. It only knows about the connections to this instance,
+//so clients are expected to catch up over REST after reconnecting
+type Hub struct {
+	mu            sync.RWMutex
+	subscriptions map[string]map[*Subscription]bool
+	closed        bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscriptions: make(map[string]map[*Subscription]bool)}
+}
+
+//Subscribe opens a subscription to the events published to user. It must be closed once the user disconnects. The
+//subscriptions of a closed hub are closed from the start
+func (h *Hub) Subscribe(user string) *Subscription {
+	events := make(chan *Event, subscriptionBuffer)
+	subscription := &Subscription{Events: events, events: events, hub: h, user: user}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(events)
+		return subscription
+	}
+
+	if h.subscriptions[user] == nil {
+		h.subscriptions[user] = make(map[*Subscription]bool)
+	}
+	h.subscriptions[user][subscription] = true
+
+	return subscription
+}
+
+//Close removes the subscription from its hub and closes its Events channel
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if !s.hub.subscriptions[s.user][s] {
+		return
+	}
+
+	delete(s.hub.subscriptions[s.user], s)
+	if len(s.hub.subscriptions[s.user]) == 0 {
+		delete(s.hub.subscriptions, s.user)
+	}
+	close(s.events)
+}
+
+//Close closes every subscription, which ends the connections they were opened for, and any opened later. It is
+//called when shutting down so long-lived connections do not hold up draining the server
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for user, subscriptions := range h.subscriptions {
+		for subscription := range subscriptions {
+			close(subscription.events)
+		}
+		delete(h.subscriptions, user)
+	}
+}
+
+//Publish sends the event to every open subscription of the given users. Subscribers that are too far behind miss the
+//event rather than holding up everyone else
+func (h *Hub) Publish(users []string, event *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, user := range users {
+		for subscription := range h.subscriptions[user] {
+			select {
+			case subscription.events <- event:
+			default:
+			}
+		}
+	}
+}
+
+//Connected reports whether the user has at least one open subscription
+func (h *Hub) Connected(user string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscriptions[user]) > 0
+}
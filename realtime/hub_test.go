@@ -0,0 +1,63 @@
+package realtime_test
+
+import (
+	"testing"
+
+	"github.com/danny-m08/music-match/realtime"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestHub(t *testing.T) {
+	convey.Convey("Given a hub with subscribers", t, func() {
+		hub := realtime.NewHub()
+		first := hub.Subscribe("first")
+		second := hub.Subscribe("second")
+
+		convey.Convey("Events are only delivered to the users they are published to\n", func() {
+			hub.Publish([]string{"first"}, &realtime.Event{Type: "message"})
+
+			convey.So((<-first.Events).Type, convey.ShouldEqual, "message")
+			convey.So(len(second.Events), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Every subscription of a user receives the event\n", func() {
+			other := hub.Subscribe("first")
+			hub.Publish([]string{"first"}, &realtime.Event{Type: "read"})
+
+			convey.So((<-first.Events).Type, convey.ShouldEqual, "read")
+			convey.So((<-other.Events).Type, convey.ShouldEqual, "read")
+		})
+
+		convey.Convey("Closed subscriptions stop receiving events\n", func() {
+			second.Close()
+			second.Close()
+			hub.Publish([]string{"second"}, &realtime.Event{Type: "message"})
+
+			_, open := <-second.Events
+			convey.So(open, convey.ShouldBeFalse)
+			convey.So(hub.Connected("second"), convey.ShouldBeFalse)
+			convey.So(hub.Connected("first"), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Slow subscribers drop events instead of blocking\n", func() {
+			for i := 0; i < cap(first.Events)+5; i++ {
+				hub.Publish([]string{"first"}, &realtime.Event{Type: "message"})
+			}
+
+			convey.So(len(first.Events), convey.ShouldEqual, cap(first.Events))
+		})
+
+		convey.Convey("Closing the hub closes every subscription, including later ones\n", func() {
+			hub.Close()
+			first.Close()
+
+			_, open := <-first.Events
+			convey.So(open, convey.ShouldBeFalse)
+			_, open = <-second.Events
+			convey.So(open, convey.ShouldBeFalse)
+			_, open = <-hub.Subscribe("third").Events
+			convey.So(open, convey.ShouldBeFalse)
+			convey.So(hub.Connected("first"), convey.ShouldBeFalse)
+		})
+	})
+}
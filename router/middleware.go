@@ -0,0 +1,252 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//RequestIDHeader carries the ID of a request, both ways
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey contextKey = "request-id"
+
+//validRequestID limits the IDs taken from clients to ones that are safe to log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+//RequestID gives every request an ID, keeping the one a proxy or client sent when it is safe to log, and writes it
+//back in the response. It can be read with RequestIDFromContext
+func RequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next(w, req.WithContext(context.WithValue(req.Context(), requestIDKey, id)))
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+//RequestIDFromContext returns the ID RequestID gave the request, or an empty string if it did not run
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+//Trace continues the trace of a caller sending a W3C traceparent header, or starts a new one, with a span for the
+//request named after the route it matched. Handlers find the span in the request context
+func Trace(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracing.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			attribute.String("http.request_id", RequestIDFromContext(ctx)),
+		))
+		defer span.End()
+
+		recorder := record(w)
+		next(recorder, req.WithContext(ctx))
+
+		if pattern := Pattern(req); pattern != "" {
+			span.SetName(req.Method + " " + pattern)
+			span.SetAttributes(semconv.HTTPRoute(pattern))
+		}
+		span.SetAttributes(semconv.HTTPStatusCode(recorder.status()))
+		if recorder.status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status()))
+		}
+	}
+}
+
+//LogRequests puts a logger carrying the request and trace IDs in the request context, for handlers to log through
+//logging.FromContext, and logs every request once it was served. Only the path is logged as query strings can carry
+//tokens
+func LogRequests(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		logger := logging.Default().With("request_id", RequestIDFromContext(req.Context()))
+		if traceID := tracing.TraceID(req.Context()); traceID != "" {
+			logger = logger.With("trace_id", traceID)
+		}
+
+		recorder := record(w)
+		next(recorder, req.WithContext(logging.NewContext(req.Context(), logger)))
+
+		logger.Info("Request served", "method", req.Method, "path", req.URL.Path, "route", Pattern(req),
+			"status", recorder.status(), "duration", time.Since(start))
+	}
+}
+
+//Instrument records the count and latency of requests by the route pattern they matched, method and status
+func Instrument(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		recorder := record(w)
+		next(recorder, req)
+
+		metrics.ObserveRequest(Pattern(req), req.Method, recorder.status(), time.Since(start))
+	}
+}
+
+//Recover turns panics in handlers into 500 responses instead of dropped connections, logging the stack trace
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		recorder := record(w)
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			} else if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			logging.FromContext(req.Context()).Error("Panic serving request", "method", req.Method,
+				"path", req.URL.Path, "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+			trace.SpanFromContext(req.Context()).RecordError(fmt.Errorf("panic: %v", err))
+			if recorder.code == 0 {
+				errs.Write(recorder, errs.ErrInternal)
+			}
+		}()
+
+		next(recorder, req)
+	}
+}
+
+//CORS lets browsers call the API from the configured origins and answers their preflight requests. Bearer tokens
+//are used rather than cookies, so credentials are not allowed
+func CORS(conf *config.CORSConfig) Middleware {
+	origins := make(map[string]bool)
+	var maxAge time.Duration
+	if conf != nil {
+		for _, origin := range conf.AllowedOrigins {
+			origins[strings.TrimSuffix(origin, "/")] = true
+		}
+		maxAge = conf.MaxAge
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if len(origins) == 0 || origin == "" {
+				next(w, req)
+				return
+			}
+
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			if !origins["*"] && !origins[origin] {
+				next(w, req)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Origin", origin)
+			if req.Method != http.MethodOptions || req.Header.Get("Access-Control-Request-Method") == "" {
+				header.Set("Access-Control-Expose-Headers", RequestIDHeader)
+				next(w, req)
+				return
+			}
+
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+RequestIDHeader)
+			if maxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+//responseRecorder remembers the status code written through it. It passes flushes and hijacks through so event
+//streams and WebSockets keep working behind the middleware
+type responseRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+//record wraps w in a responseRecorder unless it is one already
+func record(w http.ResponseWriter) *responseRecorder {
+	if recorder, ok := w.(*responseRecorder); ok {
+		return recorder
+	}
+
+	return &responseRecorder{ResponseWriter: w}
+}
+
+func (r *responseRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+
+	return r.code
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) Flush() {
+	flusher, ok := r.ResponseWriter.(http.Flusher)
+	if !ok {
+		return
+	}
+
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	flusher.Flush()
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	r.code = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
@@ -0,0 +1,255 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/danny-m08/music-match/errs"
+)
+
+//Middleware wraps a handler, doing its work before and/or after calling next or answering the request itself
+type Middleware func(next http.HandlerFunc) http.HandlerFunc
+
+//Chain composes middleware into one. The first middleware is the outermost, so it sees the request first
+func Chain(middleware ...Middleware) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+//Router dispatches requests to the handler registered for their method and path. Patterns are matched segment by
+//segment, a segment written as {name} matches any single segment whose value is read with Param. Static segments take
+//precedence over parameters, so /listings/new is matched before /listings/{id}, unless only the pattern with the
+//parameter is registered for the request's method
+type Router struct {
+	root       *node
+	middleware []Middleware
+}
+
+type node struct {
+	static  map[string]*node
+	param   *node
+	name    string
+	pattern string
+	names   []string
+	methods map[string]http.HandlerFunc
+	allow   string
+}
+
+type contextKey string
+
+const routeKey contextKey = "route"
+
+//route is what the router found for a request. It is put in the request context before the middleware registered
+//with Use runs, so they can read the pattern once the request was dispatched
+type route struct {
+	pattern string
+	params  map[string]string
+}
+
+func New() *Router {
+	return &Router{root: &node{}}
+}
+
+//Use adds middleware run for every request, including those answered with 404 or 405
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+func (r *Router) Get(pattern string, handler http.HandlerFunc, middleware ...Middleware) {
+	r.Handle(http.MethodGet, pattern, handler, middleware...)
+}
+
+func (r *Router) Post(pattern string, handler http.HandlerFunc, middleware ...Middleware) {
+	r.Handle(http.MethodPost, pattern, handler, middleware...)
+}
+
+func (r *Router) Put(pattern string, handler http.HandlerFunc, middleware ...Middleware) {
+	r.Handle(http.MethodPut, pattern, handler, middleware...)
+}
+
+func (r *Router) Delete(pattern string, handler http.HandlerFunc, middleware ...Middleware) {
+	r.Handle(http.MethodDelete, pattern, handler, middleware...)
+}
+
+//Handle registers the handler for requests with the method whose path matches the pattern, wrapped in the middleware.
+//It panics if the pattern is malformed, was already registered for the method or names a parameter differently than
+//an overlapping pattern does
+func (r *Router) Handle(method, pattern string, handler http.HandlerFunc, middleware ...Middleware) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("router: pattern %q must start with /", pattern))
+	}
+
+	n := r.root
+	var names []string
+	for _, segment := range split(pattern) {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			if n.static[segment] == nil {
+				n.static[segment] = &node{}
+			}
+			n = n.static[segment]
+			continue
+		}
+
+		name := segment[1 : len(segment)-1]
+		if name == "" {
+			panic(fmt.Sprintf("router: pattern %q has an unnamed parameter", pattern))
+		}
+
+		if n.param == nil {
+			n.param = &node{name: name}
+		} else if n.param.name != name {
+			panic(fmt.Sprintf("router: parameter {%s} in %q conflicts with {%s}", name, pattern, n.param.name))
+		}
+		n = n.param
+		names = append(names, name)
+	}
+
+	if n.methods == nil {
+		n.methods = make(map[string]http.HandlerFunc)
+	}
+	if n.methods[method] != nil {
+		panic(fmt.Sprintf("router: %s %s registered twice", method, pattern))
+	}
+
+	n.pattern = pattern
+	n.names = names
+	n.methods[method] = Chain(middleware...)(handler)
+	n.allow = allowed(n.methods)
+}
+
+//allowed lists the methods for the Allow header. HEAD is answered by GET handlers and OPTIONS by the router
+func allowed(methods map[string]http.HandlerFunc) string {
+	list := []string{http.MethodOptions}
+	for method := range methods {
+		list = append(list, method)
+	}
+	if methods[http.MethodGet] != nil && methods[http.MethodHead] == nil {
+		list = append(list, http.MethodHead)
+	}
+
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	req = req.WithContext(context.WithValue(req.Context(), routeKey, &route{}))
+	Chain(r.middleware...)(r.dispatch)(w, req)
+}
+
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) {
+	n, values := r.root.match(req.Method, split(req.URL.EscapedPath()), nil)
+	if n == nil {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Not found"))
+		return
+	}
+
+	found := req.Context().Value(routeKey).(*route)
+	found.pattern = n.pattern
+	found.params = make(map[string]string, len(values))
+	for i, value := range values {
+		unescaped, err := url.PathUnescape(value)
+		if err != nil {
+			errs.Write(w, errs.New(errs.CodeBadRequest, "Malformed path"))
+			return
+		}
+		found.params[n.names[i]] = unescaped
+	}
+
+	handler := n.handler(req.Method)
+	if handler == nil {
+		w.Header().Set("Allow", n.allow)
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		errs.Write(w, errs.New(errs.CodeMethodNotAllowed, "Method not allowed"))
+		return
+	}
+
+	handler(w, req)
+}
+
+//match finds the node registered for the segments, trying static children before the parameter so a more specific
+//pattern wins, and returns it with the values of the parameters on the way. Nodes handling the method are preferred,
+//so DELETE /listings/new reaches /listings/{id} when only GET is registered for /listings/new. If none does, the
+//most specific node is returned so its methods can be listed in the 405
+func (n *node) match(method string, segments, values []string) (*node, []string) {
+	if len(segments) == 0 {
+		if n.methods == nil {
+			return nil, nil
+		}
+		return n, values
+	}
+
+	var fallback *node
+	var fallbackValues []string
+	if child := n.static[segments[0]]; child != nil {
+		found, params := child.match(method, segments[1:], values)
+		if found != nil && found.handler(method) != nil {
+			return found, params
+		}
+		fallback, fallbackValues = found, params
+	}
+
+	if n.param != nil && segments[0] != "" {
+		// Appending to a copy keeps the values of the fallback from being overwritten
+		found, params := n.param.match(method, segments[1:], append(values[:len(values):len(values)], segments[0]))
+		if found != nil && (fallback == nil || found.handler(method) != nil) {
+			return found, params
+		}
+	}
+
+	return fallback, fallbackValues
+}
+
+//handler returns the handler registered for the method, HEAD being answered by the GET handler, or nil if there is
+//none
+func (n *node) handler(method string) http.HandlerFunc {
+	handler := n.methods[method]
+	if handler == nil && method == http.MethodHead {
+		handler = n.methods[http.MethodGet]
+	}
+
+	return handler
+}
+
+//Param returns the value of the named path parameter, or an empty string if the matched pattern has none
+func Param(req *http.Request, name string) string {
+	found, _ := req.Context().Value(routeKey).(*route)
+	if found == nil {
+		return ""
+	}
+
+	return found.params[name]
+}
+
+//Pattern returns the pattern the request matched, or an empty string if it matched none or was not dispatched yet
+func Pattern(req *http.Request) string {
+	found, _ := req.Context().Value(routeKey).(*route)
+	if found == nil {
+		return ""
+	}
+
+	return found.pattern
+}
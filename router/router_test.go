@@ -0,0 +1,184 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/router"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+//respond writes the matched pattern and the named parameter so tests can tell which route served a request
+func respond(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(router.Pattern(req) + " " + router.Param(req, name)))
+	}
+}
+
+func serve(r http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRouting(t *testing.T) {
+	convey.Convey("Routing requests...", t, func() {
+		r := router.New()
+		r.Get("/listings", respond("id"))
+		r.Post("/listings", respond("id"))
+		r.Get("/listings/{id}", respond("id"))
+		r.Get("/listings/new", respond("id"))
+		r.Delete("/listings/{id}", respond("id"))
+		r.Get("/users/{username}/followers", respond("username"))
+		r.Delete("/users/{username}", respond("username"))
+
+		convey.Convey("Path parameters should be extracted and unescaped\n", func() {
+			response := serve(r, http.MethodGet, "/listings/abc123", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(response.Body.String(), convey.ShouldEqual, "/listings/{id} abc123")
+
+			response = serve(r, http.MethodGet, "/users/dj%20shadow/followers", nil)
+			convey.So(response.Body.String(), convey.ShouldEqual, "/users/{username}/followers dj shadow")
+		})
+
+		convey.Convey("Static segments should take precedence over parameters\n", func() {
+			response := serve(r, http.MethodGet, "/listings/new", nil)
+			convey.So(response.Body.String(), convey.ShouldEqual, "/listings/new ")
+		})
+
+		convey.Convey("Parameters should match when the static segment lacks the method\n", func() {
+			response := serve(r, http.MethodDelete, "/listings/new", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(response.Body.String(), convey.ShouldEqual, "/listings/{id} new")
+
+			response = serve(r, http.MethodPost, "/listings/new", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+			convey.So(response.Header().Get("Allow"), convey.ShouldEqual, "GET, HEAD, OPTIONS")
+		})
+
+		convey.Convey("Unknown paths should be answered with 404\n", func() {
+			convey.So(serve(r, http.MethodGet, "/nowhere", nil).Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve(r, http.MethodGet, "/listings/abc/extra", nil).Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(serve(r, http.MethodGet, "/users//followers", nil).Code, convey.ShouldEqual, http.StatusNotFound)
+		})
+
+		convey.Convey("Other methods should be answered with 405 and the allowed ones\n", func() {
+			response := serve(r, http.MethodDelete, "/listings", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+			convey.So(response.Header().Get("Allow"), convey.ShouldEqual, "GET, HEAD, OPTIONS, POST")
+
+			response = serve(r, http.MethodGet, "/users/someone", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+			convey.So(response.Header().Get("Allow"), convey.ShouldEqual, "DELETE, OPTIONS")
+		})
+
+		convey.Convey("OPTIONS and HEAD should be answered for registered paths\n", func() {
+			response := serve(r, http.MethodOptions, "/listings", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusNoContent)
+			convey.So(response.Header().Get("Allow"), convey.ShouldEqual, "GET, HEAD, OPTIONS, POST")
+
+			convey.So(serve(r, http.MethodHead, "/listings/abc", nil).Code, convey.ShouldEqual, http.StatusOK)
+		})
+
+		convey.Convey("Conflicting registrations should panic\n", func() {
+			convey.So(func() { r.Get("/listings", respond("id")) }, convey.ShouldPanic)
+			convey.So(func() { r.Get("/listings/{listing}/comments", respond("id")) }, convey.ShouldPanic)
+			convey.So(func() { r.Get("listings", respond("id")) }, convey.ShouldPanic)
+		})
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	convey.Convey("Running middleware...", t, func() {
+		var order []string
+		trace := func(name string) router.Middleware {
+			return func(next http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, req *http.Request) {
+					order = append(order, name)
+					next(w, req)
+				}
+			}
+		}
+
+		r := router.New()
+		r.Use(router.RequestID, router.Recover, trace("global"))
+		r.Get("/ok", func(w http.ResponseWriter, req *http.Request) {
+			order = append(order, "handler")
+			_, _ = w.Write([]byte(router.RequestIDFromContext(req.Context())))
+		}, trace("outer"), trace("inner"))
+		r.Get("/panic", func(w http.ResponseWriter, req *http.Request) {
+			panic("boom")
+		})
+
+		convey.Convey("Middleware should run outermost first, global before per-route\n", func() {
+			serve(r, http.MethodGet, "/ok", nil)
+			convey.So(order, convey.ShouldResemble, []string{"global", "outer", "inner", "handler"})
+		})
+
+		convey.Convey("Global middleware should run for unmatched requests too\n", func() {
+			response := serve(r, http.MethodGet, "/missing", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusNotFound)
+			convey.So(order, convey.ShouldResemble, []string{"global"})
+		})
+
+		convey.Convey("Requests should get an ID, keeping a safe one sent by the client\n", func() {
+			response := serve(r, http.MethodGet, "/ok", nil)
+			id := response.Header().Get(router.RequestIDHeader)
+			convey.So(id, convey.ShouldHaveLength, 32)
+			convey.So(response.Body.String(), convey.ShouldEqual, id)
+
+			response = serve(r, http.MethodGet, "/ok", http.Header{router.RequestIDHeader: {"edge-42"}})
+			convey.So(response.Header().Get(router.RequestIDHeader), convey.ShouldEqual, "edge-42")
+
+			response = serve(r, http.MethodGet, "/ok", http.Header{router.RequestIDHeader: {"bad id\n"}})
+			convey.So(response.Header().Get(router.RequestIDHeader), convey.ShouldNotEqual, "bad id\n")
+		})
+
+		convey.Convey("Panics should be answered with 500\n", func() {
+			response := serve(r, http.MethodGet, "/panic", nil)
+			convey.So(response.Code, convey.ShouldEqual, http.StatusInternalServerError)
+		})
+	})
+}
+
+func TestCORS(t *testing.T) {
+	convey.Convey("Answering cross-origin requests...", t, func() {
+		r := router.New()
+		r.Use(router.CORS(&config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: 10 * time.Minute}))
+		r.Get("/listings", respond("id"))
+
+		convey.Convey("Allowed origins should be answered with CORS headers\n", func() {
+			response := serve(r, http.MethodGet, "/listings", http.Header{"Origin": {"https://app.example.com"}})
+			convey.So(response.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(response.Header().Get("Access-Control-Allow-Origin"), convey.ShouldEqual, "https://app.example.com")
+			convey.So(response.Header().Get("Access-Control-Allow-Credentials"), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Other origins should get no CORS headers\n", func() {
+			response := serve(r, http.MethodGet, "/listings", http.Header{"Origin": {"https://evil.example.com"}})
+			convey.So(response.Header().Get("Access-Control-Allow-Origin"), convey.ShouldBeEmpty)
+			convey.So(response.Header().Get("Vary"), convey.ShouldEqual, "Origin")
+		})
+
+		convey.Convey("Preflight requests should be answered without reaching the route\n", func() {
+			response := serve(r, http.MethodOptions, "/listings", http.Header{
+				"Origin":                        {"https://app.example.com"},
+				"Access-Control-Request-Method": {"POST"},
+			})
+			convey.So(response.Code, convey.ShouldEqual, http.StatusNoContent)
+			convey.So(response.Header().Get("Access-Control-Allow-Methods"), convey.ShouldContainSubstring, "POST")
+			convey.So(response.Header().Get("Access-Control-Allow-Headers"), convey.ShouldContainSubstring, "Authorization")
+			convey.So(response.Header().Get("Access-Control-Max-Age"), convey.ShouldEqual, "600")
+		})
+	})
+}
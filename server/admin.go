@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/types"
+)
+
+//permitted only passes requests of users holding the permission on. It has to run after authenticate
+func (server *server) permitted(permission auth.Permission) router.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			if !auth.Can(userFromContext(req.Context()), permission) {
+				errs.Write(w, errs.New(errs.CodePermissionDenied, "Permission denied"))
+				return
+			}
+
+			next(w, req)
+		}
+	}
+}
+
+//newAuditEntry starts the audit log entry of an action the actor takes on target
+func newAuditEntry(actor *types.User, action types.AuditAction, target, reason string) *types.AuditEntry {
+	return &types.AuditEntry{
+		ID:      types.GenerateID(),
+		Actor:   actor.Username,
+		Action:  action,
+		Target:  target,
+		Reason:  reason,
+		Created: time.Now(),
+	}
+}
+
+//audit records a read made with staff permissions. Reads are served even if the entry cannot be written, the failure
+//is logged instead
+func (server *server) audit(ctx context.Context, actor *types.User, action types.AuditAction, details string) {
+	entry := newAuditEntry(actor, action, "", "")
+	entry.Details = details
+
+	err := server.db(ctx).RecordAudit(entry)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to record in the audit log", "action", action, "actor", actor.Username,
+			"error", err)
+	}
+}
+
+//grantAdmins gives the admin role to the users named in the config so a fresh deployment has someone to grant the
+//staff roles
+func (server *server) grantAdmins(usernames []string) {
+	for _, username := range usernames {
+		err := server.neo4jClient.GrantRole(username, types.RoleAdmin)
+		if errors.Is(err, neo4j.ErrNotFound) {
+			logging.Warn("Configured admin does not exist", "username", username)
+		} else if err != nil {
+			logging.Error("Unable to grant the admin role", "username", username, "error", err)
+		}
+	}
+}
+
+//searchUsers writes a page of accounts ordered by username, optionally filtered by the q query parameter
+func (server *server) searchUsers(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	query := strings.TrimSpace(req.URL.Query().Get("q"))
+
+	after, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	accounts, err := server.db(req.Context()).SearchUsers(query, after, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to search users", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.audit(req.Context(), user, types.AuditListUsers, "q="+query)
+
+	response := AccountsResponse{Users: accounts}
+	if len(accounts) > limit {
+		response.Users = accounts[:limit]
+		response.NextCursor = encodeCursor(accounts[limit-1].Username)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (server *server) suspendUser(w http.ResponseWriter, req *http.Request) {
+	server.moderateUser(w, req, types.AuditSuspendUser, server.db(req.Context()).SuspendUser)
+}
+
+func (server *server) reinstateUser(w http.ResponseWriter, req *http.Request) {
+	server.moderateUser(w, req, types.AuditReinstateUser, server.db(req.Context()).ReinstateUser)
+}
+
+type moderateUserFunc func(username string, entry *types.AuditEntry) error
+
+//moderateUser suspends or reinstates the user named in the request. Staff can only act on users ranking below them
+func (server *server) moderateUser(w http.ResponseWriter, req *http.Request, action types.AuditAction, moderate moderateUserFunc) {
+	actor := userFromContext(req.Context())
+	request := &moderationRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	if !server.outranks(w, req, actor, request.Username) {
+		return
+	}
+
+	err = moderate(request.Username, newAuditEntry(actor, action, "user:"+request.Username, request.Reason))
+	if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeConflict, "Account already suspended"))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) && action == types.AuditReinstateUser {
+		errs.Write(w, errs.New(errs.CodeConflict, "Account is not suspended"))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to moderate user", "action", action,
+			"username", request.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//setRoles replaces the staff and seller roles of the user named in the request
+func (server *server) setRoles(w http.ResponseWriter, req *http.Request) {
+	actor := userFromContext(req.Context())
+	request := &SetRolesRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	names := make([]string, 0, len(request.Roles))
+	for _, role := range request.Roles {
+		names = append(names, string(role))
+	}
+
+	if !server.outranks(w, req, actor, request.Username) {
+		return
+	}
+
+	entry := newAuditEntry(actor, types.AuditSetRoles, "user:"+request.Username, "")
+	entry.Details = "roles=" + strings.Join(names, ",")
+
+	err = server.db(req.Context()).SetRoles(request.Username, request.Roles, entry)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to set roles", "username", request.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//outranks checks the actor ranks above the user with the given username, writing the error response if not
+func (server *server) outranks(w http.ResponseWriter, req *http.Request, actor *types.User, username string) bool {
+	target, err := server.db(req.Context()).GetAccount(username)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve account", "username", username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return false
+	}
+
+	if target == nil {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return false
+	}
+
+	if !auth.Outranks(actor, &types.User{Username: target.Username, Roles: target.Roles}) {
+		errs.Write(w, errs.New(errs.CodePermissionDenied, "Permission denied"))
+		return false
+	}
+
+	return true
+}
+
+//removeListing takes down the listing named in the request
+func (server *server) removeListing(w http.ResponseWriter, req *http.Request) {
+	actor := userFromContext(req.Context())
+	request := &moderationRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	entry := newAuditEntry(actor, types.AuditRemoveListing, "listing:"+request.ListingID, request.Reason)
+	err = server.db(req.Context()).RemoveListing(request.ListingID, entry)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to remove listing", "listing_id", request.ListingID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//getTransactions writes a page of sales across the marketplace, newest first, optionally only those of the user
+//named in the username query parameter
+func (server *server) getTransactions(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	username := req.URL.Query().Get("username")
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	before, beforeID, err := newestFirst(position)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	sales, err := server.db(req.Context()).ListSales(username, before, beforeID, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list transactions", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.audit(req.Context(), user, types.AuditViewTransactions, "username="+username)
+
+	response := SalesResponse{Sales: sales}
+	if len(sales) > limit {
+		last := sales[limit-1]
+		response.Sales = sales[:limit]
+		response.NextCursor = timeCursor(last.Tx.Date, last.Tx.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//getAuditLog writes a page of the audit log, newest first, optionally only the entries of the user named in the
+//actor query parameter
+func (server *server) getAuditLog(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	actor := req.URL.Query().Get("actor")
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	before, beforeID, err := newestFirst(position)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	entries, err := server.db(req.Context()).ListAuditLog(actor, before, beforeID, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list the audit log", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.audit(req.Context(), user, types.AuditViewLog, "actor="+actor)
+
+	response := AuditLogResponse{Entries: entries}
+	if len(entries) > limit {
+		last := entries[limit-1]
+		response.Entries = entries[:limit]
+		response.NextCursor = timeCursor(last.Created, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
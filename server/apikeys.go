@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	maxAPIKeys          = 20
+	maxAPIKeyNameLength = 100
+)
+
+//createAPIKey creates a named key with the requested scopes and writes it. The key cannot be retrieved again
+func (server *server) createAPIKey(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &CreateAPIKeyRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	token, err := auth.NewAPIKey()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate API key", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	key := &types.APIKey{
+		ID:      types.GenerateID(),
+		Name:    name,
+		Prefix:  auth.KeyPrefix(token),
+		Scopes:  request.Scopes,
+		Created: time.Now(),
+	}
+
+	err = server.db(req.Context()).CreateAPIKey(user, key, auth.HashToken(token), maxAPIKeys)
+	if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeAlreadyExists, "An API key with this name already exists"))
+		return
+	} else if errors.Is(err, neo4j.ErrLimitReached) {
+		errs.Write(w, errs.New(errs.CodeLimitReached, fmt.Sprintf("Users can have at most %d API keys", maxAPIKeys)))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create API key", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, APIKeyResponse{APIKey: key, Key: token})
+}
+
+func (server *server) getAPIKeys(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	keys, err := server.db(req.Context()).ListAPIKeys(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list API keys", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIKeysResponse{Keys: keys})
+}
+
+func (server *server) revokeAPIKey(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	id := pathParam(req, "id")
+
+	err := server.db(req.Context()).RevokeAPIKey(user, id)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "API key not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to revoke API key", "key_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/oauth"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/types"
+	"github.com/gorilla/websocket"
+)
+
+type contextKey string
+
+const (
+	userKey      contextKey = "user"
+	scopeKey     contextKey = "scope"
+	bearerPrefix            = "Bearer "
+)
+
+//errScopeDenied is returned for API keys and OAuth access tokens used on endpoints that do not accept them or need a
+//scope they lack
+var errScopeDenied = errs.New(errs.CodeInsufficientScope, "Token not allowed to access this endpoint")
+
+//authenticate only passes requests carrying a valid session token, API key or OAuth access token of an account that
+//is not suspended on to next. The authenticated user can be retrieved from the request context with userFromContext
+func (s *server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token := bearerToken(req)
+		if token == "" {
+			errs.Write(w, errs.New(errs.CodeUnauthorized, "Authentication required"))
+			return
+		}
+
+		user, err := s.tokenUser(req, token)
+		if errors.Is(err, errScopeDenied) {
+			errs.Write(w, err)
+			return
+		} else if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to retrieve session", "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		if user == nil {
+			errs.Write(w, errs.New(errs.CodeUnauthorized, "Invalid or expired session"))
+			return
+		}
+
+		if user.Suspended {
+			errs.Write(w, errs.New(errs.CodeAccountSuspended, "Account suspended"))
+			return
+		}
+
+		next(w, req.WithContext(withUser(req.Context(), user)))
+	}
+}
+
+//identify passes every request on to next, setting the user in the request context when it carries a valid session
+//token, API key or OAuth access token. It is used by endpoints anyone can read but whose results depend on who is
+//reading
+func (s *server) identify(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token := bearerToken(req)
+		if token == "" {
+			next(w, req)
+			return
+		}
+
+		user, err := s.tokenUser(req, token)
+		if errors.Is(err, errScopeDenied) {
+			errs.Write(w, err)
+			return
+		} else if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to retrieve session", "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		if user != nil && !user.Suspended {
+			req = req.WithContext(withUser(req.Context(), user))
+		}
+		next(w, req)
+	}
+}
+
+//keyScope lets API keys and OAuth access tokens granted the scope through. Endpoints not wrapped in it only accept
+//session tokens. It has to run before authenticate or identify
+func keyScope(scope types.Scope) router.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			next(w, req.WithContext(context.WithValue(req.Context(), scopeKey, scope)))
+		}
+	}
+}
+
+//tokenUser returns the user a session token, API key or OAuth access token belongs to, or nil if there is none. API
+//keys and access tokens are only accepted when they were granted the scope the endpoint declared with keyScope
+func (s *server) tokenUser(req *http.Request, token string) (*types.User, error) {
+	scope, _ := req.Context().Value(scopeKey).(types.Scope)
+
+	if strings.HasPrefix(token, oauth.AccessTokenPrefix) {
+		access, err := s.oauth.Authenticate(token)
+		if err != nil || access == nil {
+			return nil, err
+		}
+
+		if scope == "" || !access.Allows(scope) {
+			return nil, errScopeDenied
+		}
+
+		return s.db(req.Context()).GetUser(&types.User{Username: access.Username})
+	}
+
+	if !auth.IsAPIKey(token) {
+		return s.db(req.Context()).GetSessionUser(auth.HashToken(token))
+	}
+
+	user, key, err := s.db(req.Context()).UseAPIKey(auth.HashToken(token), time.Now())
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	if scope == "" || !key.Allows(scope) {
+		return nil, errScopeDenied
+	}
+
+	return user, nil
+}
+
+//withUser sets the user in ctx for userFromContext, and adds them to the fields of the request's logger
+func withUser(ctx context.Context, user *types.User) context.Context {
+	ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("user", user.Username))
+	return context.WithValue(ctx, userKey, user)
+}
+
+//userFromContext returns the user set by authenticate or identify, or nil if there is none
+func userFromContext(ctx context.Context) *types.User {
+	user, _ := ctx.Value(userKey).(*types.User)
+	return user
+}
+
+//queryToken lets browsers, which cannot set headers on EventSource requests, pass the session token as the token
+//query parameter instead
+func queryToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if token := req.URL.Query().Get("token"); token != "" && req.Header.Get("Authorization") == "" {
+			req.Header.Set("Authorization", bearerPrefix+token)
+		}
+		next(w, req)
+	}
+}
+
+//socketToken lets browsers, which cannot set headers on WebSocket requests, offer the session token as a subprotocol
+//starting with socketTokenPrefix. Unlike query parameters, subprotocols do not end up in logs or browser history
+func socketToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "" {
+			for _, protocol := range websocket.Subprotocols(req) {
+				if strings.HasPrefix(protocol, socketTokenPrefix) {
+					req.Header.Set("Authorization", bearerPrefix+strings.TrimPrefix(protocol, socketTokenPrefix))
+					break
+				}
+			}
+		}
+		next(w, req)
+	}
+}
+
+func bearerToken(req *http.Request) string {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix))
+}
+
+func (server *server) logout(w http.ResponseWriter, req *http.Request) {
+	err := server.db(req.Context()).DeleteSession(auth.HashToken(bearerToken(req)))
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to delete session", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
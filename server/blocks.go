@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+type userRelationshipFunc func(client *neo4j.Client, from, to *types.User) error
+
+type listUsersFunc func(client *neo4j.Client, user *types.User, after string, limit int) ([]*types.User, error)
+
+//listRelationships serves the list of the users the authenticated user blocked or muted
+func (server *server) listRelationships(list listUsersFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user := userFromContext(req.Context())
+
+		after, limit, err := readPage(req)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+
+		users, err := list(server.db(req.Context()), user, after, limit+1)
+		if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to list users", "username", user.Username, "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		response := UsersResponse{Users: users}
+		if len(users) > limit {
+			response.Users = users[:limit]
+			response.NextCursor = encodeCursor(users[limit-1].Username)
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+//createRelationship serves blocking or muting the user named in the request
+func (server *server) createRelationship(create userRelationshipFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user := userFromContext(req.Context())
+		request := &followRequest{}
+
+		err := readJSON(req, request)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+
+		err = create(server.db(req.Context()), user, &types.User{Username: request.Username})
+		if errors.Is(err, neo4j.ErrForbidden) {
+			errs.Write(w, errs.New(errs.CodeSelfAction, "Unable to do this to yourself"))
+			return
+		} else if errors.Is(err, neo4j.ErrNotFound) {
+			errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+			return
+		} else if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to update relationship", "username", user.Username,
+				"target", request.Username, "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+//removeRelationship serves unblocking or unmuting the user named in the path
+func (server *server) removeRelationship(remove userRelationshipFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user := userFromContext(req.Context())
+		username := pathParam(req, "username")
+
+		err := remove(server.db(req.Context()), user, &types.User{Username: username})
+		if errors.Is(err, neo4j.ErrNotFound) {
+			errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+			return
+		} else if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to update relationship", "username", user.Username,
+				"target", username, "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/discount"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/tax"
+	"github.com/danny-m08/music-match/types"
+)
+
+var errInvalidDiscountCode = errors.New("Invalid discount code")
+
+func (server *server) createDiscount(w http.ResponseWriter, req *http.Request) {
+	seller := userFromContext(req.Context())
+	request := &CreateDiscountRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	d := &types.Discount{
+		ID:            types.GenerateID(),
+		Code:          request.Code,
+		ListingID:     request.ListingID,
+		Type:          request.Type,
+		Value:         request.Value,
+		MaxUses:       request.MaxUses,
+		PerBuyerLimit: request.PerBuyerLimit,
+		Starts:        request.Starts,
+		Expires:       request.Expires,
+	}
+
+	err = discount.Validate(d)
+	if err != nil {
+		errs.Write(w, errs.WithCode(errs.CodeBadRequest, err))
+		return
+	}
+
+	err = server.db(req.Context()).CreateDiscount(seller, d)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	} else if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeAlreadyExists, "Discount code already exists"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create discount", "seller", seller.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, d)
+}
+
+func (server *server) getDiscounts(w http.ResponseWriter, req *http.Request) {
+	seller := userFromContext(req.Context())
+
+	discounts, err := server.db(req.Context()).GetDiscounts(seller)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve discounts", "seller", seller.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, discounts)
+}
+
+func (server *server) deleteDiscount(w http.ResponseWriter, req *http.Request) {
+	seller := userFromContext(req.Context())
+
+	err := server.db(req.Context()).DeleteDiscount(seller, pathParam(req, "id"))
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Discount not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to delete discount", "seller", seller.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//checkout buys a listing for the authenticated user. A discount code given in the request is applied if it is valid,
+//otherwise the best scheduled sale running on the listing is applied. Tax is then charged on the discounted price
+//according to the rule for the buyer's country and region
+func (server *server) checkout(w http.ResponseWriter, req *http.Request) {
+	buyer := userFromContext(req.Context())
+	request := &CheckoutRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	listing, err := server.db(req.Context()).GetListing(request.ListingID, buyer)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve listing", "listing_id", request.ListingID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if listing == nil {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	} else if listing.Sold {
+		errs.Write(w, errs.New(errs.CodeAlreadySold, "Listing has already been sold"))
+		return
+	} else if listing.Seller != nil && listing.Seller.Username == buyer.Username {
+		errs.Write(w, errs.New(errs.CodeSelfAction, "Unable to buy your own listing"))
+		return
+	}
+
+	now := time.Now()
+	d, err := server.findDiscount(req.Context(), listing, buyer, request.DiscountCode, now)
+	if errors.Is(err, errInvalidDiscountCode) || errors.Is(err, discount.ErrNotStarted) || errors.Is(err, discount.ErrExpired) ||
+		errors.Is(err, discount.ErrUsedUp) || errors.Is(err, discount.ErrBuyerLimit) || errors.Is(err, discount.ErrWrongListing) {
+		errs.Write(w, errs.WithCode(errs.CodeBadRequest, err))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve discounts for listing", "listing_id", listing.ID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	txID, err := auth.NewToken()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate transaction ID", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	tx := &types.Transaction{
+		ID:            txID,
+		Buyer:         &types.User{Username: buyer.Username},
+		Date:          now,
+		OriginalPrice: listing.Price,
+		FinalPrice:    listing.Price,
+	}
+
+	tx.Discount, err = currency.NewAmount("0", listing.Price.CurrencyCode())
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Invalid price on listing", "listing_id", listing.ID, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if d != nil {
+		tx.Discount, tx.FinalPrice, err = discount.Apply(d, listing.Price)
+		if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to apply discount", "discount_id", d.ID, "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+		tx.DiscountID = d.ID
+	}
+
+	if !server.taxTable.Empty() {
+		if request.Country == "" {
+			errs.Write(w, errs.Invalid("country", "Buyer country is required"))
+			return
+		}
+
+		if rule := server.taxTable.Lookup(request.Country, request.Region); rule != nil {
+			tx.Tax, err = tax.Calculate(rule, request.Country, request.Region, tx.FinalPrice)
+			if err != nil {
+				logging.FromContext(req.Context()).Error("Unable to calculate tax on listing", "listing_id", listing.ID,
+					"error", err)
+				errs.Write(w, errs.ErrInternal)
+				return
+			}
+			tx.FinalPrice = tx.Tax.Gross
+		}
+	}
+
+	sales, err := server.db(req.Context()).Purchase(listing, tx, d, saleEmails(listing, tx)...)
+	if errors.Is(err, neo4j.ErrAlreadySold) {
+		errs.Write(w, err)
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	} else if errors.Is(err, neo4j.ErrBlocked) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Unable to buy from this seller"))
+		return
+	} else if errors.Is(err, discount.ErrUsedUp) || errors.Is(err, discount.ErrBuyerLimit) {
+		errs.Write(w, errs.WithCode(errs.CodeBadRequest, err))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to complete purchase", "listing_id", listing.ID,
+			"buyer", buyer.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Listing bought", "buyer", buyer.Username, "listing_id", listing.ID,
+		"price", tx.FinalPrice)
+	if tx.Tax != nil {
+		metrics.Purchase(tx.Tax.Net)
+	} else {
+		metrics.Purchase(tx.FinalPrice)
+	}
+	if listing.Seller != nil {
+		server.publishMilestone(req.Context(), listing.Seller, sales, now)
+		server.notify(req.Context(), listing.Seller, &types.Notification{
+			Type:          types.NotifySale,
+			Actor:         &types.User{Username: buyer.Username},
+			ListingID:     listing.ID,
+			TransactionID: tx.ID,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, tx)
+}
+
+//findDiscount returns the discount to apply at checkout, or nil if there is none
+func (server *server) findDiscount(ctx context.Context, listing *types.Listing, buyer *types.User, code string, now time.Time) (*types.Discount, error) {
+	if code == "" {
+		sales, err := server.db(ctx).GetSales(listing)
+		if err != nil {
+			return nil, err
+		}
+
+		return discount.Best(sales, listing.ID, listing.Price, now), nil
+	}
+
+	d, err := server.db(ctx).GetDiscountByCode(listing, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if d == nil {
+		return nil, errInvalidDiscountCode
+	}
+
+	uses, err := server.db(ctx).CountDiscountUses(buyer, d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = discount.Active(d, listing.ID, uses, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/download"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/storage"
+)
+
+//downloadLink issues a signed, expiring link the authenticated buyer can download a purchased track with. Every link
+//is a new grant, counting once towards the transaction's download limit when it is first used
+func (server *server) downloadLink(w http.ResponseWriter, req *http.Request) {
+	buyer := userFromContext(req.Context())
+	txID := req.URL.Query().Get("tx")
+
+	listing, err := server.db(req.Context()).GetPurchase(txID)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve transaction", "transaction_id", txID, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if listing == nil || listing.Tx.Buyer.Username != buyer.Username {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Transaction not found"))
+		return
+	}
+
+	if listing.Tx.Downloads >= server.downloads.MaxDownloads {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Download limit reached"))
+		return
+	}
+
+	expires := time.Now().Add(server.downloads.LinkTTL).Truncate(time.Second)
+	grant, err := auth.NewToken()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate download grant", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("tx", txID)
+	params.Set("buyer", buyer.Username)
+	params.Set("grant", grant)
+	params.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	params.Set("signature", server.signer.Sign(buyer.Username, txID, grant, expires))
+
+	writeJSON(w, http.StatusOK, DownloadLinkResponse{
+		URL:     "/download?" + params.Encode(),
+		Expires: expires,
+	})
+}
+
+//download serves the track bought in a transaction to anyone holding a valid link for it. The first request made with
+//a link counts towards the transaction's download limit, further ones such as resumed or ranged downloads do not
+func (server *server) download(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	txID, buyer, grant := query.Get("tx"), query.Get("buyer"), query.Get("grant")
+
+	unix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		errs.Write(w, errs.New(errs.CodeForbidden, download.ErrInvalidSignature.Error()))
+		return
+	}
+
+	err = server.signer.Verify(buyer, txID, grant, time.Unix(unix, 0), query.Get("signature"), time.Now())
+	if err != nil {
+		errs.Write(w, errs.WithCode(errs.CodeForbidden, err))
+		return
+	}
+
+	listing, err := server.db(req.Context()).GetPurchase(txID)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve transaction", "transaction_id", txID, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if listing == nil || listing.Tx.Buyer.Username != buyer {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Transaction not found"))
+		return
+	}
+
+	if listing.Track == nil || listing.Track.Path == "" {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Track not found"))
+		return
+	}
+
+	blob, err := server.store.Open(listing.Track.Path)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to open track for listing", "listing_id", listing.ID,
+			"error", err)
+		if errors.Is(err, storage.ErrNotFound) {
+			errs.Write(w, errs.New(errs.CodeNotFound, "Track not found"))
+			return
+		}
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+	defer blob.Close()
+
+	err = server.db(req.Context()).RecordDownload(txID, grant, server.downloads.MaxDownloads)
+	if errors.Is(err, neo4j.ErrLimitReached) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Download limit reached"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to record download for transaction", "transaction_id", txID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", listing.Track.Name))
+	http.ServeContent(w, req, listing.Track.Name, blob.ModTime(), blob)
+}
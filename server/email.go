@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+//saleEmails renders the receipt for the buyer and the sale notice for the seller of a purchase. Emails that fail to
+//render are logged and left out rather than failing the purchase
+func saleEmails(listing *types.Listing, tx *types.Transaction) []*types.Email {
+	data := email.SaleData{
+		Buyer:         tx.Buyer.Username,
+		TransactionID: tx.ID,
+		Date:          tx.Date,
+		Total:         tx.FinalPrice.String(),
+	}
+	if listing.Track != nil {
+		data.Track = listing.Track.Name
+	}
+	if listing.Seller != nil {
+		data.Seller = listing.Seller.Username
+	}
+	if !tx.Discount.IsZero() {
+		data.Discount = tx.Discount.String()
+	}
+	if tx.Tax != nil {
+		data.TaxName = tx.Tax.Name
+		data.Tax = tx.Tax.Amount.String()
+	}
+
+	var emails []*types.Email
+	receipt := data
+	receipt.Username = data.Buyer
+	if e, err := email.New(email.Receipt, data.Buyer, &receipt); err != nil {
+		logging.Error("Unable to render receipt for transaction", "transaction_id", tx.ID, "error", err)
+	} else {
+		emails = append(emails, e)
+	}
+
+	if data.Seller != "" {
+		sale := data
+		sale.Username = data.Seller
+		if e, err := email.New(email.Sale, data.Seller, &sale); err != nil {
+			logging.Error("Unable to render sale notice for transaction", "transaction_id", tx.ID, "error", err)
+		} else {
+			emails = append(emails, e)
+		}
+	}
+
+	return emails
+}
+
+//getEmailPreferences writes the authenticated user's email preferences
+func (server *server) getEmailPreferences(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	prefs, err := server.db(req.Context()).GetEmailPreferences(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve email preferences", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+//setEmailPreferences replaces the authenticated user's email preferences
+func (server *server) setEmailPreferences(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	prefs := &types.EmailPreferences{}
+
+	err := readJSON(req, prefs)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	err = server.db(req.Context()).SetEmailPreferences(user, prefs)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to update email preferences", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prefs)
+}
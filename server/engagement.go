@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const maxCommentLength = 2000
+
+type reactFunc func(client *neo4j.Client, user *types.User, listingID string) error
+
+type listReactionsFunc func(client *neo4j.Client, listingID string, viewer *types.User, after string,
+	limit int) ([]*types.User, error)
+
+type reactedFunc func(ctx context.Context, user *types.User, listingID string)
+
+//react serves creating a kind of reaction to a listing. onCreate, if set, is called after a reaction was created
+func (server *server) react(create reactFunc, onCreate reactedFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user := userFromContext(req.Context())
+		request := &listingRequest{}
+
+		err := readJSON(req, request)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+
+		err = create(server.db(req.Context()), user, request.ListingID)
+		if !server.reactionError(w, err) {
+			return
+		}
+
+		if onCreate != nil {
+			onCreate(req.Context(), user, request.ListingID)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+//unreact serves taking a kind of reaction to the listing named in the path back
+func (server *server) unreact(remove reactFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		err := remove(server.db(req.Context()), userFromContext(req.Context()), pathParam(req, "listing_id"))
+		if server.reactionError(w, err) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+//reactionError writes the response for a failed reaction and reports whether err was nil
+func (server *server) reactionError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return false
+	} else if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeAlreadyExists, "Already done"))
+		return false
+	} else if err != nil {
+		logging.Error("Unable to update reaction", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return false
+	}
+
+	return true
+}
+
+//listReactions serves the users who reacted to a listing in a kind of way
+func (server *server) listReactions(list listReactionsFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		listingID := req.URL.Query().Get("listing_id")
+
+		after, limit, err := readPage(req)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+
+		users, err := list(server.db(req.Context()), listingID, userFromContext(req.Context()), after, limit+1)
+		if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to retrieve reactions to listing", "listing_id", listingID,
+				"error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+
+		response := UsersResponse{Users: users}
+		if len(users) > limit {
+			response.Users = users[:limit]
+			response.NextCursor = encodeCursor(users[limit-1].Username)
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func (server *server) publishRepost(ctx context.Context, user *types.User, listingID string) {
+	listing, err := server.db(ctx).GetListing(listingID, user)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to retrieve reposted listing", "listing_id", listingID, "error", err)
+		return
+	} else if listing == nil {
+		return
+	}
+
+	server.publish(ctx, &types.FeedItem{
+		ID:      "repost:" + user.Username + ":" + listingID,
+		Type:    types.FeedRepost,
+		Actor:   &types.User{Username: user.Username},
+		Listing: listing,
+		Time:    time.Now(),
+	})
+}
+
+func (server *server) createComment(w http.ResponseWriter, req *http.Request) {
+	author := userFromContext(req.Context())
+	request := &CreateCommentRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	body := strings.TrimSpace(request.Body)
+
+	comment := &types.Comment{
+		ID:        types.GenerateID(),
+		ListingID: request.ListingID,
+		ParentID:  request.ParentID,
+		Author:    &types.User{Username: author.Username},
+		Body:      body,
+		Created:   time.Now(),
+	}
+
+	err = server.db(req.Context()).CreateComment(author, comment)
+	if errors.Is(err, neo4j.ErrBlocked) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Unable to comment on this listing"))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing or parent comment not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create comment on listing", "listing_id", request.ListingID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.notifyComment(req.Context(), comment)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+//deleteComment lets authors delete their comments and sellers and moderators moderate the comments on listings
+func (server *server) deleteComment(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	id := pathParam(req, "id")
+
+	var moderation *types.AuditEntry
+	if auth.Can(user, auth.PermModerateComments) {
+		moderation = newAuditEntry(user, types.AuditDeleteComment, "comment:"+id, req.URL.Query().Get("reason"))
+	}
+
+	err := server.db(req.Context()).DeleteComment(user, id, moderation)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Comment not found"))
+		return
+	} else if errors.Is(err, neo4j.ErrForbidden) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Only the author or the seller can delete this comment"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to delete comment", "comment_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *server) getComments(w http.ResponseWriter, req *http.Request) {
+	listingID := req.URL.Query().Get("listing_id")
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	after, afterID := time.Time{}, ""
+	if position != "" {
+		after, afterID, err = parseTimeCursor(position)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+	}
+
+	db := server.db(req.Context())
+	comments, err := db.GetComments(listingID, userFromContext(req.Context()), after, afterID, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve comments on listing", "listing_id", listingID,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := CommentsResponse{Comments: comments}
+	if len(comments) > limit {
+		last := comments[limit-1]
+		response.Comments = comments[:limit]
+		response.NextCursor = timeCursor(last.Created, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/feed"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/types"
+)
+
+//getFeed writes a page of the authenticated user's activity feed
+func (server *server) getFeed(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	var after *feed.Cursor
+	if position != "" {
+		t, id, err := parseTimeCursor(position)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+		after = &feed.Cursor{Time: t, ID: id}
+	}
+
+	items, err := server.feed.Read(req.Context(), user, after, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve feed", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := FeedResponse{Items: items}
+	if len(items) > limit {
+		last := items[limit-1]
+		response.Items = items[:limit]
+		response.NextCursor = timeCursor(last.Time, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//publish adds an item to the actor's followers' feeds. Failing to do so is logged but does not fail the request that
+//caused the event
+func (server *server) publish(ctx context.Context, item *types.FeedItem) {
+	err := server.feed.Publish(ctx, item)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to publish feed item", "item_id", item.ID, "error", err)
+	}
+}
+
+//publishMilestone announces the seller's number of sales, as counted by the purchase that made the last one, if it is a
+//milestone
+func (server *server) publishMilestone(ctx context.Context, seller *types.User, sales int64, now time.Time) {
+	if !feed.IsMilestone(server.feed, sales) {
+		return
+	}
+
+	server.publish(ctx, &types.FeedItem{
+		ID:        "milestone:" + seller.Username + ":" + strconv.FormatInt(sales, 10),
+		Type:      types.FeedMilestone,
+		Actor:     &types.User{Username: seller.Username},
+		Milestone: sales,
+		Time:      now,
+	})
+}
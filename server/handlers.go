@@ -1,154 +1,332 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"time"
 
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
 	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/router"
 	"github.com/danny-m08/music-match/types"
 )
 
-const unableToProcessRequestFormat = "Unable to process request from %s: %s"
+//maxBodySize is the largest request body readJSON accepts, in bytes
+const maxBodySize = 1 << 20
 
 func (server *server) newUser(w http.ResponseWriter, req *http.Request) {
-	logging.Info("New user request from: " + req.RemoteAddr)
+	logging.FromContext(req.Context()).Info("New user request", "remote_addr", req.RemoteAddr)
 
-	body, err := ioutil.ReadAll(req.Body)
-	if err != nil {
-		logging.Error(fmt.Sprintf(unableToProcessRequestFormat, req.RemoteAddr, err.Error()))
-		http.Error(w, "Unable to process request", http.StatusBadRequest)
-		return
-	}
-
-	logging.Debug(string(body))
 	userReq := CreateUserRequest{}
-
-	err = json.Unmarshal(body, &userReq)
+	err := readJSON(req, &userReq)
 	if err != nil {
-		logging.Error(fmt.Sprintf(unableToProcessRequestFormat, req.RemoteAddr, err.Error()))
-		http.Error(w, "Unable to process request", http.StatusBadRequest)
+		logging.FromContext(req.Context()).Warn("Unable to process request", "error", err)
+		errs.Write(w, err)
 		return
 	}
 
-	valid, err := verifyEmail(userReq.Email)
+	hash, err := auth.HashPassword(userReq.Password)
 	if err != nil {
-		logging.Error(fmt.Sprintf(unableToProcessRequestFormat, req.RemoteAddr, err.Error()))
-		http.Error(w, "Unable to process request", http.StatusBadRequest)
-		return
-	} else if !valid {
-		logging.Error(fmt.Sprintf(unableToProcessRequestFormat, req.RemoteAddr, "Invalid email address "+userReq.Email))
-		http.Error(w, "Unable to process request", http.StatusBadRequest)
+		logging.FromContext(req.Context()).Error("Unable to hash password", "error", err)
+		errs.Write(w, errs.ErrInternal)
 		return
 	}
 
 	user := &types.User{
 		Username: userReq.Username,
-		Password: userReq.Password,
+		Password: hash,
 		Email:    userReq.Email,
 	}
 
-	logging.Info("Creating new user " + user.String())
-	err = server.neo4jClient.InsertUser(user)
-	if err != nil {
-		logging.Error(fmt.Sprintf(unableToProcessRequestFormat, req.RemoteAddr, err.Error()))
-		http.Error(w, "Unable to create new user", http.StatusInternalServerError)
+	logging.FromContext(req.Context()).Info("Creating new user", "username", user.Username)
+	err = server.db(req.Context()).InsertUser(user)
+	if errors.Is(err, neo4j.ErrUsernameTaken) {
+		errs.Write(w, errs.New(errs.CodeUsernameTaken, "Username already taken"))
 		return
+	} else if errors.Is(err, neo4j.ErrEmailTaken) {
+		errs.Write(w, errs.New(errs.CodeEmailTaken, "Email address already in use"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create user", "username", user.Username, "error", err)
+		errs.Write(w, errs.New(errs.CodeInternal, "Unable to create new user"))
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("User created", "username", user.Username)
+	metrics.Signup()
+
+	err = server.sendVerification(req.Context(), user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to send verification email", "username", user.Username,
+			"error", err)
 	}
 
-	logging.Info(fmt.Sprintf("%s created successfully", user.String()))
 	w.WriteHeader(http.StatusOK)
 }
 
 func (server *server) login(w http.ResponseWriter, req *http.Request) {
 	loginReq := LoginRequest{}
 
-	body, err := ioutil.ReadAll(req.Body)
+	err := readJSON(req, &loginReq)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		errs.Write(w, err)
 		return
 	}
 
-	err = json.Unmarshal(body, &loginReq)
+	usr := types.User{Email: loginReq.Email}
+
+	attempt, ok := server.allowLogin(w, req, loginReq.Email)
+	if !ok {
+		return
+	}
+
+	userInfo, err := server.db(req.Context()).GetUser(&usr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		logging.FromContext(req.Context()).Error("Unable to retrieve user", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if userInfo == nil {
+		auth.CheckMissingPassword(loginReq.Password)
+	}
+
+	if userInfo == nil || !auth.CheckPassword(userInfo.Password, loginReq.Password) {
+		server.loginFailed(req, attempt, userInfo)
+		errs.Write(w, errs.New(errs.CodeInvalidCredentials, "Username or password incorrect"))
+		return
+	}
+
+	server.rehashPassword(req.Context(), userInfo, loginReq.Password)
+
+	if userInfo.TwoFactor {
+		server.loginContinued(req.Context(), attempt)
+		server.challenge(w, req, userInfo)
 		return
 	}
 
-	usr := types.User{
-		Email:    loginReq.Email,
-		Password: loginReq.Password,
+	server.loginSucceeded(req.Context(), attempt)
+	server.startSession(w, req, userInfo)
+}
+
+//rehashPassword replaces the stored password of a user who just logged in by its hash if it was stored in plain text
+//or hashed with older parameters. Failing to do so does not fail the login, it is tried again on the next one
+func (server *server) rehashPassword(ctx context.Context, user *types.User, password string) {
+	if !auth.NeedsRehash(user.Password) {
+		return
 	}
 
-	userInfo, err := server.neo4jClient.GetUser(&usr)
+	hash, err := auth.HashPassword(password)
+	if err == nil {
+		err = server.db(ctx).UpgradePassword(user, user.Password, hash)
+	}
 	if err != nil {
-		http.Error(w, "Username or password incorrect", http.StatusUnauthorized)
+		logging.FromContext(ctx).Error("Unable to rehash password", "username", user.Username, "error", err)
 	}
+}
 
-	if userInfo.Password == loginReq.Password {
-		http.Error(w, "Username or password incorrect", http.StatusUnauthorized)
+//startSession logs the user in and writes the new session token. Suspended users are turned away
+func (server *server) startSession(w http.ResponseWriter, req *http.Request, user *types.User) {
+	if user.Suspended {
+		errs.Write(w, errs.New(errs.CodeAccountSuspended, "Account suspended"))
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	token, err := auth.NewToken()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate session token", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	expires := time.Now().Add(server.authConfig.SessionTTL)
+	err = server.db(req.Context()).CreateSession(user, auth.HashToken(token), expires)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create session", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LoginResponse{
+		Token:    token,
+		Expires:  expires,
+		Verified: user.Verified,
+	})
 }
 
 func (server *server) follow(w http.ResponseWriter, req *http.Request) {
+	follower := userFromContext(req.Context())
 	request := &followRequest{}
 
-	body, err := ioutil.ReadAll(req.Body)
+	err := readJSON(req, request)
 	if err != nil {
-		http.Error(w, "Unable to process request: "+err.Error(), http.StatusBadRequest)
+		errs.Write(w, err)
 		return
 	}
 
-	err = json.Unmarshal(body, request)
-	if err != nil {
-		http.Error(w, "Unable to process request: "+err.Error(), http.StatusBadRequest)
+	user := &types.User{Username: request.Username}
+	err = server.db(req.Context()).CreateFollowing(user, follower)
+	if errors.Is(err, neo4j.ErrBlocked) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Unable to follow "+user.Username))
+		return
+	} else if errors.Is(err, neo4j.ErrSelfFollow) {
+		errs.Write(w, err)
+		return
+	} else if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeAlreadyExists, "Already following "+user.Username))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create following request", "error", err)
+		errs.Write(w, errs.ErrInternal)
 		return
 	}
 
-	err = server.neo4jClient.CreateFollowing(request.User, request.Follower)
+	logging.FromContext(req.Context()).Info("Following created", "follower", follower.Username,
+		"username", user.Username)
+	metrics.Follow()
+	server.notify(req.Context(), user,
+		&types.Notification{Type: types.NotifyFollow, Actor: &types.User{Username: follower.Username}})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (server *server) unfollow(w http.ResponseWriter, req *http.Request) {
+	follower := userFromContext(req.Context())
+	request := &followRequest{}
+
+	err := readJSON(req, request)
 	if err != nil {
-		logging.Error("Unable to create following request: " + err.Error())
-		http.Error(w, "Unable to process request", http.StatusInternalServerError)
+		errs.Write(w, err)
 		return
 	}
 
-	logging.Info(fmt.Sprintf("%s -> %s following created successfully", request.Follower.String(), request.User.String()))
+	user := &types.User{Username: request.Username}
+	err = server.db(req.Context()).Unfollow(user, follower)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Not following "+user.Username))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to remove following", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Following removed", "follower", follower.Username,
+		"username", user.Username)
+	w.WriteHeader(http.StatusOK)
 }
 
 func (server *server) getFollowers(w http.ResponseWriter, req *http.Request) {
-	//user, err := readUser(req)
-	//if err != nil {
-	//	logging.Error("Unable to read user from request: " + err.Error())
-	//	http.Error(w, "Unable to proccess request: "+err.Error(), http.StatusBadRequest)
-	//	return
-	//}
-	//
-	//	client, err := neo4j.NewClient(config.GetGlobalConfig().GetDBConfig())
-	//	if err != nil {
-	//		logging.Error("Unable to create DB connection: " + err.Error())
-	//		http.Error(w, "Unable to process request", http.StatusInternalServerError)
-	//		return
-	//	}
-	//	defer client.Close()
-	//
-	//	f, err := client.GetFollowers(user)
-	//	if err != nil {
-	//		logging.Error(fmt.Sprintf("Unable to retrieve followers for user %s: %s", user.String(), err.Error()))
-	//		http.Error(w, "Unable to process request", http.StatusInternalServerError)
-	//	}
-	//
-	//	fReq := followers{
-	//		followers: f,
-	//	}
-	//
-	//	data, err := json.Marshal(fReq)
-	//	if err != nil {
-	//		logging.Error("Unable to marshal followers: " + err.Error())
-	//		http.Error(w, "Unable to process request", http.StatusInternalServerError)
-	//	}
-	//
-	//	w.Write(data)
+	server.listFollows(w, req, server.db(req.Context()).ListFollowers, true)
+}
+
+func (server *server) getFollowing(w http.ResponseWriter, req *http.Request) {
+	server.listFollows(w, req, server.db(req.Context()).ListFollowing, false)
+}
+
+type listFollowsFunc func(user, viewer *types.User, after string, limit int) ([]*types.Follow, error)
+
+//listFollows writes a page of the followers or followings of the user named in the request along with their total
+func (server *server) listFollows(w http.ResponseWriter, req *http.Request, list listFollowsFunc, followers bool) {
+	user := &types.User{Username: pathParam(req, "username")}
+
+	after, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	followerCount, followingCount, err := server.db(req.Context()).GetFollowCounts(user, userFromContext(req.Context()))
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve follow counts", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	follows, err := list(user, userFromContext(req.Context()), after, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve follows", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := FollowsResponse{Users: follows, Count: followingCount}
+	if followers {
+		response.Count = followerCount
+	}
+
+	if len(follows) > limit {
+		response.Users = follows[:limit]
+		response.NextCursor = encodeCursor(follows[limit-1].User.Username)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//pathParam returns the named path parameter, falling back to the query parameter of the same name that the routes
+//predating path parameters take it from
+func pathParam(req *http.Request, name string) string {
+	if value := router.Param(req, name); value != "" {
+		return value
+	}
+
+	return req.URL.Query().Get(name)
+}
+
+//readJSON unmarshals the request body into v and validates it if it is a validator. Bodies larger than maxBodySize,
+//with fields v does not have or with anything after the JSON value are rejected
+func readJSON(req *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+	if err != nil {
+		return errs.Wrap(errs.CodeMalformedBody, "Unable to read request body", err)
+	} else if len(body) > maxBodySize {
+		return errs.New(errs.CodeBodyTooLarge, fmt.Sprintf("Request body must be at most %d bytes", maxBodySize))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		return errs.Wrap(errs.CodeMalformedBody, "Malformed request body: "+err.Error(), err)
+	} else if decoder.Decode(&json.RawMessage{}) != io.EOF {
+		return errs.New(errs.CodeMalformedBody, "Malformed request body: unexpected data after JSON value")
+	}
+
+	if val, ok := v.(validator); ok {
+		return val.Validate()
+	}
+
+	return nil
+}
+
+//writeJSON marshals v as the response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logging.Error("Unable to marshal response", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	if err != nil {
+		logging.Error("Unable to write response", "error", err)
+	}
 }
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/danny-m08/music-match/health"
+	"github.com/danny-m08/music-match/logging"
+)
+
+const defaultOutboxLag = 15 * time.Minute
+
+//registerChecks sets up the readiness checks. The database and the blob store are critical, a backed up outbox or a
+//stuck background job only degrades the server as taking it out of rotation would not help. Errors of the
+//dependencies are logged rather than reported as they can carry addresses
+func (s *server) registerChecks(outboxLag time.Duration) {
+	if outboxLag == 0 {
+		outboxLag = defaultOutboxLag
+	}
+
+	s.health.Register("neo4j", func() error {
+		err := s.neo4jClient.Ping()
+		if err != nil {
+			logging.Error("Readiness check of Neo4j failed", "error", err)
+			return errors.New("unreachable")
+		}
+		return nil
+	}, true)
+
+	s.health.Register("storage", func() error {
+		err := s.store.Ping()
+		if err != nil {
+			logging.Error("Readiness check of the blob store failed", "error", err)
+			return errors.New("unavailable")
+		}
+		return nil
+	}, true)
+
+	s.health.Register("outbox", func() error {
+		overdue, err := s.neo4jClient.CountOverdueEmails(time.Now().Add(-outboxLag))
+		if err != nil {
+			logging.Error("Readiness check of the outbox failed", "error", err)
+			return errors.New("unable to count overdue emails")
+		} else if overdue > 0 {
+			return fmt.Errorf("%d emails overdue by more than %s", overdue, outboxLag)
+		}
+		return nil
+	}, false)
+
+	if s.mailer != nil {
+		s.health.Register("email-worker", s.mailer.Check, false)
+	}
+
+	s.health.Register("housekeeping", func() error {
+		last := atomic.LoadInt64(&s.lastHousekeeping)
+		if last == 0 {
+			return errors.New("not running")
+		} else if since := time.Since(time.Unix(0, last)); since > 2*housekeepingInterval {
+			return fmt.Errorf("last ran %s ago", since.Round(time.Second))
+		}
+		return nil
+	}, false)
+}
+
+//healthz answers liveness probes. It only shows the process is serving requests, dependencies are left to readyz so a
+//database outage does not get every instance restarted
+func (s *server) healthz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+//readyz answers readiness probes with the report of every check, with 503 while a critical check fails or the server
+//is draining
+func (s *server) readyz(w http.ResponseWriter, req *http.Request) {
+	report := s.health.Check()
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/metrics"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+func (server *server) createListing(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &CreateListingRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	now := time.Now()
+	listing := &types.Listing{
+		ID:      types.GenerateID(),
+		Price:   request.Price,
+		Track:   &types.Track{Name: request.Track.Name},
+		Created: &now,
+	}
+
+	err = server.db(req.Context()).CreateUserListing(user, listing)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create listing", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	metrics.ListingCreated()
+	listing.Seller = &types.User{Username: user.Username}
+	server.publish(req.Context(), &types.FeedItem{
+		ID:      "listing:" + listing.ID,
+		Type:    types.FeedListing,
+		Actor:   listing.Seller,
+		Listing: listing,
+		Time:    now,
+	})
+
+	writeJSON(w, http.StatusCreated, listing)
+}
+
+func (server *server) getListing(w http.ResponseWriter, req *http.Request) {
+	id := pathParam(req, "id")
+	if id == "" {
+		errs.Write(w, errs.Invalid("id", "Listing id is required"))
+		return
+	}
+
+	listing, err := server.db(req.Context()).GetListing(id, userFromContext(req.Context()))
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve listing", "listing_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if listing == nil {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listing)
+}
+
+//errUploadTooLarge is returned while reading uploads past the configured maximum size
+var errUploadTooLarge = errors.New("upload too large")
+
+//uploadLimit reads from r until more than n bytes were read, when it fails with errUploadTooLarge
+type uploadLimit struct {
+	r io.Reader
+	n int64
+}
+
+func (l *uploadLimit) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errUploadTooLarge
+	}
+
+	return n, err
+}
+
+//uploadTrack stores the request body as the track file of one of the seller's listings. The file is stored under a
+//key the server generates, so sellers cannot point listings at files they did not upload. Uploading again replaces
+//the track until the listing is sold
+func (server *server) uploadTrack(w http.ResponseWriter, req *http.Request) {
+	seller := userFromContext(req.Context())
+	id := pathParam(req, "id")
+
+	if req.ContentLength > server.maxUploadSize {
+		errs.Write(w, errs.New(errs.CodeBodyTooLarge,
+			fmt.Sprintf("Track files must be at most %d bytes", server.maxUploadSize)))
+		return
+	}
+
+	listing, err := server.db(req.Context()).GetListing(id, seller)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve listing", "listing_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if listing == nil || listing.Seller == nil || listing.Seller.Username != seller.Username {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	}
+
+	if listing.Sold {
+		errs.Write(w, errs.New(errs.CodeAlreadySold, "Listing has already been sold"))
+		return
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate storage key", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	key := "tracks/" + token
+	err = server.store.Create(key, &uploadLimit{r: req.Body, n: server.maxUploadSize})
+	if errors.Is(err, errUploadTooLarge) {
+		errs.Write(w, errs.New(errs.CodeBodyTooLarge,
+			fmt.Sprintf("Track files must be at most %d bytes", server.maxUploadSize)))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to store track", "listing_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	err = server.db(req.Context()).SetTrackPath(seller, id, key)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Listing not found"))
+		return
+	} else if errors.Is(err, neo4j.ErrAlreadySold) {
+		errs.Write(w, errs.New(errs.CodeAlreadySold, "Listing has already been sold"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to set track of listing", "listing_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,354 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/realtime"
+	"github.com/danny-m08/music-match/types"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	maxParticipants    = 10
+	maxMessageLength   = 5000
+	maxAttachments     = 5
+	socketWriteTimeout = 10 * time.Second
+	socketPongTimeout  = 60 * time.Second
+	socketPingInterval = socketPongTimeout * 9 / 10
+)
+
+const (
+	eventMessage = "message"
+	eventRead    = "read"
+)
+
+//socketProtocol is the subprotocol of the messaging socket. Browsers, which cannot set headers on WebSocket requests,
+//offer the session token as a second subprotocol starting with socketTokenPrefix. Only socketProtocol is ever
+//selected, so the token is not sent back
+const (
+	socketProtocol    = "music-match"
+	socketTokenPrefix = "bearer."
+)
+
+//newUpgrader returns an upgrader accepting connections from the origins browsers may call the API from, from the
+//API's own origin and from clients sending no origin, which are not browsers
+func newUpgrader(conf *config.CORSConfig) *websocket.Upgrader {
+	origins := make(map[string]bool)
+	if conf != nil {
+		for _, origin := range conf.AllowedOrigins {
+			origins[strings.TrimSuffix(origin, "/")] = true
+		}
+	}
+
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    []string{socketProtocol},
+		CheckOrigin: func(req *http.Request) bool {
+			origin := req.Header.Get("Origin")
+			if origin == "" || origins["*"] || origins[origin] {
+				return true
+			}
+
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, req.Host)
+		},
+	}
+}
+
+func (server *server) createConversation(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &CreateConversationRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	seen := map[string]bool{user.Username: true}
+	var others []*types.User
+	for _, username := range request.Participants {
+		if !seen[username] {
+			seen[username] = true
+			others = append(others, &types.User{Username: username})
+		}
+	}
+
+	if len(others) == 0 || len(others)+1 > maxParticipants {
+		errs.Write(w, errs.Invalid("participants",
+			fmt.Sprintf("Conversations must have between 2 and %d participants", maxParticipants)))
+		return
+	}
+
+	conversation, err := server.db(req.Context()).CreateConversation(user, others, request.Group || len(others) > 1)
+	if errors.Is(err, neo4j.ErrBlocked) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Unable to start a conversation with these users"))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "User not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create conversation", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, conversation)
+}
+
+func (server *server) getConversations(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	before, beforeID, err := newestFirst(position)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	conversations, err := server.db(req.Context()).ListConversations(user, before, beforeID, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list conversations", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := ConversationsResponse{Conversations: conversations}
+	if len(conversations) > limit {
+		last := conversations[limit-1]
+		response.Conversations = conversations[:limit]
+		response.NextCursor = timeCursor(last.Updated, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//sendMessage stores the message and delivers it to the participants connected over a WebSocket. Participants that
+//are not connected pick it up from the message history
+func (server *server) sendMessage(w http.ResponseWriter, req *http.Request) {
+	sender := userFromContext(req.Context())
+	request := &SendMessageRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	body := strings.TrimSpace(request.Body)
+	message := &types.Message{
+		ID:             types.GenerateID(),
+		ConversationID: request.ConversationID,
+		Sender:         &types.User{Username: sender.Username},
+		Body:           body,
+		Created:        time.Now(),
+	}
+
+	for _, attachment := range request.Attachments {
+		message.Attachments = append(message.Attachments, &types.Attachment{Type: attachment.Type, ListingID: attachment.ListingID})
+	}
+
+	conversation, ok := server.findConversation(w, req, sender, request.ConversationID)
+	if !ok {
+		return
+	}
+
+	err = server.db(req.Context()).SendMessage(sender, message)
+	if errors.Is(err, neo4j.ErrBlocked) {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Unable to send messages in this conversation"))
+		return
+	} else if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Conversation or attached listing not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to send message", "sender", sender.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.messageHub.Publish(participants(conversation), &realtime.Event{Type: eventMessage, Data: message})
+	writeJSON(w, http.StatusCreated, message)
+}
+
+//getMessages writes a page of a conversation's history, newest first
+func (server *server) getMessages(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	before, beforeID, err := newestFirst(position)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	conversation, ok := server.findConversation(w, req, user, req.URL.Query().Get("conversation_id"))
+	if !ok {
+		return
+	}
+
+	messages, err := server.db(req.Context()).GetMessages(conversation.ID, before, beforeID, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve messages of conversation",
+			"conversation_id", conversation.ID, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := MessagesResponse{Messages: messages}
+	if len(messages) > limit {
+		last := messages[limit-1]
+		response.Messages = messages[:limit]
+		response.NextCursor = timeCursor(last.Created, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//readMessages marks a conversation read up to now and sends a read receipt to the other connected participants
+func (server *server) readMessages(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &conversationRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	conversation, ok := server.findConversation(w, req, user, request.ConversationID)
+	if !ok {
+		return
+	}
+
+	receipt := &types.ReadReceipt{
+		ConversationID: conversation.ID,
+		User:           &types.User{Username: user.Username},
+		Read:           time.Now(),
+	}
+
+	err = server.db(req.Context()).MarkRead(user, conversation.ID, receipt.Read)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Conversation not found"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to mark conversation read", "conversation_id", conversation.ID,
+			"username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	server.messageHub.Publish(participants(conversation), &realtime.Event{Type: eventRead, Data: receipt})
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+//findConversation retrieves a conversation the user participates in, writing the error response if there is none
+func (server *server) findConversation(w http.ResponseWriter, req *http.Request, user *types.User,
+	id string) (*types.Conversation, bool) {
+	conversation, err := server.db(req.Context()).GetConversation(user, id)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Conversation not found"))
+		return nil, false
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve conversation", "conversation_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return nil, false
+	}
+
+	return conversation, true
+}
+
+func participants(conversation *types.Conversation) []string {
+	usernames := make([]string, 0, len(conversation.Participants))
+	for _, participant := range conversation.Participants {
+		usernames = append(usernames, participant.Username)
+	}
+
+	return usernames
+}
+
+//websocket upgrades the request and pushes the authenticated user's messaging events to it until either side closes
+//the connection. Messages are sent through the REST endpoints, anything the client sends is ignored
+func (server *server) websocket(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	conn, err := server.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		logging.FromContext(req.Context()).Debug("Unable to upgrade connection", "username", user.Username,
+			"error", err)
+		return
+	}
+
+	subscription := server.messageHub.Subscribe(user.Username)
+	go readSocket(conn, subscription)
+	writeSocket(conn, subscription)
+}
+
+//readSocket keeps the connection alive by handling pongs and closes the subscription once the client goes away
+func readSocket(conn *websocket.Conn, subscription *realtime.Subscription) {
+	defer subscription.Close()
+
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(socketPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongTimeout))
+	})
+
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+//writeSocket writes the subscription's events to the connection and pings the client until the subscription is closed
+//or a write fails
+func writeSocket(conn *websocket.Conn, subscription *realtime.Subscription) {
+	ticker := time.NewTicker(socketPingInterval)
+	defer func() {
+		ticker.Stop()
+		subscription.Close()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events:
+			_ = conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/metrics"
+)
+
+var metricsHandler = metrics.Handler()
+
+//metrics serves the Prometheus metrics to scrapers sending the configured token. Without one the endpoint is open,
+//which NewServer warns about
+func (server *server) metrics(w http.ResponseWriter, req *http.Request) {
+	if server.metricsToken != "" &&
+		subtle.ConstantTimeCompare([]byte(bearerToken(req)), []byte(server.metricsToken)) != 1 {
+		errs.Write(w, errs.New(errs.CodeUnauthorized, "Invalid metrics token"))
+		return
+	}
+
+	metricsHandler.ServeHTTP(w, req)
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/realtime"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	eventNotification = "notification"
+	maxReplay         = 100
+	streamHeartbeat   = 30 * time.Second
+	streamRetry       = 5 * time.Second
+)
+
+//notify stores a notification for the recipient and pushes it to their open notification streams. Users are never
+//notified of their own actions. Failing to notify is logged but does not fail the request that caused the event
+func (server *server) notify(ctx context.Context, recipient *types.User, n *types.Notification) {
+	if recipient == nil || recipient.Username == n.Actor.Username {
+		return
+	}
+
+	n.ID = types.GenerateID()
+	n.Created = time.Now()
+
+	err := server.db(ctx).CreateNotification(recipient, n)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to notify", "recipient", recipient.Username, "notification_type", n.Type,
+			"error", err)
+		return
+	}
+
+	server.notificationHub.Publish([]string{recipient.Username}, &realtime.Event{Type: eventNotification, Data: n})
+}
+
+//notifyComment notifies the seller of a new comment on their listing and, for replies, the author of the parent comment
+func (server *server) notifyComment(ctx context.Context, comment *types.Comment) {
+	seller, parent, err := server.db(ctx).CommentRecipients(comment)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to find who to notify of comment", "comment_id", comment.ID,
+			"error", err)
+		return
+	}
+
+	if parent != nil {
+		server.notify(ctx, parent, &types.Notification{Type: types.NotifyReply, Actor: comment.Author, ListingID: comment.ListingID, CommentID: comment.ID})
+	}
+
+	if parent == nil || parent.Username != seller.Username {
+		server.notify(ctx, seller, &types.Notification{Type: types.NotifyComment, Actor: comment.Author, ListingID: comment.ListingID, CommentID: comment.ID})
+	}
+}
+
+func (server *server) getNotifications(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	position, limit, err := readPage(req)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	before, beforeID, err := newestFirst(position)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	unreadOnly := false
+	if unread := req.URL.Query().Get("unread"); unread != "" {
+		unreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			errs.Write(w, errs.Invalid("unread", "Invalid unread parameter"))
+			return
+		}
+	}
+
+	notifications, err := server.db(req.Context()).ListNotifications(user, before, beforeID, unreadOnly, limit+1)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list notifications", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	unread, err := server.db(req.Context()).CountUnreadNotifications(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to count notifications", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	response := NotificationsResponse{Notifications: notifications, Unread: unread}
+	if len(notifications) > limit {
+		last := notifications[limit-1]
+		response.Notifications = notifications[:limit]
+		response.NextCursor = timeCursor(last.Created, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+//readNotifications marks the listed notifications as read, or all of them if the request asks for it
+func (server *server) readNotifications(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &ReadNotificationsRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	ids := request.IDs
+	if request.All {
+		ids = nil
+	}
+
+	err = server.db(req.Context()).MarkNotificationsRead(user, ids)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to mark notifications read", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//streamNotifications pushes the authenticated user's notifications as Server-Sent Events. Clients reconnecting with
+//a Last-Event-ID are first sent the notifications they missed. The write timeout cannot be lifted for a single
+//response, so streams end shortly before it and when the server shuts down, and clients reconnect
+func (server *server) streamNotifications(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errs.Write(w, errs.New(errs.CodeInternal, "Streaming not supported"))
+		return
+	}
+
+	// Subscribe before replaying so nothing created in between is lost. Replayed notifications are skipped when they
+	// come in live as well
+	subscription := server.notificationHub.Subscribe(user.Username)
+	defer subscription.Close()
+
+	var missed []*types.Notification
+	lastID := req.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = req.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		var err error
+		missed, err = server.db(req.Context()).GetNotificationsSince(user, lastID, maxReplay)
+		if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to replay notifications", "username", user.Username,
+				"error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+
+	sent := make(map[string]bool, len(missed))
+	for _, n := range missed {
+		if err := writeNotification(w, n); err != nil {
+			return
+		}
+		sent[n.ID] = true
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+
+	lifetime := server.httpConfig.WriteTimeout - streamRetry
+	if lifetime <= 0 {
+		lifetime = server.httpConfig.WriteTimeout / 2
+	}
+	end := time.NewTimer(lifetime)
+	defer end.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-end.C:
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return
+			}
+
+			n := event.Data.(*types.Notification)
+			if sent[n.ID] {
+				continue
+			}
+
+			if err := writeNotification(w, n); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeNotification(w http.ResponseWriter, n *types.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", n.ID, eventNotification, data)
+	return err
+}
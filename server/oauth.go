@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/oauth"
+)
+
+//authorizeClient serves the OAuth authorization endpoint for the signed in user
+func (server *server) authorizeClient(w http.ResponseWriter, req *http.Request) {
+	server.oauth.ServeAuthorize(w, req, userFromContext(req.Context()).Username)
+}
+
+//registerOAuthClient registers a partner app owned by the user and writes it along with its secret, which cannot be
+//retrieved again
+func (server *server) registerOAuthClient(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &RegisterClientRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	client, secret, err := server.oauth.RegisterClient(user.Username, request.Name, request.RedirectURIs, request.Confidential)
+	var invalid *oauth.Error
+	if errors.As(err, &invalid) {
+		errs.Write(w, errs.New(errs.CodeBadRequest, invalid.Description))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to register OAuth client", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, OAuthClientResponse{Client: client, Secret: secret})
+}
+
+func (server *server) getOAuthClients(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	clients, err := server.oauth.Clients(user.Username)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list OAuth clients", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, OAuthClientsResponse{Clients: clients})
+}
+
+//deleteOAuthClient deletes one of the user's clients, revoking every token issued to it
+func (server *server) deleteOAuthClient(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	id := pathParam(req, "client_id")
+
+	deleted, err := server.oauth.DeleteClient(user.Username, id)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to delete OAuth client", "client_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if !deleted {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Client not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//getConsents lists the apps the user allowed access to their account
+func (server *server) getConsents(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+
+	consents, err := server.oauth.Consents(user.Username)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to list consents", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ConsentsResponse{Consents: consents})
+}
+
+//withdrawConsent takes an app's access to the user's account away, revoking its tokens
+func (server *server) withdrawConsent(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	id := pathParam(req, "client_id")
+
+	withdrawn, err := server.oauth.Withdraw(user.Username, id)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to withdraw consent", "username", user.Username,
+			"client_id", id, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if !withdrawn {
+		errs.Write(w, errs.New(errs.CodeNotFound, "Consent not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
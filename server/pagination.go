@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/errs"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	errInvalidLimit  = errs.Invalid("limit", fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+	errInvalidCursor = errs.Invalid("cursor", "Invalid cursor")
+)
+
+//readPage reads the cursor and limit query parameters of a paginated request. Cursors are opaque to clients and
+//decode to the position the previous page ended at
+func readPage(req *http.Request) (string, int, error) {
+	query := req.URL.Query()
+
+	limit := defaultPageSize
+	if l := query.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return "", 0, errInvalidLimit
+		}
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(query.Get("cursor"))
+	if err != nil {
+		return "", 0, errInvalidCursor
+	}
+
+	return string(after), limit, nil
+}
+
+//encodeCursor turns the position a page ended at into a cursor for the next page
+func encodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+//timeCursor is the cursor for lists ordered by time with an ID breaking ties
+func timeCursor(t time.Time, id string) string {
+	return encodeCursor(t.Format(time.RFC3339Nano) + "|" + id)
+}
+
+//parseTimeCursor parses the position of a cursor created by timeCursor
+func parseTimeCursor(position string) (time.Time, string, error) {
+	parts := strings.SplitN(position, "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", errInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", errInvalidCursor
+	}
+
+	return t, parts[1], nil
+}
+
+//newestFirst returns the position the first item of a page of a newest-first list has to come before
+func newestFirst(position string) (time.Time, string, error) {
+	if position == "" {
+		return time.Now().Add(24 * time.Hour), "", nil
+	}
+
+	return parseTimeCursor(position)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const defaultResetTTL = time.Hour
+
+//forgotPassword emails a password reset link to the account with the given email address. The response is the same
+//whether or not there is such an account, so it cannot be used to find out who has signed up
+func (server *server) forgotPassword(w http.ResponseWriter, req *http.Request) {
+	request := &ForgotPasswordRequest{}
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	if request.Email != "" {
+		err = server.sendPasswordReset(req.Context(), request.Email)
+		if err != nil {
+			logging.FromContext(req.Context()).Error("Unable to send password reset", "error", err)
+			errs.Write(w, errs.ErrInternal)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (server *server) sendPasswordReset(ctx context.Context, address string) error {
+	user, err := server.db(ctx).GetUser(&types.User{Email: address})
+	if err != nil {
+		return err
+	}
+
+	if user == nil || user.Email != address {
+		return nil
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(server.authConfig.ResetTTL)
+	e, err := email.New(email.PasswordReset, user.Username, &email.LinkData{
+		Username: user.Username,
+		Link:     server.emailConfig.BaseURL + "/reset-password?token=" + token,
+		Expires:  expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	return server.db(ctx).CreatePasswordReset(user, auth.HashToken(token), expires, e)
+}
+
+//resetPassword sets a new password using a reset token and logs the user out everywhere, revoking their API keys and
+//OAuth grants as well
+func (server *server) resetPassword(w http.ResponseWriter, req *http.Request) {
+	request := &ResetPasswordRequest{}
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to hash password", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	user, err := server.db(req.Context()).ResetPassword(auth.HashToken(request.Token), hash, time.Now())
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeBadRequest, "Invalid or expired reset token"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to reset password", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Password reset", "username", user.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//changePassword replaces the authenticated user's password after checking the current one. Every session of the user
+//ends, including the one making the request, and a new session is returned in its place. Their API keys and OAuth
+//grants are revoked
+func (server *server) changePassword(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &ChangePasswordRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	current, err := server.db(req.Context()).GetUser(&types.User{Username: user.Username})
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve user", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if current == nil || !auth.CheckPassword(current.Password, request.CurrentPassword) {
+		errs.Write(w, errs.New(errs.CodeForbidden, auth.ErrWrongPassword.Error()))
+		return
+	}
+
+	hash, err := auth.HashPassword(request.NewPassword)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to hash password", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	err = server.db(req.Context()).ChangePassword(user, hash)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to change password", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Password changed", "username", user.Username)
+	server.startSession(w, req, user)
+}
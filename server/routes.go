@@ -0,0 +1,126 @@
+package server
+
+import (
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/types"
+)
+
+//routes builds the router serving the API. Every request gets an ID, is traced, logged, measured and recovered from
+//panics, and CORS headers are added for the configured origins. Routes taking an ID or username in the path also take
+//it as a query parameter on the path without it, which is how they were called before paths had parameters
+func (s *server) routes() *router.Router {
+	r := router.New()
+	r.Use(router.RequestID, router.Trace, router.LogRequests, router.Instrument, router.Recover,
+		router.CORS(s.httpConfig.CORS))
+
+	verified := router.Chain(s.authenticate, s.verified)
+	readListings := router.Chain(keyScope(types.ScopeReadListings), s.identify)
+	manageListings := router.Chain(keyScope(types.ScopeManageListings), s.authenticate)
+
+	r.Get("/healthz", s.healthz)
+	r.Get("/readyz", s.readyz)
+	r.Get("/metrics", s.metrics)
+
+	r.Post("/signup", s.newUser)
+	r.Post("/login", s.login)
+	r.Post("/login/2fa", s.loginSecondFactor)
+	r.Post("/logout", s.logout, s.authenticate)
+
+	r.Post("/follow", s.follow, s.authenticate)
+	r.Post("/unfollow", s.unfollow, s.authenticate)
+	r.Get("/users/{username}/followers", s.getFollowers, s.identify)
+	r.Get("/users/{username}/following", s.getFollowing, s.identify)
+	r.Get("/followers", s.getFollowers, s.identify)
+	r.Get("/following", s.getFollowing, s.identify)
+
+	r.Get("/blocks", s.listRelationships((*neo4j.Client).ListBlocked), s.authenticate)
+	r.Post("/blocks", s.createRelationship((*neo4j.Client).Block), s.authenticate)
+	r.Delete("/blocks/{username}", s.removeRelationship((*neo4j.Client).Unblock), s.authenticate)
+	r.Delete("/blocks", s.removeRelationship((*neo4j.Client).Unblock), s.authenticate)
+	r.Get("/mutes", s.listRelationships((*neo4j.Client).ListMuted), s.authenticate)
+	r.Post("/mutes", s.createRelationship((*neo4j.Client).Mute), s.authenticate)
+	r.Delete("/mutes/{username}", s.removeRelationship((*neo4j.Client).Unmute), s.authenticate)
+	r.Delete("/mutes", s.removeRelationship((*neo4j.Client).Unmute), s.authenticate)
+
+	r.Get("/listings/{id}", s.getListing, readListings)
+	r.Get("/listings", s.getListing, readListings)
+	r.Post("/listings", s.createListing, manageListings, s.verified)
+	r.Put("/listings/{id}/track", s.uploadTrack, manageListings, s.verified)
+	r.Get("/discounts", s.getDiscounts, manageListings)
+	r.Post("/discounts", s.createDiscount, manageListings)
+	r.Delete("/discounts/{id}", s.deleteDiscount, manageListings)
+	r.Delete("/discounts", s.deleteDiscount, manageListings)
+	r.Post("/checkout", s.checkout, s.authenticate)
+	r.Get("/tax-report", s.taxReport, keyScope(types.ScopeReadSales), s.authenticate)
+	r.Get("/download-link", s.downloadLink, s.authenticate)
+	r.Get("/download", s.download)
+
+	r.Get("/feed", s.getFeed, s.authenticate)
+	r.Get("/likes", s.listReactions((*neo4j.Client).ListLikes), s.identify)
+	r.Post("/likes", s.react((*neo4j.Client).Like, nil), s.authenticate)
+	r.Delete("/likes/{listing_id}", s.unreact((*neo4j.Client).Unlike), s.authenticate)
+	r.Delete("/likes", s.unreact((*neo4j.Client).Unlike), s.authenticate)
+	r.Get("/reposts", s.listReactions((*neo4j.Client).ListReposts), s.identify)
+	r.Post("/reposts", s.react((*neo4j.Client).Repost, s.publishRepost), s.authenticate)
+	r.Delete("/reposts/{listing_id}", s.unreact((*neo4j.Client).Unrepost), s.authenticate)
+	r.Delete("/reposts", s.unreact((*neo4j.Client).Unrepost), s.authenticate)
+	r.Get("/comments", s.getComments, s.identify)
+	r.Post("/comments", s.createComment, s.authenticate)
+	r.Delete("/comments/{id}", s.deleteComment, s.authenticate)
+	r.Delete("/comments", s.deleteComment, s.authenticate)
+
+	r.Get("/conversations", s.getConversations, verified)
+	r.Post("/conversations", s.createConversation, verified)
+	r.Get("/messages", s.getMessages, verified)
+	r.Post("/messages", s.sendMessage, verified)
+	r.Post("/messages/read", s.readMessages, verified)
+	r.Get("/ws", s.websocket, socketToken, verified)
+
+	r.Get("/notifications", s.getNotifications, s.authenticate)
+	r.Post("/notifications/read", s.readNotifications, s.authenticate)
+	r.Get("/notifications/stream", s.streamNotifications, queryToken, s.authenticate)
+
+	r.Get("/email-preferences", s.getEmailPreferences, s.authenticate)
+	r.Put("/email-preferences", s.setEmailPreferences, s.authenticate)
+	r.Get("/verify-email", s.verifyEmailAddress)
+	r.Post("/verify-email", s.verifyEmailAddress)
+	r.Post("/verify-email/resend", s.resendVerification, s.authenticate)
+	r.Put("/email", s.changeEmail, s.authenticate)
+	r.Post("/forgot-password", s.forgotPassword)
+	r.Post("/reset-password", s.resetPassword)
+	r.Post("/change-password", s.changePassword, s.authenticate)
+
+	r.Get("/2fa", s.twoFactor, s.authenticate)
+	r.Post("/2fa/enroll", s.enrollTwoFactor, s.authenticate)
+	r.Post("/2fa/confirm", s.confirmTwoFactor, s.authenticate)
+	r.Post("/2fa/disable", s.disableTwoFactor, s.authenticate)
+
+	r.Get("/api-keys", s.getAPIKeys, verified)
+	r.Post("/api-keys", s.createAPIKey, verified)
+	r.Delete("/api-keys/{id}", s.revokeAPIKey, verified)
+	r.Delete("/api-keys", s.revokeAPIKey, verified)
+
+	r.Get("/oauth/authorize", s.authorizeClient, verified)
+	r.Post("/oauth/authorize", s.authorizeClient, verified)
+	r.Post("/oauth/token", s.oauth.ServeToken)
+	r.Post("/oauth/revoke", s.oauth.ServeRevoke)
+	r.Get("/oauth/clients", s.getOAuthClients, verified)
+	r.Post("/oauth/clients", s.registerOAuthClient, verified)
+	r.Delete("/oauth/clients/{client_id}", s.deleteOAuthClient, verified)
+	r.Delete("/oauth/clients", s.deleteOAuthClient, verified)
+	r.Get("/oauth/consents", s.getConsents, s.authenticate)
+	r.Delete("/oauth/consents/{client_id}", s.withdrawConsent, s.authenticate)
+	r.Delete("/oauth/consents", s.withdrawConsent, s.authenticate)
+
+	r.Get("/admin/users", s.searchUsers, s.authenticate, s.permitted(auth.PermViewUsers))
+	r.Post("/admin/users/suspend", s.suspendUser, s.authenticate, s.permitted(auth.PermSuspendUsers))
+	r.Post("/admin/users/reinstate", s.reinstateUser, s.authenticate, s.permitted(auth.PermSuspendUsers))
+	r.Put("/admin/users/roles", s.setRoles, s.authenticate, s.permitted(auth.PermManageRoles))
+	r.Post("/admin/listings/remove", s.removeListing, s.authenticate, s.permitted(auth.PermRemoveListings))
+	r.Get("/admin/transactions", s.getTransactions, s.authenticate, s.permitted(auth.PermViewTransactions))
+	r.Get("/admin/audit", s.getAuditLog, s.authenticate, s.permitted(auth.PermViewAuditLog))
+
+	return r
+}
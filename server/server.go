@@ -1,57 +1,310 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
+	"github.com/danny-m08/music-match/auth"
 	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/download"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/feed"
+	"github.com/danny-m08/music-match/health"
 	"github.com/danny-m08/music-match/logging"
 	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/oauth"
+	"github.com/danny-m08/music-match/realtime"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/storage"
+	"github.com/danny-m08/music-match/tax"
+	"github.com/danny-m08/music-match/tracing"
+	"github.com/gorilla/websocket"
 	"net/http"
-	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type server struct {
-	neo4jClient *neo4j.Client
-	httpConfig  *config.HTTPConfig
+	neo4jClient     *neo4j.Client
+	httpConfig      *config.HTTPConfig
+	authConfig      *config.AuthConfig
+	cipher          *auth.Cipher
+	loginThrottle   *loginThrottle
+	emailConfig     *config.EmailConfig
+	taxTable        *tax.Table
+	store           storage.Store
+	signer          *download.Signer
+	downloads       *config.DownloadConfig
+	maxUploadSize   int64
+	feed            feed.Feed
+	messageHub      *realtime.Hub
+	upgrader        *websocket.Upgrader
+	notificationHub *realtime.Hub
+	mailer          *email.Worker
+	oauth           *oauth.Provider
+	router          *router.Router
+	httpServer      *http.Server
+	health          *health.Checker
+	drainDelay      time.Duration
+	metricsToken    string
+	stopWorkers     context.CancelFunc
+	workers         sync.WaitGroup
+	//lastHousekeeping is when housekeeping last ran, in Unix nanoseconds
+	lastHousekeeping int64
 }
 
-func NewServer(conf *config.HTTPConfig, neo4jConfig *config.Neo4jConfig) (*server, error) {
-	client, err := neo4j.NewClient(neo4jConfig)
+const (
+	defaultSessionTTL   = 24 * time.Hour
+	defaultLinkTTL      = 15 * time.Minute
+	defaultMaxDownloads = 5
+	//defaultMaxUploadSize is the size of the largest track file sellers can upload, in bytes
+	defaultMaxUploadSize = 200 << 20
+	//defaultEmailRetention is how long sent and failed emails are kept in the outbox
+	defaultEmailRetention = 30 * 24 * time.Hour
+
+	defaultReadTimeout     = 30 * time.Second
+	defaultWriteTimeout    = 5 * time.Minute
+	defaultIdleTimeout     = 2 * time.Minute
+	defaultShutdownTimeout = 30 * time.Second
+	defaultWorkerTimeout   = 10 * time.Second
+
+	housekeepingInterval = time.Hour
+)
+
+func NewServer(conf *config.Config) (*server, error) {
+	if conf == nil {
+		return nil, errors.New("Config cannot be nil")
+	}
+
+	client, err := neo4j.NewClient(conf.GetDBConfig())
 	if err != nil {
 		return nil, err
 	}
 
-	if conf == nil {
+	httpConfig := conf.GetHTTPServerConfig()
+	if httpConfig == nil {
 		return nil, errors.New("Http config cannot be nil")
 	}
+	if httpConfig.ReadTimeout == 0 {
+		httpConfig.ReadTimeout = defaultReadTimeout
+	}
+	if httpConfig.WriteTimeout == 0 {
+		httpConfig.WriteTimeout = defaultWriteTimeout
+	}
+	if httpConfig.IdleTimeout == 0 {
+		httpConfig.IdleTimeout = defaultIdleTimeout
+	}
+	if httpConfig.ShutdownTimeout == 0 {
+		httpConfig.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if httpConfig.WorkerTimeout == 0 {
+		httpConfig.WorkerTimeout = defaultWorkerTimeout
+	}
+
+	authConfig := conf.GetAuthConfig()
+	if authConfig.SessionTTL == 0 {
+		authConfig.SessionTTL = defaultSessionTTL
+	}
+	if authConfig.VerificationTTL == 0 {
+		authConfig.VerificationTTL = defaultVerificationTTL
+	}
+	if authConfig.UnverifiedTTL == 0 {
+		authConfig.UnverifiedTTL = defaultUnverifiedTTL
+	}
+	if authConfig.ResetTTL == 0 {
+		authConfig.ResetTTL = defaultResetTTL
+	}
+
+	var cipher *auth.Cipher
+	if authConfig.EncryptionKey != "" {
+		cipher, err = auth.NewCipher(authConfig.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		logging.Warn("No encryption key configured -- two-factor authentication is unavailable")
+	}
+
+	limiter, err := newLoginThrottle(conf.GetThrottleConfig(), client)
+	if err != nil {
+		return nil, err
+	}
 
-	return &server{
-		neo4jClient: client,
-		httpConfig:  conf,
-	}, nil
+	taxTable, err := tax.NewTable(conf.GetTaxConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	storageConfig := conf.GetStorageConfig()
+	store, err := storage.NewFileStore(storageConfig)
+	if err != nil {
+		return nil, err
+	}
+	if storageConfig.MaxUploadSize == 0 {
+		storageConfig.MaxUploadSize = defaultMaxUploadSize
+	}
+
+	downloads := conf.GetDownloadConfig()
+	if downloads.LinkTTL == 0 {
+		downloads.LinkTTL = defaultLinkTTL
+	}
+	if downloads.MaxDownloads == 0 {
+		downloads.MaxDownloads = defaultMaxDownloads
+	}
+
+	key := []byte(downloads.SigningKey)
+	if len(key) == 0 {
+		logging.Warn("No download signing key configured -- download links will not survive a restart")
+		key, err = randomKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signer, err := download.NewSigner(key)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := feed.New(conf.GetFeedConfig(), client)
+	if err != nil {
+		return nil, err
+	}
+
+	var mailer *email.Worker
+	emailConfig := conf.GetEmailConfig()
+	if emailConfig.Retention == 0 {
+		emailConfig.Retention = defaultEmailRetention
+	}
+	if emailConfig.Host != "" {
+		sender, err := email.NewSMTPSender(emailConfig)
+		if err != nil {
+			return nil, err
+		}
+		mailer = email.NewWorker(emailConfig, client, sender)
+	} else {
+		logging.Warn("No SMTP host configured -- emails will be queued but not sent")
+	}
+
+	s := &server{
+		neo4jClient:     client,
+		httpConfig:      httpConfig,
+		authConfig:      authConfig,
+		cipher:          cipher,
+		loginThrottle:   limiter,
+		emailConfig:     emailConfig,
+		taxTable:        taxTable,
+		store:           store,
+		signer:          signer,
+		downloads:       downloads,
+		maxUploadSize:   storageConfig.MaxUploadSize,
+		feed:            activity,
+		messageHub:      realtime.NewHub(),
+		upgrader:        newUpgrader(httpConfig.CORS),
+		notificationHub: realtime.NewHub(),
+		mailer:          mailer,
+		oauth:           oauth.NewProvider(conf.GetOAuthConfig(), client),
+		health:          health.NewChecker(conf.GetHealthConfig()),
+		drainDelay:      conf.GetHealthConfig().DrainDelay,
+		metricsToken:    conf.GetMetricsConfig().Token,
+	}
+	s.registerChecks(conf.GetHealthConfig().OutboxLag)
+	if s.metricsToken == "" {
+		logging.Warn("No metrics token is configured, /metrics is served to anyone")
+	}
+	s.router = s.routes()
+	s.httpServer = &http.Server{
+		Addr:         httpConfig.ListenAddr,
+		Handler:      s.router,
+		ReadTimeout:  httpConfig.ReadTimeout,
+		WriteTimeout: httpConfig.WriteTimeout,
+		IdleTimeout:  httpConfig.IdleTimeout,
+	}
+	// Event streams and WebSockets only end when their subscription is closed, Shutdown does not wait for them
+	s.httpServer.RegisterOnShutdown(s.messageHub.Close)
+	s.httpServer.RegisterOnShutdown(s.notificationHub.Close)
+	s.grantAdmins(authConfig.Admins)
+
+	return s, nil
 }
 
-//StartServer runs a http server using the given config object
+//StartServer starts the background jobs and serves the API until Shutdown is called, when it returns nil. Any other
+//error means the server could not listen or stopped unexpectedly
 func (s *server) StartServer() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopWorkers = cancel
+	if s.mailer != nil {
+		s.runWorker(func() { s.mailer.Run(ctx) })
+	}
+	s.runWorker(func() { s.housekeeping(ctx) })
 
-	http.HandleFunc("/login", s.login)
-	http.HandleFunc("/signup", s.newUser)
-	http.HandleFunc("/follow", s.follow)
-	http.HandleFunc("/followers", s.getFollowers)
-
-	logging.Info("Server starting and listening on " + s.httpConfig.ListenAddr)
+	logging.Info("Server starting and listening", "listen_addr", s.httpConfig.ListenAddr)
+	var err error
 	if s.httpConfig.TLS != nil && s.httpConfig.TLS.Enabled {
 		logging.Debug("TLS enabled")
-		err := http.ListenAndServeTLS(s.httpConfig.ListenAddr, s.httpConfig.TLS.CertFile, s.httpConfig.TLS.KeyFile, nil)
-		if err != nil {
-			return err
-		}
+		err = s.httpServer.ListenAndServeTLS(s.httpConfig.TLS.CertFile, s.httpConfig.TLS.KeyFile)
+	} else {
+		logging.Debug("TLS disabled")
+		err = s.httpServer.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
-	logging.Debug("TLS disabled")
+	return err
+}
+
+//db returns the Neo4j client tracing its operations as part of the request or job in ctx
+func (server *server) db(ctx context.Context) *neo4j.Client {
+	return server.neo4jClient.WithContext(ctx)
+}
 
-	return http.ListenAndServe(s.httpConfig.ListenAddr, nil)
+//runWorker runs a background job that Shutdown waits for
+func (s *server) runWorker(job func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		job()
+	}()
 }
 
-func (s *server) Close() error {
+//Shutdown stops the server gracefully. It reports not ready for the drain delay, then stops accepting connections,
+//ends event streams and WebSockets, and waits for in-flight requests to finish, forcing connections closed once
+//ShutdownTimeout has passed. Background jobs then get WorkerTimeout of their own, so slow requests cannot use up their
+//time. The Neo4j driver is closed last, after nothing can use it anymore
+func (s *server) Shutdown() error {
+	s.health.Drain()
+	time.Sleep(s.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.httpConfig.ShutdownTimeout)
+	defer cancel()
+
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		logging.Warn("Requests still in flight after the shutdown timeout -- closing their connections", "error", err)
+		_ = s.httpServer.Close()
+	}
+
+	if s.stopWorkers != nil {
+		s.stopWorkers()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(s.httpConfig.WorkerTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		logging.Warn("Background jobs still running after the worker timeout")
+	}
+
 	if s.neo4jClient != nil {
 		return s.neo4jClient.Close()
 	}
@@ -59,6 +312,69 @@ func (s *server) Close() error {
 	return nil
 }
 
-func verifyEmail(email string) (bool, error) {
-	return regexp.Match("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$", []byte(email))
+//housekeeping periodically deletes the accounts that did not verify their email address in time, stale login
+//attempts and expired OAuth codes and tokens, until the context is cancelled
+func (server *server) housekeeping(ctx context.Context) {
+	ticker := time.NewTicker(housekeepingInterval)
+	defer ticker.Stop()
+
+	for {
+		server.cleanUp(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+//cleanUp runs one round of housekeeping in a span of its own
+func (server *server) cleanUp(ctx context.Context) {
+	now := time.Now()
+	atomic.StoreInt64(&server.lastHousekeeping, now.UnixNano())
+	ctx, span := tracing.Start(ctx, "housekeeping")
+	var failed error
+
+	purged, err := server.db(ctx).PurgeUnverifiedUsers(now.Add(-server.authConfig.UnverifiedTTL))
+	if err != nil {
+		failed = err
+		logging.FromContext(ctx).Error("Unable to purge unverified users", "error", err)
+	} else if purged > 0 {
+		logging.FromContext(ctx).Info("Purged unverified users", "purged", purged)
+	}
+
+	err = server.db(ctx).PruneAttempts(now.Add(-server.loginThrottle.window))
+	if err != nil {
+		failed = err
+		logging.FromContext(ctx).Error("Unable to prune login attempts", "error", err)
+	}
+
+	err = server.oauth.Prune()
+	if err != nil {
+		failed = err
+		logging.FromContext(ctx).Error("Unable to prune OAuth tokens", "error", err)
+	}
+
+	err = server.feed.Prune(ctx, now)
+	if err != nil {
+		failed = err
+		logging.FromContext(ctx).Error("Unable to prune feed entries", "error", err)
+	}
+
+	pruned, err := server.db(ctx).PruneEmails(now.Add(-server.emailConfig.Retention))
+	if err != nil {
+		failed = err
+		logging.FromContext(ctx).Error("Unable to prune sent emails", "error", err)
+	} else if pruned > 0 {
+		logging.FromContext(ctx).Info("Pruned sent emails", "pruned", pruned)
+	}
+
+	tracing.End(span, failed)
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
 }
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/tax"
+)
+
+const reportDateFormat = "2006-01-02"
+
+//taxReport exports the tax collected on the authenticated seller's sales between the from and to dates, both
+//inclusive, as CSV or as JSON when format=json is given
+func (server *server) taxReport(w http.ResponseWriter, req *http.Request) {
+	seller := userFromContext(req.Context())
+	query := req.URL.Query()
+
+	from, err := time.Parse(reportDateFormat, query.Get("from"))
+	if err != nil {
+		errs.Write(w, errs.Invalid("from", "from must be a date in the format "+reportDateFormat))
+		return
+	}
+
+	to, err := time.Parse(reportDateFormat, query.Get("to"))
+	if err != nil {
+		errs.Write(w, errs.Invalid("to", "to must be a date in the format "+reportDateFormat))
+		return
+	}
+
+	if to.Before(from) {
+		errs.Write(w, errs.Invalid("to", "to must not be before from"))
+		return
+	}
+
+	txs, err := server.db(req.Context()).GetSellerTransactions(seller, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve transactions", "seller", seller.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	report, err := tax.Report(txs)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to build tax report", "seller", seller.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if query.Get("format") == "json" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=tax-report-%s-%s.csv", query.Get("from"), query.Get("to")))
+	err = tax.WriteCSV(w, report)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to write tax report", "error", err)
+	}
+}
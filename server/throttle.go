@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/throttle"
+	"github.com/danny-m08/music-match/types"
+)
+
+var (
+	defaultAccountPolicy = throttle.Policy{
+		Free:      3,
+		Base:      time.Second,
+		MaxDelay:  time.Minute,
+		LockAfter: 10,
+		Lockout:   15 * time.Minute,
+		Window:    time.Hour,
+	}
+	defaultIPPolicy = throttle.Policy{
+		Free:      20,
+		Base:      time.Second,
+		MaxDelay:  time.Minute,
+		LockAfter: 100,
+		Lockout:   15 * time.Minute,
+		Window:    time.Hour,
+	}
+)
+
+//loginThrottle holds off login attempts after failures, both per account so a single password cannot be guessed and
+//per IP address so one client cannot try many accounts
+type loginThrottle struct {
+	*throttle.Login
+	lockout time.Duration
+	window  time.Duration
+}
+
+func newLoginThrottle(conf *config.ThrottleConfig, client *neo4j.Client) (*loginThrottle, error) {
+	var store throttle.Store
+	switch conf.Store {
+	case "", throttle.StoreMemory:
+		store = throttle.NewMemoryStore()
+	case throttle.StoreShared:
+		store = client
+	default:
+		return nil, fmt.Errorf("%w: %s", throttle.ErrUnknownStore, conf.Store)
+	}
+
+	accounts := throttlePolicy(conf.Account, defaultAccountPolicy)
+	ips := throttlePolicy(conf.IP, defaultIPPolicy)
+
+	lt := &loginThrottle{
+		Login: &throttle.Login{
+			Accounts: throttle.NewLimiter(store, accounts),
+			Clients:  throttle.NewLimiter(store, ips),
+		},
+		lockout: accounts.Lockout,
+		window:  accounts.Window,
+	}
+	for _, d := range []time.Duration{ips.Window, accounts.Lockout, ips.Lockout} {
+		if d > lt.window {
+			lt.window = d
+		}
+	}
+
+	return lt, nil
+}
+
+//throttlePolicy fills in the settings missing from the configured policy with the defaults
+func throttlePolicy(conf *config.ThrottlePolicy, defaults throttle.Policy) throttle.Policy {
+	if conf == nil {
+		return defaults
+	}
+
+	policy := throttle.Policy{
+		Free:      conf.Free,
+		Base:      conf.Base,
+		MaxDelay:  conf.MaxDelay,
+		LockAfter: conf.LockAfter,
+		Lockout:   conf.Lockout,
+		Window:    conf.Window,
+	}
+	if policy.Free == 0 {
+		policy.Free = defaults.Free
+	}
+	if policy.Base == 0 {
+		policy.Base = defaults.Base
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = defaults.MaxDelay
+	}
+	if policy.LockAfter == 0 {
+		policy.LockAfter = defaults.LockAfter
+	}
+	if policy.Lockout == 0 {
+		policy.Lockout = defaults.Lockout
+	}
+	if policy.Window == 0 {
+		policy.Window = defaults.Window
+	}
+
+	return policy
+}
+
+func accountKey(address string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(address))
+}
+
+func ipKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+//allowLogin reserves a login step for the client and the account, which counts as a failure of both until the login
+//succeeds. It writes a 429 response and returns false if either of them has to wait before trying again. Checking and
+//counting the attempt happen at once, so concurrent requests cannot get more guesses than the throttle allows.
+//Throttling fails open, so an unavailable store does not lock everyone out
+func (server *server) allowLogin(w http.ResponseWriter, req *http.Request, address string) (*throttle.LoginAttempt,
+	bool) {
+	attempt, wait, err := server.loginThrottle.Attempt(ipKey(req), accountKey(address), time.Now())
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to check login attempts", "error", err)
+	}
+
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		errs.Write(w, errs.New(errs.CodeRateLimited, "Too many failed login attempts, try again later"))
+		return nil, false
+	}
+
+	return attempt, true
+}
+
+//loginFailed leaves the attempt counted against the client and the account. The owner of the account, if it exists,
+//is emailed when the failure locks it
+func (server *server) loginFailed(req *http.Request, attempt *throttle.LoginAttempt, user *types.User) {
+	if !attempt.Locks || user == nil {
+		return
+	}
+
+	now := time.Now()
+	logging.FromContext(req.Context()).Warn("Locked out after repeated failed logins", "username", user.Username)
+	e, err := email.New(email.Lockout, user.Username, &email.LinkData{
+		Username: user.Username,
+		Link:     server.emailConfig.BaseURL + "/forgot-password",
+		Expires:  now.Add(server.loginThrottle.lockout),
+	})
+	if err == nil {
+		err = server.db(req.Context()).EnqueueEmails(e)
+	}
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to notify of the lockout", "username", user.Username,
+			"error", err)
+	}
+}
+
+//loginContinued takes back the client's attempt once the password of an account with two-factor authentication was
+//correct. The account's attempt stays counted, so cycling through login challenges cannot reset its failures
+func (server *server) loginContinued(ctx context.Context, attempt *throttle.LoginAttempt) {
+	if err := server.loginThrottle.Continue(attempt); err != nil {
+		logging.FromContext(ctx).Error("Unable to release login attempt", "error", err)
+	}
+}
+
+//loginSucceeded forgets the failed attempts on the account once the login is complete
+func (server *server) loginSucceeded(ctx context.Context, attempt *throttle.LoginAttempt) {
+	if err := server.loginThrottle.Succeed(attempt); err != nil {
+		logging.FromContext(ctx).Error("Unable to reset login attempts", "error", err)
+	}
+}
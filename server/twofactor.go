@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	totpIssuer           = "Music Match"
+	recoveryCodeCount    = 10
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+)
+
+var errTwoFactorDisabled = errors.New("Two-factor authentication is not configured on this server")
+
+//challenge answers a login whose password was correct for a user with two-factor authentication enabled. The user is
+//logged in once they send the code to /login/2fa along with the challenge token
+func (server *server) challenge(w http.ResponseWriter, req *http.Request, user *types.User) {
+	token, err := auth.NewToken()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate login challenge", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	expires := time.Now().Add(loginChallengeTTL)
+	err = server.db(req.Context()).CreateLoginChallenge(user, auth.HashToken(token), expires)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create login challenge", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ChallengeResponse{TwoFactorRequired: true, Challenge: token, Expires: expires})
+}
+
+//loginSecondFactor completes a login that was challenged for a TOTP or recovery code. Every code sent counts towards
+//the account's login throttle like a password, and rejected or expired codes are left counted as failures, so opening
+//new challenges with a known password does not give endless guesses at the second factor
+func (server *server) loginSecondFactor(w http.ResponseWriter, req *http.Request) {
+	request := &SecondFactorRequest{}
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	challengeHash := auth.HashToken(request.Challenge)
+	user, valid, err := server.db(req.Context()).AttemptLoginChallenge(challengeHash, time.Now(), maxChallengeAttempts)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeUnauthorized, "Invalid or expired login challenge"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve login challenge", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	attempt, ok := server.allowLogin(w, req, user.Email)
+	if !ok {
+		return
+	}
+
+	if !valid {
+		server.loginFailed(req, attempt, user)
+		errs.Write(w, errs.New(errs.CodeUnauthorized, "Invalid or expired login challenge"))
+		return
+	}
+
+	ok, err = server.checkSecondFactor(req.Context(), user, request.Code, request.RecoveryCode)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to check second factor", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	} else if !ok {
+		server.loginFailed(req, attempt, user)
+		errs.Write(w, errs.New(errs.CodeUnauthorized, "Invalid code"))
+		return
+	}
+
+	err = server.db(req.Context()).DeleteLoginChallenge(challengeHash)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to delete login challenge", "error", err)
+	}
+
+	server.loginSucceeded(req.Context(), attempt)
+	server.startSession(w, req, user)
+}
+
+//checkSecondFactor checks a TOTP code, or a recovery code if one is given, and uses it up
+func (server *server) checkSecondFactor(ctx context.Context, user *types.User, code,
+	recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return server.db(ctx).UseRecoveryCode(user, auth.HashRecoveryCode(recoveryCode))
+	}
+
+	if server.cipher == nil {
+		return false, errTwoFactorDisabled
+	}
+
+	tf, err := server.db(ctx).GetTwoFactor(user)
+	if err != nil {
+		return false, err
+	}
+
+	if !tf.Enabled {
+		return false, nil
+	}
+
+	secret, err := server.cipher.Decrypt(tf.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return server.db(ctx).UseTOTPStep(user, step)
+}
+
+//twoFactor reports whether the authenticated user has two-factor authentication enabled
+func (server *server) twoFactor(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	tf, err := server.db(req.Context()).GetTwoFactor(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve two-factor state", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TwoFactorResponse{Enabled: tf.Enabled, RecoveryCodes: tf.RecoveryCodes})
+}
+
+//enrollTwoFactor starts a TOTP enrollment and returns the secret and the provisioning URI to show as a QR code.
+//Two-factor authentication is only enabled once a code generated from the secret is confirmed
+func (server *server) enrollTwoFactor(w http.ResponseWriter, req *http.Request) {
+	if server.cipher == nil {
+		errs.Write(w, errs.New(errs.CodeUnavailable, errTwoFactorDisabled.Error()))
+		return
+	}
+
+	user := userFromContext(req.Context())
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate TOTP secret", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	encrypted, err := server.cipher.Encrypt(secret)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to encrypt TOTP secret", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	err = server.db(req.Context()).StartTwoFactor(user, encrypted)
+	if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeAlreadyExists, "Two-factor authentication is already enabled"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to start two-factor enrollment", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, EnrollmentResponse{
+		Secret: secret,
+		URI:    auth.TOTPURI(totpIssuer, user.Username, secret),
+	})
+}
+
+//confirmTwoFactor enables two-factor authentication once the user proves their authenticator works. The recovery
+//codes are returned this once and only their hashes are kept
+func (server *server) confirmTwoFactor(w http.ResponseWriter, req *http.Request) {
+	if server.cipher == nil {
+		errs.Write(w, errs.New(errs.CodeUnavailable, errTwoFactorDisabled.Error()))
+		return
+	}
+
+	user := userFromContext(req.Context())
+	request := &SecondFactorRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	tf, err := server.db(req.Context()).GetTwoFactor(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve two-factor state", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if tf.Pending == "" {
+		errs.Write(w, errs.New(errs.CodeConflict, "No two-factor enrollment in progress"))
+		return
+	}
+
+	secret, err := server.cipher.Decrypt(tf.Pending)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to decrypt TOTP secret", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(secret, request.Code, time.Now())
+	if !ok {
+		errs.Write(w, errs.New(errs.CodeBadRequest, "Invalid code"))
+		return
+	}
+
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to generate recovery codes", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashRecoveryCode(code))
+	}
+
+	err = server.db(req.Context()).EnableTwoFactor(user, tf.Pending, hashes, step)
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeConflict, "Two-factor enrollment changed, please start again"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to enable two-factor authentication",
+			"username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Two-factor authentication enabled", "username", user.Username)
+	writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+//disableTwoFactor turns two-factor authentication off. Both the password and a current code or recovery code are
+//required, so a stolen session alone cannot weaken the account
+func (server *server) disableTwoFactor(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &DisableTwoFactorRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	current, err := server.db(req.Context()).GetUser(&types.User{Username: user.Username})
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to retrieve user", "username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	if current == nil || !auth.CheckPassword(current.Password, request.Password) {
+		errs.Write(w, errs.New(errs.CodeForbidden, auth.ErrWrongPassword.Error()))
+		return
+	}
+
+	ok, err := server.checkSecondFactor(req.Context(), user, request.Code, request.RecoveryCode)
+	if errors.Is(err, errTwoFactorDisabled) {
+		errs.Write(w, errs.WithCode(errs.CodeUnavailable, err))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to check second factor", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	} else if !ok {
+		errs.Write(w, errs.New(errs.CodeForbidden, "Invalid code"))
+		return
+	}
+
+	err = server.db(req.Context()).DisableTwoFactor(user)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to disable two-factor authentication",
+			"username", user.Username, "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Two-factor authentication disabled", "username", user.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
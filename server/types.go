@@ -1,6 +1,10 @@
 package server
 
 import (
+	"time"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/oauth"
 	"github.com/danny-m08/music-match/types"
 )
 
@@ -15,7 +19,240 @@ type CreateUserRequest struct {
 	Username string `json:"username"`
 }
 
+//followRequest names the user to follow, unfollow, block or mute
 type followRequest struct {
-	User     *types.User `json:"user"`
-	Follower *types.User `json:"follower"`
+	Username string `json:"username"`
+}
+
+type FollowsResponse struct {
+	Users      []*types.Follow `json:"users"`
+	Count      int64           `json:"count"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type LoginResponse struct {
+	Token    string    `json:"token"`
+	Expires  time.Time `json:"expires"`
+	Verified bool      `json:"verified"`
+}
+
+type CreateListingRequest struct {
+	Price currency.Amount `json:"price"`
+	Track *TrackRequest   `json:"track"`
+}
+
+//TrackRequest names the track of a new listing. Its file is uploaded to PUT /listings/{id}/track once the listing
+//exists
+type TrackRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateDiscountRequest struct {
+	Code          string             `json:"code,omitempty"`
+	ListingID     string             `json:"listing_id,omitempty"`
+	Type          types.DiscountType `json:"type"`
+	Value         string             `json:"value"`
+	MaxUses       int64              `json:"max_uses,omitempty"`
+	PerBuyerLimit int64              `json:"per_buyer_limit,omitempty"`
+	Starts        *time.Time         `json:"starts,omitempty"`
+	Expires       *time.Time         `json:"expires,omitempty"`
+}
+
+type CheckoutRequest struct {
+	ListingID    string `json:"listing_id"`
+	DiscountCode string `json:"discount_code,omitempty"`
+	Country      string `json:"country,omitempty"`
+	Region       string `json:"region,omitempty"`
+}
+
+type DownloadLinkResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+type FeedResponse struct {
+	Items      []*types.FeedItem `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type listingRequest struct {
+	ListingID string `json:"listing_id"`
+}
+
+type UsersResponse struct {
+	Users      []*types.User `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type CreateCommentRequest struct {
+	ListingID string `json:"listing_id"`
+	ParentID  string `json:"parent_id,omitempty"`
+	Body      string `json:"body"`
+}
+
+type CommentsResponse struct {
+	Comments   []*types.Comment `json:"comments"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type CreateConversationRequest struct {
+	Participants []string `json:"participants"`
+	Group        bool     `json:"group"`
+}
+
+type ConversationsResponse struct {
+	Conversations []*types.Conversation `json:"conversations"`
+	NextCursor    string                `json:"next_cursor,omitempty"`
+}
+
+type SendMessageRequest struct {
+	ConversationID string               `json:"conversation_id"`
+	Body           string               `json:"body"`
+	Attachments    []*AttachmentRequest `json:"attachments,omitempty"`
+}
+
+type AttachmentRequest struct {
+	Type      types.AttachmentType `json:"type"`
+	ListingID string               `json:"listing_id"`
+}
+
+type MessagesResponse struct {
+	Messages   []*types.Message `json:"messages"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type conversationRequest struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+type NotificationsResponse struct {
+	Notifications []*types.Notification `json:"notifications"`
+	Unread        int64                 `json:"unread"`
+	NextCursor    string                `json:"next_cursor,omitempty"`
+}
+
+type ReadNotificationsRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ChangeEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type VerificationResponse struct {
+	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	Challenge         string    `json:"challenge"`
+	Expires           time.Time `json:"expires"`
+}
+
+type SecondFactorRequest struct {
+	Challenge    string `json:"challenge,omitempty"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type TwoFactorResponse struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes"`
+}
+
+type EnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type AccountsResponse struct {
+	Users      []*types.Account `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+//moderationRequest names the user or listing a staff action is taken on and why
+type moderationRequest struct {
+	Username  string `json:"username,omitempty"`
+	ListingID string `json:"listing_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type SetRolesRequest struct {
+	Username string       `json:"username"`
+	Roles    []types.Role `json:"roles"`
+}
+
+type SalesResponse struct {
+	Sales      []*types.Listing `json:"sales"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type AuditLogResponse struct {
+	Entries    []*types.AuditEntry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string        `json:"name"`
+	Scopes []types.Scope `json:"scopes"`
+}
+
+//APIKeyResponse is a newly created API key, the only time the key itself is shown
+type APIKeyResponse struct {
+	*types.APIKey
+	Key string `json:"key"`
+}
+
+type APIKeysResponse struct {
+	Keys []*types.APIKey `json:"keys"`
+}
+
+type RegisterClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+//OAuthClientResponse is a newly registered client, the only time a confidential client's secret is shown
+type OAuthClientResponse struct {
+	*oauth.Client
+	Secret string `json:"client_secret,omitempty"`
+}
+
+type OAuthClientsResponse struct {
+	Clients []*oauth.Client `json:"clients"`
+}
+
+type ConsentsResponse struct {
+	Consents []*oauth.Consent `json:"consents"`
 }
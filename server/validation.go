@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/types"
+	"github.com/danny-m08/music-match/validate"
+)
+
+const (
+	maxReasonLength     = 500
+	maxClientNameLength = 100
+	maxDiscountCode     = 64
+)
+
+//validator is implemented by request bodies. readJSON validates every body implementing it, so handlers only see
+//requests whose fields passed these checks; checks needing storage stay in the handlers
+type validator interface {
+	Validate() error
+}
+
+func (r *LoginRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("email", r.Email),
+		validate.Required("password", r.Password),
+	)
+}
+
+func (r *CreateUserRequest) Validate() error {
+	return validate.Fields(
+		validate.Email("email", r.Email),
+		validate.Password("password", r.Password),
+		validate.Username("username", r.Username),
+	)
+}
+
+func (r *followRequest) Validate() error {
+	return validate.Fields(validate.Length("username", r.Username, 1, validate.MaxUsernameLength))
+}
+
+func (r *CreateListingRequest) Validate() error {
+	if r.Track == nil {
+		return validate.Fields(validate.Price("price", r.Price), validate.That(false, "track", "track is required"))
+	}
+
+	return validate.Fields(
+		validate.Price("price", r.Price),
+		validate.TrackName("track.name", r.Track.Name),
+	)
+}
+
+func (r *CreateDiscountRequest) Validate() error {
+	return validate.Fields(
+		validate.That(len(r.Code) <= maxDiscountCode, "code",
+			fmt.Sprintf("code must be at most %d characters", maxDiscountCode)),
+		validate.That(r.Type == types.PercentageDiscount || r.Type == types.FixedDiscount, "type",
+			fmt.Sprintf("type must be %q or %q", types.PercentageDiscount, types.FixedDiscount)),
+		validate.Required("value", r.Value),
+		validate.That(r.MaxUses >= 0, "max_uses", "max_uses must not be negative"),
+		validate.That(r.PerBuyerLimit >= 0, "per_buyer_limit", "per_buyer_limit must not be negative"),
+	)
+}
+
+func (r *CheckoutRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("listing_id", r.ListingID),
+		validate.That(r.Country == "" || len(r.Country) == 2, "country", "country must be a two-letter country code"),
+	)
+}
+
+func (r *listingRequest) Validate() error {
+	return validate.Fields(validate.Required("listing_id", r.ListingID))
+}
+
+func (r *CreateCommentRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("listing_id", r.ListingID),
+		validate.Length("body", r.Body, 1, maxCommentLength),
+	)
+}
+
+func (r *CreateConversationRequest) Validate() error {
+	checks := []*errs.FieldError{validate.Count("participants", len(r.Participants), 1, maxParticipants-1)}
+	for i, username := range r.Participants {
+		checks = append(checks, validate.Required(fmt.Sprintf("participants[%d]", i), username))
+	}
+
+	return validate.Fields(checks...)
+}
+
+func (r *SendMessageRequest) Validate() error {
+	minLength := 1
+	if len(r.Attachments) > 0 {
+		minLength = 0
+	}
+
+	checks := []*errs.FieldError{
+		validate.Required("conversation_id", r.ConversationID),
+		validate.Length("body", r.Body, minLength, maxMessageLength),
+		validate.Count("attachments", len(r.Attachments), 0, maxAttachments),
+	}
+	for i, attachment := range r.Attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+		if attachment == nil {
+			checks = append(checks, validate.That(false, field, field+" is required"))
+			continue
+		}
+
+		checks = append(checks,
+			validate.That(attachment.Type == types.AttachListing || attachment.Type == types.AttachTrack, field+".type",
+				"Attachments must be a listing or a track"),
+			validate.Required(field+".listing_id", attachment.ListingID),
+		)
+	}
+
+	return validate.Fields(checks...)
+}
+
+func (r *conversationRequest) Validate() error {
+	return validate.Fields(validate.Required("conversation_id", r.ConversationID))
+}
+
+func (r *ReadNotificationsRequest) Validate() error {
+	return validate.Fields(validate.That(r.All || len(r.IDs) > 0, "ids", "Either ids or all must be set"))
+}
+
+func (r *VerifyEmailRequest) Validate() error {
+	return validate.Fields(validate.Required("token", r.Token))
+}
+
+func (r *ChangeEmailRequest) Validate() error {
+	return validate.Fields(validate.Email("email", r.Email))
+}
+
+func (r *ForgotPasswordRequest) Validate() error {
+	return validate.Fields(validate.Required("email", r.Email))
+}
+
+func (r *ResetPasswordRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("token", r.Token),
+		validate.Password("password", r.Password),
+	)
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("current_password", r.CurrentPassword),
+		validate.Password("new_password", r.NewPassword),
+	)
+}
+
+//Validate does not require the challenge, which is only sent when logging in
+func (r *SecondFactorRequest) Validate() error {
+	return validate.Fields(secondFactor(r.Code, r.RecoveryCode))
+}
+
+func (r *DisableTwoFactorRequest) Validate() error {
+	return validate.Fields(
+		validate.Required("password", r.Password),
+		secondFactor(r.Code, r.RecoveryCode),
+	)
+}
+
+func secondFactor(code, recoveryCode string) *errs.FieldError {
+	return validate.That(code != "" || recoveryCode != "", "code", "Either code or recovery_code must be set")
+}
+
+func (r *moderationRequest) Validate() error {
+	return validate.Fields(
+		validate.That(r.Username != "" || r.ListingID != "", "username", "Either username or listing_id must be set"),
+		validate.That(len(r.Reason) <= maxReasonLength, "reason",
+			fmt.Sprintf("reason must be at most %d characters", maxReasonLength)),
+	)
+}
+
+func (r *SetRolesRequest) Validate() error {
+	checks := []*errs.FieldError{validate.Required("username", r.Username)}
+	for i, role := range r.Roles {
+		checks = append(checks, validate.That(auth.ValidRole(role), fmt.Sprintf("roles[%d]", i),
+			fmt.Sprintf("Unknown role %q", role)))
+	}
+
+	return validate.Fields(checks...)
+}
+
+func (r *CreateAPIKeyRequest) Validate() error {
+	checks := []*errs.FieldError{
+		validate.Length("name", r.Name, 1, maxAPIKeyNameLength),
+		validate.Count("scopes", len(r.Scopes), 1, len(types.Scopes)),
+	}
+	for i, scope := range r.Scopes {
+		checks = append(checks, validate.That(types.ValidScope(scope), fmt.Sprintf("scopes[%d]", i),
+			fmt.Sprintf("Unknown scope %q", scope)))
+	}
+
+	return validate.Fields(checks...)
+}
+
+//Validate leaves the redirect URIs to the OAuth provider, which checks them when registering the client
+func (r *RegisterClientRequest) Validate() error {
+	return validate.Fields(validate.Length("name", r.Name, 1, maxClientNameLength))
+}
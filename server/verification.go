@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/email"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/logging"
+	"github.com/danny-m08/music-match/neo4j"
+	"github.com/danny-m08/music-match/types"
+)
+
+const (
+	defaultVerificationTTL = 48 * time.Hour
+	defaultUnverifiedTTL   = 7 * 24 * time.Hour
+)
+
+//verified only passes requests of users whose email address is verified on to next. It has to run after authenticate
+func (server *server) verified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if user := userFromContext(req.Context()); user == nil || !user.Verified {
+			errs.Write(w, errs.New(errs.CodeEmailNotVerified, "Email address not verified"))
+			return
+		}
+
+		next(w, req)
+	}
+}
+
+//newVerification creates a verification token for the user's current email address and renders the email carrying it
+func (server *server) newVerification(user *types.User) (string, time.Time, *types.Email, error) {
+	token, err := auth.NewToken()
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	expires := time.Now().Add(server.authConfig.VerificationTTL)
+	e, err := email.New(email.Verification, user.Username, &email.LinkData{
+		Username: user.Username,
+		Link:     server.emailConfig.BaseURL + "/verify-email?token=" + token,
+		Expires:  expires,
+	})
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	return auth.HashToken(token), expires, e, nil
+}
+
+//sendVerification replaces the user's verification token and queues the email carrying the new one
+func (server *server) sendVerification(ctx context.Context, user *types.User) error {
+	tokenHash, expires, e, err := server.newVerification(user)
+	if err != nil {
+		return err
+	}
+
+	return server.db(ctx).CreateVerification(user, tokenHash, expires, e)
+}
+
+//verifyEmailAddress uses up a verification token, given as the token query parameter of the link in the email or in
+//the body of a POST
+func (server *server) verifyEmailAddress(w http.ResponseWriter, req *http.Request) {
+	request := &VerifyEmailRequest{}
+
+	if req.Method == http.MethodPost {
+		err := readJSON(req, request)
+		if err != nil {
+			errs.Write(w, err)
+			return
+		}
+	} else {
+		request.Token = req.URL.Query().Get("token")
+	}
+
+	user, err := server.db(req.Context()).VerifyEmail(auth.HashToken(request.Token), time.Now())
+	if errors.Is(err, neo4j.ErrNotFound) {
+		errs.Write(w, errs.New(errs.CodeBadRequest, "Invalid or expired verification token"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to verify email address", "error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	logging.FromContext(req.Context()).Info("Email address verified", "username", user.Username)
+	writeJSON(w, http.StatusOK, VerificationResponse{Email: user.Email, Verified: true})
+}
+
+//resendVerification sends the authenticated user a new verification email, invalidating the previous token
+func (server *server) resendVerification(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	err := server.sendVerification(req.Context(), user)
+	if errors.Is(err, neo4j.ErrAlreadyVerified) {
+		errs.Write(w, err)
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to resend verification", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, VerificationResponse{Email: user.Email})
+}
+
+//changeEmail changes the authenticated user's email address and sends a verification email to the new one
+func (server *server) changeEmail(w http.ResponseWriter, req *http.Request) {
+	user := userFromContext(req.Context())
+	request := &ChangeEmailRequest{}
+
+	err := readJSON(req, request)
+	if err != nil {
+		errs.Write(w, err)
+		return
+	}
+
+	updated := &types.User{Username: user.Username, Email: request.Email}
+	tokenHash, expires, e, err := server.newVerification(updated)
+	if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to create verification", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	err = server.db(req.Context()).ChangeEmail(user, request.Email, tokenHash, expires, e)
+	if errors.Is(err, neo4j.ErrAlreadyExists) {
+		errs.Write(w, errs.New(errs.CodeEmailTaken, "Email address already in use"))
+		return
+	} else if err != nil {
+		logging.FromContext(req.Context()).Error("Unable to change email address", "username", user.Username,
+			"error", err)
+		errs.Write(w, errs.ErrInternal)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, VerificationResponse{Email: request.Email})
+}
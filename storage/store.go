@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/danny-m08/music-match/config"
+)
+
+var (
+	//ErrNotFound is returned when no blob is stored at the given path
+	ErrNotFound = errors.New("blob not found")
+	//ErrInvalidPath is returned for paths that would escape the store
+	ErrInvalidPath = errors.New("invalid blob path")
+)
+
+//Blob is an open stored file
+type Blob interface {
+	io.ReadSeekCloser
+	ModTime() time.Time
+}
+
+//Store is where track files live. Keys are slash separated paths relative to the store, assigned by the server when
+//a file is uploaded and never taken from or exposed to API clients
+type Store interface {
+	Create(key string, r io.Reader) error
+	Open(key string) (Blob, error)
+	Ping() error
+}
+
+//FileStore is a Store backed by a directory on the local filesystem
+type FileStore struct {
+	root string
+}
+
+type fileBlob struct {
+	*os.File
+	modTime time.Time
+}
+
+func (b *fileBlob) ModTime() time.Time {
+	return b.modTime
+}
+
+//NewFileStore creates a store rooted at the configured directory. The root has no default, as the working directory
+//holds the config files with the database credentials and keys
+func NewFileStore(conf *config.StorageConfig) (*FileStore, error) {
+	if conf == nil {
+		return nil, errors.New("Storage config cannot be nil")
+	}
+
+	if conf.Root == "" {
+		return nil, errors.New("Storage root must be configured")
+	}
+
+	root, err := filepath.Abs(conf.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{root: root}, nil
+}
+
+//resolve returns the file the blob with the given key is stored in. Keys that are not clean relative paths or resolve
+//outside the root are rejected with ErrInvalidPath
+func (s *FileStore) resolve(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.ContainsRune(key, '\\') {
+		return "", ErrInvalidPath
+	}
+
+	full := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(full, s.root+string(filepath.Separator)) {
+		return "", ErrInvalidPath
+	}
+
+	return full, nil
+}
+
+//Create stores the contents of r under key, replacing the blob stored there. The blob only appears once it was
+//written completely
+func (s *FileStore) Create(key string, r io.Reader) error {
+	full, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(full), 0750)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), full)
+}
+
+//Open opens the blob with the given key for reading
+func (s *FileStore) Open(key string) (Blob, error) {
+	full, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+
+	return &fileBlob{File: file, modTime: info.ModTime()}, nil
+}
+
+//Ping checks that the store's root directory is accessible
+func (s *FileStore) Ping() error {
+	info, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return errors.New(s.root + " is not a directory")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/storage"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileStore(t *testing.T) {
+	convey.Convey("Storing files...", t, func() {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "tracks")
+		convey.So(os.Mkdir(root, 0750), convey.ShouldBeNil)
+		convey.So(os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("password: secret"), 0600), convey.ShouldBeNil)
+
+		store, err := storage.NewFileStore(&config.StorageConfig{Root: root})
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Stores should need an explicit root\n", func() {
+			_, err := storage.NewFileStore(&config.StorageConfig{})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Created blobs should be readable under their key\n", func() {
+			convey.So(store.Create("tracks/abc", strings.NewReader("audio")), convey.ShouldBeNil)
+
+			blob, err := store.Open("tracks/abc")
+			convey.So(err, convey.ShouldBeNil)
+			defer blob.Close()
+			data, _ := io.ReadAll(blob)
+			convey.So(string(data), convey.ShouldEqual, "audio")
+		})
+
+		convey.Convey("Keys resolving outside the root should be rejected\n", func() {
+			for _, key := range []string{"../config.yaml", "tracks/../../config.yaml", "/etc/passwd", "./abc", "", ".."} {
+				_, err := store.Open(key)
+				convey.So(err, convey.ShouldEqual, storage.ErrInvalidPath)
+				convey.So(store.Create(key, strings.NewReader("audio")), convey.ShouldEqual, storage.ErrInvalidPath)
+			}
+		})
+
+		convey.Convey("Missing blobs should not be found\n", func() {
+			_, err := store.Open("tracks/missing")
+			convey.So(err, convey.ShouldEqual, storage.ErrNotFound)
+		})
+	})
+}
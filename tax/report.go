@@ -0,0 +1,123 @@
+package tax
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/types"
+)
+
+//ReportLine totals the sales made in one currency under one tax rule. Sales no tax was charged on are grouped in
+//lines with an empty Country
+type ReportLine struct {
+	Country      string          `json:"country"`
+	Region       string          `json:"region,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Rate         string          `json:"rate,omitempty"`
+	Currency     string          `json:"currency"`
+	Transactions int             `json:"transactions"`
+	Net          currency.Amount `json:"net"`
+	Tax          currency.Amount `json:"tax"`
+	Gross        currency.Amount `json:"gross"`
+}
+
+var csvHeader = []string{"country", "region", "tax", "rate", "currency", "transactions", "net", "tax_amount", "gross"}
+
+//Report totals the tax collected on the given transactions per jurisdiction, rate and currency
+func Report(txs []*types.Transaction) ([]*ReportLine, error) {
+	lines := map[string]*ReportLine{}
+
+	for _, tx := range txs {
+		line := &ReportLine{Currency: tx.FinalPrice.CurrencyCode()}
+		net, amount := tx.FinalPrice, currency.Amount{}
+		if tx.Tax != nil {
+			line.Country, line.Region, line.Name, line.Rate = tx.Tax.Country, tx.Tax.Region, tx.Tax.Name, tx.Tax.Rate
+			net, amount = tx.Tax.Net, tx.Tax.Amount
+		}
+
+		k := line.Country + "/" + line.Region + "/" + line.Name + "/" + line.Rate + "/" + line.Currency
+		if existing, ok := lines[k]; ok {
+			line = existing
+		} else {
+			zero, err := currency.NewAmount("0", line.Currency)
+			if err != nil {
+				return nil, err
+			}
+			line.Net, line.Tax, line.Gross = zero, zero, zero
+			lines[k] = line
+		}
+
+		var err error
+		line.Transactions++
+		line.Net, err = line.Net.Add(net)
+		if err != nil {
+			return nil, err
+		}
+
+		if amount.CurrencyCode() != "" {
+			line.Tax, err = line.Tax.Add(amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		line.Gross, err = line.Gross.Add(tx.FinalPrice)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := make([]*ReportLine, 0, len(lines))
+	for _, line := range lines {
+		report = append(report, line)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Country != b.Country {
+			return a.Country < b.Country
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Rate != b.Rate {
+			return a.Rate < b.Rate
+		}
+		return a.Currency < b.Currency
+	})
+
+	return report, nil
+}
+
+//WriteCSV writes the report lines as CSV with a header row
+func WriteCSV(w io.Writer, report []*ReportLine) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range report {
+		err = writer.Write([]string{
+			line.Country,
+			line.Region,
+			line.Name,
+			line.Rate,
+			line.Currency,
+			strconv.Itoa(line.Transactions),
+			line.Net.Number(),
+			line.Tax.Number(),
+			line.Gross.Number(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,132 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/types"
+)
+
+const hundred = "100"
+
+//Table looks up the tax rule that applies to a buyer's country and region
+type Table struct {
+	rules map[string]*config.TaxRule
+}
+
+//NewTable builds a tax table from the configured rules. Country and region codes are matched case insensitively
+func NewTable(conf *config.TaxConfig) (*Table, error) {
+	if conf == nil {
+		return nil, errors.New("Tax config cannot be nil")
+	}
+
+	table := &Table{rules: make(map[string]*config.TaxRule, len(conf.Rules))}
+	for _, rule := range conf.Rules {
+		if rule.Country == "" {
+			return nil, errors.New("Tax rule must have a country")
+		}
+
+		rate, err := strconv.ParseFloat(rule.Rate, 64)
+		if err != nil || math.IsNaN(rate) || rate < 0 || rate > 100 {
+			return nil, fmt.Errorf("Invalid tax rate %q for %s", rule.Rate, jurisdiction(rule.Country, rule.Region))
+		}
+
+		k := key(rule.Country, rule.Region)
+		if _, ok := table.rules[k]; ok {
+			return nil, fmt.Errorf("Duplicate tax rule for %s", jurisdiction(rule.Country, rule.Region))
+		}
+		table.rules[k] = rule
+	}
+
+	return table, nil
+}
+
+//Empty reports whether the table has no rules, in which case no tax is charged anywhere
+func (t *Table) Empty() bool {
+	return len(t.rules) == 0
+}
+
+//Lookup returns the rule for the region if there is one, otherwise the country wide rule. It returns nil if neither
+//exists
+func (t *Table) Lookup(country, region string) *config.TaxRule {
+	if region != "" {
+		if rule, ok := t.rules[key(country, region)]; ok {
+			return rule
+		}
+	}
+
+	return t.rules[key(country, "")]
+}
+
+//Calculate computes the tax on price under the given rule. For inclusive rules the tax is extracted from the price,
+//otherwise it is added on top of it. The tax amount is rounded to the currency's precision
+func Calculate(rule *config.TaxRule, country, region string, price currency.Amount) (*types.Tax, error) {
+	tax := &types.Tax{
+		Name:      rule.Name,
+		Country:   strings.ToUpper(country),
+		Region:    strings.ToUpper(region),
+		Rate:      rule.Rate,
+		Inclusive: rule.Inclusive,
+	}
+
+	rate, err := currency.NewAmount(rule.Rate, price.CurrencyCode())
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.Inclusive {
+		// tax = price * rate / (100 + rate)
+		base, _ := currency.NewAmount(hundred, price.CurrencyCode())
+		divisor, err := rate.Add(base)
+		if err != nil {
+			return nil, err
+		}
+
+		tax.Amount, err = price.Mul(rule.Rate)
+		if err != nil {
+			return nil, err
+		}
+
+		tax.Amount, err = tax.Amount.Div(divisor.Number())
+		if err != nil {
+			return nil, err
+		}
+
+		tax.Amount = tax.Amount.Round()
+		tax.Gross = price
+		tax.Net, err = price.Sub(tax.Amount)
+		return tax, err
+	}
+
+	tax.Amount, err = price.Mul(rule.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	tax.Amount, err = tax.Amount.Div(hundred)
+	if err != nil {
+		return nil, err
+	}
+
+	tax.Amount = tax.Amount.Round()
+	tax.Net = price
+	tax.Gross, err = price.Add(tax.Amount)
+	return tax, err
+}
+
+func key(country, region string) string {
+	return strings.ToUpper(country) + "/" + strings.ToUpper(region)
+}
+
+func jurisdiction(country, region string) string {
+	if region == "" {
+		return country
+	}
+
+	return country + "-" + region
+}
@@ -0,0 +1,73 @@
+package tax_test
+
+import (
+	"testing"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/tax"
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestTax(t *testing.T) {
+	convey.Convey("Tax calculation...", t, func() {
+		vat := &config.TaxRule{Country: "GB", Name: "VAT", Rate: "20", Inclusive: true}
+		salesTax := &config.TaxRule{Country: "US", Region: "CA", Name: "Sales tax", Rate: "7.25"}
+		table, err := tax.NewTable(&config.TaxConfig{Rules: []*config.TaxRule{vat, salesTax}})
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Rules should be looked up by region first, then country\n", func() {
+			convey.So(table.Lookup("gb", "eng"), convey.ShouldEqual, vat)
+			convey.So(table.Lookup("US", "ca"), convey.ShouldEqual, salesTax)
+			convey.So(table.Lookup("US", "NY"), convey.ShouldBeNil)
+			convey.So(table.Lookup("FR", ""), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Invalid or duplicate rules should be rejected\n", func() {
+			_, err := tax.NewTable(&config.TaxConfig{Rules: []*config.TaxRule{{Country: "GB", Rate: "abc"}}})
+			convey.So(err, convey.ShouldNotBeNil)
+
+			_, err = tax.NewTable(&config.TaxConfig{Rules: []*config.TaxRule{vat, {Country: "gb", Rate: "5"}}})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Inclusive tax should be extracted from the price\n", func() {
+			price, _ := currency.NewAmount("25", "GBP")
+			breakdown, err := tax.Calculate(vat, "GB", "", price)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(breakdown.Amount.String(), convey.ShouldEqual, "4.17 GBP")
+			convey.So(breakdown.Net.String(), convey.ShouldEqual, "20.83 GBP")
+			convey.So(breakdown.Gross.String(), convey.ShouldEqual, "25 GBP")
+		})
+
+		convey.Convey("Exclusive tax should be added to the price\n", func() {
+			price, _ := currency.NewAmount("25", "USD")
+			breakdown, err := tax.Calculate(salesTax, "us", "ca", price)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(breakdown.Country, convey.ShouldEqual, "US")
+			convey.So(breakdown.Amount.String(), convey.ShouldEqual, "1.81 USD")
+			convey.So(breakdown.Gross.String(), convey.ShouldEqual, "26.81 USD")
+		})
+
+		convey.Convey("The report should total transactions per jurisdiction and currency\n", func() {
+			gbp, _ := currency.NewAmount("25", "GBP")
+			usd, _ := currency.NewAmount("10", "USD")
+			b1, _ := tax.Calculate(vat, "GB", "", gbp)
+			b2, _ := tax.Calculate(vat, "GB", "", gbp)
+
+			report, err := tax.Report([]*types.Transaction{
+				{FinalPrice: b1.Gross, Tax: b1},
+				{FinalPrice: b2.Gross, Tax: b2},
+				{FinalPrice: usd},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(report), convey.ShouldEqual, 2)
+			convey.So(report[0].Country, convey.ShouldEqual, "")
+			convey.So(report[0].Gross.String(), convey.ShouldEqual, "10 USD")
+			convey.So(report[1].Transactions, convey.ShouldEqual, 2)
+			convey.So(report[1].Tax.String(), convey.ShouldEqual, "8.34 GBP")
+			convey.So(report[1].Gross.String(), convey.ShouldEqual, "50 GBP")
+		})
+	})
+}
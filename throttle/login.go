@@ -0,0 +1,72 @@
+package throttle
+
+import "time"
+
+//Login throttles the steps of logging in per account, so neither a password nor a second factor can be guessed, and
+//per client, so one client cannot try many accounts. Every step counts as a failure of both until the login succeeds,
+//a correct password of an account with two-factor authentication only completes the first step
+type Login struct {
+	Accounts *Limiter
+	Clients  *Limiter
+}
+
+//LoginAttempt is a login step reserved by Login.Attempt
+type LoginAttempt struct {
+	client  string
+	account string
+	//clientCounted is false when the store could not be reached
+	clientCounted bool
+	//Locks is set when failing the step locks the account out
+	Locks bool
+}
+
+//Attempt reserves a login step for the client and the account, returning how long to wait instead if either of them
+//has to. Throttling fails open: when the store cannot be reached the error is returned along with the attempt, so an
+//unavailable store does not lock everyone out
+func (l *Login) Attempt(client, account string, now time.Time) (*LoginAttempt, time.Duration, error) {
+	attempt := &LoginAttempt{client: client, account: account}
+
+	wait, _, err := l.Clients.Attempt(client, now)
+	attempt.clientCounted = err == nil && wait == 0
+	if wait > 0 {
+		return nil, wait, err
+	}
+
+	wait, locks, accountErr := l.Accounts.Attempt(account, now)
+	if accountErr != nil {
+		err = accountErr
+	}
+	attempt.Locks = locks
+
+	if wait > 0 {
+		if attempt.clientCounted {
+			if releaseErr := l.Clients.Release(client); releaseErr != nil {
+				err = releaseErr
+			}
+		}
+		return nil, wait, err
+	}
+
+	return attempt, 0, err
+}
+
+//Continue takes back the client's attempt of a step that passed but does not complete the login, such as a correct
+//password of an account with two-factor authentication. The account's attempt stays counted until the login succeeds
+func (l *Login) Continue(attempt *LoginAttempt) error {
+	if !attempt.clientCounted {
+		return nil
+	}
+
+	return l.Clients.Release(attempt.client)
+}
+
+//Succeed forgets the failures of the account once the login completed. Only the attempt itself is taken back for the
+//client, its earlier failures are kept, otherwise an attacker could reset them by logging into an account of their own
+func (l *Login) Succeed(attempt *LoginAttempt) error {
+	err := l.Accounts.Reset(attempt.account)
+	if continueErr := l.Continue(attempt); continueErr != nil {
+		err = continueErr
+	}
+
+	return err
+}
@@ -0,0 +1,186 @@
+package throttle
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrUnknownStore is returned for a store setting other than "memory" or "shared"
+var ErrUnknownStore = errors.New("unknown throttle store")
+
+const (
+	StoreMemory = "memory"
+	StoreShared = "shared"
+)
+
+//Record is the failed attempts made under a key since the failures started
+type Record struct {
+	Failures int64
+	Last     time.Time
+}
+
+//Store keeps the failed attempts per key. The in-memory store only sees the attempts made against one instance, a
+//store shared by all instances has to be used when running several
+type Store interface {
+	//ReserveAttempt counts an attempt at now as a failure unless the key has to wait, which it does for delays[n] after
+	//its last failure when it has n failures, the last delay applying to any higher count. Failures older than window
+	//are forgotten first. Checking and counting have to happen at once. It returns the record and whether the attempt
+	//was counted, keys that have to wait are left as they are
+	ReserveAttempt(key string, now time.Time, window time.Duration, delays []time.Duration) (*Record, bool, error)
+	//ReleaseAttempt takes back a failure counted by ReserveAttempt
+	ReleaseAttempt(key string) error
+	//ClearAttempts forgets the failures of the key
+	ClearAttempts(key string) error
+}
+
+//Policy decides how long attempts are held off after failing. The first Free failures cost nothing, after that the
+//delay starts at Base and doubles with every failure up to MaxDelay. Reaching LockAfter failures locks the key out
+//for Lockout. Failures are forgotten once none happened for Window
+type Policy struct {
+	Free      int64
+	Base      time.Duration
+	MaxDelay  time.Duration
+	LockAfter int64
+	Lockout   time.Duration
+	Window    time.Duration
+}
+
+//Limiter throttles attempts per key following its policy
+type Limiter struct {
+	store  Store
+	policy Policy
+	//delays is Delay for every number of failures up to the one from which it stops changing
+	delays []time.Duration
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	if policy.Window < policy.Lockout {
+		policy.Window = policy.Lockout
+	}
+
+	l := &Limiter{store: store, policy: policy}
+	l.delays = []time.Duration{l.Delay(0)}
+	for failures := int64(1); ; failures++ {
+		delay := l.Delay(failures)
+		l.delays = append(l.delays, delay)
+		if failures > policy.Free && failures > policy.LockAfter && delay == l.delays[failures-1] {
+			break
+		}
+	}
+
+	return l
+}
+
+//Attempt reserves an attempt for the key, which counts as a failure unless it is released or the key reset once the
+//attempt succeeded. If the key has to wait nothing is counted and the wait is returned instead. Otherwise locks
+//reports whether failing the attempt locks the key out. As the store checks and counts at once, concurrent attempts
+//cannot all pass before any of them is counted
+func (l *Limiter) Attempt(key string, now time.Time) (wait time.Duration, locks bool, err error) {
+	record, counted, err := l.store.ReserveAttempt(key, now, l.policy.Window, l.delays)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !counted {
+		return record.Last.Add(l.Delay(record.Failures)).Sub(now), false, nil
+	}
+
+	return 0, l.policy.LockAfter > 0 && record.Failures == l.policy.LockAfter, nil
+}
+
+//Release takes back the failure counted for an attempt that succeeded, keeping the earlier ones
+func (l *Limiter) Release(key string) error {
+	return l.store.ReleaseAttempt(key)
+}
+
+//Reset forgets the failures of the key after a successful attempt
+func (l *Limiter) Reset(key string) error {
+	return l.store.ClearAttempts(key)
+}
+
+//Delay is how long to hold off attempts after the given number of failures
+func (l *Limiter) Delay(failures int64) time.Duration {
+	if l.policy.LockAfter > 0 && failures >= l.policy.LockAfter {
+		return l.policy.Lockout
+	}
+
+	if failures < l.policy.Free || l.policy.Base == 0 {
+		return 0
+	}
+
+	delay := l.policy.Base
+	for i := l.policy.Free; i < failures && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if l.policy.MaxDelay > 0 && delay > l.policy.MaxDelay {
+		return l.policy.MaxDelay
+	}
+
+	return delay
+}
+
+//MemoryStore keeps attempts in memory. It only throttles the attempts made against this instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+	pruned  time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+func (s *MemoryStore) ReserveAttempt(key string, now time.Time, window time.Duration, delays []time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget stale keys every now and then so the map does not grow with every address ever seen
+	if now.Sub(s.pruned) > window {
+		for k, record := range s.records {
+			if now.Sub(record.Last) > window {
+				delete(s.records, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	record, ok := s.records[key]
+	if !ok || now.Sub(record.Last) > window {
+		record = &Record{}
+		s.records[key] = record
+	}
+
+	delay := delays[len(delays)-1]
+	if record.Failures < int64(len(delays)) {
+		delay = delays[record.Failures]
+	}
+
+	counted := record.Failures == 0 || !now.Before(record.Last.Add(delay))
+	if counted {
+		record.Failures++
+		record.Last = now
+	}
+
+	copied := *record
+	return &copied, counted, nil
+}
+
+func (s *MemoryStore) ReleaseAttempt(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Failures > 0 {
+		record.Failures--
+	}
+	return nil
+}
+
+func (s *MemoryStore) ClearAttempts(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
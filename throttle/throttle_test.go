@@ -0,0 +1,190 @@
+package throttle_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danny-m08/music-match/throttle"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimiter(t *testing.T) {
+	convey.Convey("Throttling attempts...", t, func() {
+		limiter := throttle.NewLimiter(throttle.NewMemoryStore(), throttle.Policy{
+			Free:      3,
+			Base:      time.Second,
+			MaxDelay:  time.Minute,
+			LockAfter: 10,
+			Lockout:   15 * time.Minute,
+			Window:    time.Hour,
+		})
+		now := time.Now()
+
+		//fail makes n failed attempts, each one as soon as the previous failures allow it, and leaves now at the last one
+		failures := int64(0)
+		fail := func(n int) bool {
+			locked := false
+			for i := 0; i < n; i++ {
+				now = now.Add(limiter.Delay(failures))
+				wait, locks, err := limiter.Attempt("key", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(wait, convey.ShouldEqual, 0)
+				locked = locks
+				failures++
+			}
+			return locked
+		}
+
+		convey.Convey("The first failures should be free\n", func() {
+			for i := 0; i < 3; i++ {
+				wait, _, err := limiter.Attempt("key", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(wait, convey.ShouldEqual, 0)
+			}
+		})
+
+		convey.Convey("Further failures should back off exponentially up to the cap\n", func() {
+			convey.So(limiter.Delay(3), convey.ShouldEqual, time.Second)
+			convey.So(limiter.Delay(4), convey.ShouldEqual, 2*time.Second)
+			convey.So(limiter.Delay(5), convey.ShouldEqual, 4*time.Second)
+			convey.So(limiter.Delay(9), convey.ShouldEqual, time.Minute)
+
+			fail(4)
+			wait, _, _ := limiter.Attempt("key", now)
+			convey.So(wait, convey.ShouldEqual, 2*time.Second)
+			wait, _, _ = limiter.Attempt("key", now.Add(2*time.Second))
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Attempts that have to wait should not be counted\n", func() {
+			fail(4)
+			for i := 0; i < 5; i++ {
+				wait, _, _ := limiter.Attempt("key", now.Add(time.Second))
+				convey.So(wait, convey.ShouldEqual, time.Second)
+			}
+		})
+
+		convey.Convey("Concurrent attempts should not get past the throttle together\n", func() {
+			fail(3)
+			allowed := make(chan bool, 20)
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					wait, _, _ := limiter.Attempt("key", now.Add(time.Second))
+					allowed <- wait == 0
+				}()
+			}
+			wg.Wait()
+			close(allowed)
+
+			count := 0
+			for ok := range allowed {
+				if ok {
+					count++
+				}
+			}
+			convey.So(count, convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Reaching the limit should lock the key out once\n", func() {
+			convey.So(fail(9), convey.ShouldBeFalse)
+			convey.So(fail(1), convey.ShouldBeTrue)
+
+			wait, _, _ := limiter.Attempt("key", now.Add(time.Minute))
+			convey.So(wait, convey.ShouldEqual, 14*time.Minute)
+			wait, _, _ = limiter.Attempt("other", now)
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Released attempts should not count as failures\n", func() {
+			for i := 0; i < 10; i++ {
+				wait, _, err := limiter.Attempt("key", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(wait, convey.ShouldEqual, 0)
+				convey.So(limiter.Release("key"), convey.ShouldBeNil)
+			}
+		})
+
+		convey.Convey("Failures should be forgotten once the window passed\n", func() {
+			fail(10)
+			wait, _, _ := limiter.Attempt("key", now.Add(2*time.Hour))
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Failures should be forgotten after a success\n", func() {
+			fail(10)
+			convey.So(limiter.Reset("key"), convey.ShouldBeNil)
+			wait, _, _ := limiter.Attempt("key", now)
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+	})
+}
+
+func TestLogin(t *testing.T) {
+	convey.Convey("Throttling logins...", t, func() {
+		store := throttle.NewMemoryStore()
+		login := &throttle.Login{
+			Accounts: throttle.NewLimiter(store, throttle.Policy{
+				Free:      3,
+				Base:      time.Second,
+				MaxDelay:  time.Minute,
+				LockAfter: 10,
+				Lockout:   15 * time.Minute,
+				Window:    time.Hour,
+			}),
+			Clients: throttle.NewLimiter(store, throttle.Policy{Free: 20, Base: time.Second, MaxDelay: time.Minute,
+				LockAfter: 100, Lockout: 15 * time.Minute, Window: time.Hour}),
+		}
+		now := time.Now()
+
+		//attempt makes a login step as soon as the throttle allows it
+		attempt := func() *throttle.LoginAttempt {
+			a, wait, err := login.Attempt("ip:192.0.2.1", "account:user@example.com", now)
+			convey.So(err, convey.ShouldBeNil)
+			if wait > 0 {
+				now = now.Add(wait)
+				a, wait, err = login.Attempt("ip:192.0.2.1", "account:user@example.com", now)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(wait, convey.ShouldEqual, 0)
+			}
+			return a
+		}
+
+		convey.Convey("Correct passwords followed by wrong codes should lock the account out\n", func() {
+			locked := false
+			for cycle := 0; cycle < 3 && !locked; cycle++ {
+				convey.So(login.Continue(attempt()), convey.ShouldBeNil)
+				for code := 0; code < 5 && !locked; code++ {
+					locked = attempt().Locks
+				}
+			}
+			convey.So(locked, convey.ShouldBeTrue)
+
+			_, wait, _ := login.Attempt("ip:192.0.2.1", "account:user@example.com", now.Add(time.Minute))
+			convey.So(wait, convey.ShouldEqual, 14*time.Minute)
+		})
+
+		convey.Convey("Completed logins should forget the account's failures\n", func() {
+			for i := 0; i < 9; i++ {
+				attempt()
+			}
+			convey.So(login.Succeed(attempt()), convey.ShouldBeNil)
+
+			_, wait, _ := login.Attempt("ip:192.0.2.1", "account:user@example.com", now)
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Failures should not hold off other accounts and clients\n", func() {
+			for i := 0; i < 3; i++ {
+				attempt()
+			}
+			_, wait, _ := login.Attempt("ip:192.0.2.1", "account:user@example.com", now)
+			convey.So(wait, convey.ShouldEqual, time.Second)
+			_, wait, _ = login.Attempt("ip:192.0.2.2", "account:other@example.com", now)
+			convey.So(wait, convey.ShouldEqual, 0)
+		})
+	})
+}
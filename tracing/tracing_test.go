@@ -0,0 +1,105 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danny-m08/music-match/config"
+	"github.com/danny-m08/music-match/router"
+	"github.com/danny-m08/music-match/tracing"
+	"github.com/smartystreets/goconvey/convey"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestPropagation(t *testing.T) {
+	convey.Convey("Receiving trace context...", t, func() {
+		_, err := tracing.Setup(&config.TracingConfig{})
+		convey.So(err, convey.ShouldBeNil)
+
+		var traceID string
+		r := router.New()
+		r.Use(router.Trace)
+		r.Get("/ok", func(w http.ResponseWriter, req *http.Request) {
+			traceID = tracing.TraceID(req.Context())
+		})
+
+		convey.Convey("Requests with a traceparent header should continue the caller's trace\n", func() {
+			req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+			req.Header.Set("traceparent", traceparent)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			convey.So(traceID, convey.ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+		})
+
+		convey.Convey("Unknown exporters should be rejected\n", func() {
+			_, err := tracing.Setup(&config.TracingConfig{Exporter: "jaeger"})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestOTLPExporter(t *testing.T) {
+	convey.Convey("Exporting spans over OTLP...", t, func() {
+		var body coltracepb.ExportTraceServiceRequest
+		var contentType, apiKey string
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			contentType, apiKey = req.Header.Get("Content-Type"), req.Header.Get("X-API-Key")
+			data, _ := io.ReadAll(req.Body)
+			_ = proto.Unmarshal(data, &body)
+			if req.URL.Path != "/v1/traces" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer collector.Close()
+
+		exporter, err := tracing.NewOTLPExporter(collector.URL, map[string]string{"X-API-Key": "secret"})
+		convey.So(err, convey.ShouldBeNil)
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		tracer := provider.Tracer("test")
+
+		convey.Convey("Spans should be posted to the collector with the configured headers\n", func() {
+			ctx, parent := tracer.Start(context.Background(), "GET /listings/{id}",
+				trace.WithSpanKind(trace.SpanKindServer))
+			_, child := tracer.Start(ctx, "neo4j GetListing")
+			child.End()
+			parent.End()
+
+			convey.So(contentType, convey.ShouldEqual, "application/x-protobuf")
+			convey.So(apiKey, convey.ShouldEqual, "secret")
+
+			span := body.ResourceSpans[0].ScopeSpans[0].Spans[0]
+			traceID := parent.SpanContext().TraceID()
+			convey.So(span.Name, convey.ShouldEqual, "GET /listings/{id}")
+			convey.So(span.Kind, convey.ShouldEqual, tracepb.Span_SPAN_KIND_SERVER)
+			convey.So(span.TraceId, convey.ShouldResemble, traceID[:])
+			convey.So(span.ParentSpanId, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Failed spans should carry the error status and their parent's ID\n", func() {
+			ctx, parent := tracer.Start(context.Background(), "housekeeping")
+			_, child := tracer.Start(ctx, "neo4j PruneAttempts")
+			tracing.End(child, errors.New("unreachable"))
+
+			span := body.ResourceSpans[0].ScopeSpans[0].Spans[0]
+			spanID := parent.SpanContext().SpanID()
+			convey.So(span.ParentSpanId, convey.ShouldResemble, spanID[:])
+			convey.So(span.Status.Code, convey.ShouldEqual, tracepb.Status_STATUS_CODE_ERROR)
+			convey.So(span.Status.Message, convey.ShouldEqual, "unreachable")
+			parent.End()
+		})
+
+		convey.Convey("Endpoints that are not HTTP URLs should be rejected\n", func() {
+			_, err := tracing.NewOTLPExporter("collector:4318", nil)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
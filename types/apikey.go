@@ -0,0 +1,48 @@
+package types
+
+import "time"
+
+//Scope is what an API key lets its holder do on the owner's behalf
+type Scope string
+
+const (
+	ScopeReadListings   Scope = "listings:read"
+	ScopeManageListings Scope = "listings:write"
+	ScopeReadSales      Scope = "sales:read"
+)
+
+//Scopes lists every scope a key can be granted
+var Scopes = []Scope{ScopeReadListings, ScopeManageListings, ScopeReadSales}
+
+//APIKey is a named key a user created for scripts and integrations. The key itself is only shown when it is created,
+//Prefix is its first few characters so owners can tell their keys apart
+type APIKey struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Prefix   string     `json:"prefix"`
+	Scopes   []Scope    `json:"scopes"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+//Allows reports whether the key was granted the scope
+func (k *APIKey) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ValidScope reports whether scope is one of the known scopes
+func ValidScope(scope Scope) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditListUsers        AuditAction = "users.list"
+	AuditSuspendUser      AuditAction = "users.suspend"
+	AuditReinstateUser    AuditAction = "users.reinstate"
+	AuditSetRoles         AuditAction = "users.roles"
+	AuditRemoveListing    AuditAction = "listings.remove"
+	AuditDeleteComment    AuditAction = "comments.delete"
+	AuditViewTransactions AuditAction = "transactions.view"
+	AuditViewLog          AuditAction = "audit.view"
+)
+
+//AuditEntry records an action taken with staff permissions. Target names what the action was taken on, such as
+//"user:alice" or "listing:abc123", and Details holds the parameters of reads such as a search query
+type AuditEntry struct {
+	ID      string      `json:"id"`
+	Actor   string      `json:"actor"`
+	Action  AuditAction `json:"action"`
+	Target  string      `json:"target,omitempty"`
+	Reason  string      `json:"reason,omitempty"`
+	Details string      `json:"details,omitempty"`
+	Created time.Time   `json:"created"`
+}
+
+//Account is a user as shown to staff, including the details that are hidden from other users
+type Account struct {
+	Username   string      `json:"username"`
+	Email      string      `json:"email"`
+	Roles      []Role      `json:"roles"`
+	Verified   bool        `json:"verified"`
+	TwoFactor  bool        `json:"two_factor"`
+	Created    *time.Time  `json:"created,omitempty"`
+	Suspension *Suspension `json:"suspension,omitempty"`
+}
+
+//Suspension is why and by whom an account was suspended
+type Suspension struct {
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import "time"
+
+type DiscountType string
+
+const (
+	//PercentageDiscount takes Value percent off the listing price
+	PercentageDiscount DiscountType = "percentage"
+	//FixedDiscount takes Value off the listing price in the listing's currency
+	FixedDiscount DiscountType = "fixed"
+)
+
+//Discount is a promotion a seller runs on one of their listings, or on all of them when ListingID is empty.
+//A discount with a Code has to be entered by the buyer at checkout, one without is a scheduled sale that is
+//applied automatically between Starts and Expires
+type Discount struct {
+	ID            string       `json:"id"`
+	Code          string       `json:"code,omitempty"`
+	ListingID     string       `json:"listing_id,omitempty"`
+	Type          DiscountType `json:"type"`
+	Value         string       `json:"value"`
+	MaxUses       int64        `json:"max_uses,omitempty"`
+	PerBuyerLimit int64        `json:"per_buyer_limit,omitempty"`
+	Uses          int64        `json:"uses"`
+	Starts        *time.Time   `json:"starts,omitempty"`
+	Expires       *time.Time   `json:"expires,omitempty"`
+}
+
+//IsSale reports whether the discount is a scheduled sale rather than a discount code
+func (d *Discount) IsSale() bool {
+	return d.Code == ""
+}
@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+type EmailCategory string
+
+const (
+	//EmailAccount emails concern the security of an account and are always sent
+	EmailAccount  EmailCategory = "account"
+	EmailReceipts EmailCategory = "receipts"
+	EmailSales    EmailCategory = "sales"
+)
+
+//Email is a rendered message waiting in the outbox to be delivered to a user. Address is filled in from the
+//recipient's account when the email is queued
+type Email struct {
+	ID       string
+	To       string
+	Address  string
+	Category EmailCategory
+	Subject  string
+	Text     string
+	HTML     string
+	Attempts int64
+	Created  time.Time
+}
+
+//EmailPreferences lists which optional categories of email a user wants to receive
+type EmailPreferences struct {
+	Receipts bool `json:"receipts"`
+	Sales    bool `json:"sales"`
+}
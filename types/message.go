@@ -0,0 +1,46 @@
+package types
+
+import "time"
+
+type AttachmentType string
+
+const (
+	AttachListing AttachmentType = "listing"
+	AttachTrack   AttachmentType = "track"
+)
+
+//Conversation is a 1:1 or small group message thread. Unread counts the messages sent since the requesting
+//participant last read the conversation
+type Conversation struct {
+	ID           string    `json:"id"`
+	Participants []*User   `json:"participants"`
+	Group        bool      `json:"group"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+	Unread       int64     `json:"unread"`
+}
+
+//Message is a message sent in a conversation. ReadBy lists the participants who have read it
+type Message struct {
+	ID             string        `json:"id"`
+	ConversationID string        `json:"conversation_id"`
+	Sender         *User         `json:"sender"`
+	Body           string        `json:"body"`
+	Attachments    []*Attachment `json:"attachments,omitempty"`
+	Created        time.Time     `json:"created"`
+	ReadBy         []string      `json:"read_by"`
+}
+
+//Attachment references a listing, or the track sold by it, from a message
+type Attachment struct {
+	Type      AttachmentType `json:"type"`
+	ListingID string         `json:"listing_id"`
+	Track     *Track         `json:"track,omitempty"`
+}
+
+//ReadReceipt records that a participant has read a conversation up to the given time
+type ReadReceipt struct {
+	ConversationID string    `json:"conversation_id"`
+	User           *User     `json:"user"`
+	Read           time.Time `json:"read"`
+}
@@ -0,0 +1,25 @@
+package types
+
+import "time"
+
+type NotificationType string
+
+const (
+	NotifyFollow  NotificationType = "follow"
+	NotifySale    NotificationType = "sale"
+	NotifyComment NotificationType = "comment"
+	NotifyReply   NotificationType = "reply"
+)
+
+//Notification tells a user about something another user did that concerns them. Which of ListingID, CommentID and
+//TransactionID are set depends on the type
+type Notification struct {
+	ID            string           `json:"id"`
+	Type          NotificationType `json:"type"`
+	Actor         *User            `json:"actor"`
+	ListingID     string           `json:"listing_id,omitempty"`
+	CommentID     string           `json:"comment_id,omitempty"`
+	TransactionID string           `json:"transaction_id,omitempty"`
+	Read          bool             `json:"read"`
+	Created       time.Time        `json:"created"`
+}
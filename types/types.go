@@ -1,9 +1,9 @@
 package types
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"github.com/bojanz/currency"
-	"math/rand"
 	"strings"
 	"time"
 )
@@ -13,30 +13,99 @@ const (
 	alphaNumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
 )
 
+//Listing is a track put up for sale. Tx is only filled in for the buyer, the seller and staff allowed to view
+//transactions, anyone else only learns whether the listing was Sold
 type Listing struct {
-	Price   currency.Amount `json:"price"`
-	ID      string          `json:"id"`
-	Track   *Track          `json:"track"`
-	Created *time.Time      `json:"created"`
-	Tx      *Transaction    `json:"transaction,omitempty"`
+	Price    currency.Amount `json:"price"`
+	ID       string          `json:"id"`
+	Track    *Track          `json:"track"`
+	Created  *time.Time      `json:"created"`
+	Seller   *User           `json:"seller,omitempty"`
+	Sold     bool            `json:"sold"`
+	Tx       *Transaction    `json:"transaction,omitempty"`
+	Likes    int64           `json:"likes"`
+	Reposts  int64           `json:"reposts"`
+	Comments int64           `json:"comments"`
 }
 
+//Comment is a comment on a listing. Replies point at the comment they answer through ParentID. Deleted comments keep
+//their place in the thread but lose their body
+type Comment struct {
+	ID        string    `json:"id"`
+	ListingID string    `json:"listing_id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Author    *User     `json:"author,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+//Track is the audio file a listing sells. Path is where the file lives in storage and is never serialised, buyers
+//download the file through a signed link instead
 type Track struct {
 	Name string `json:"name"`
-	Path string `json:"path"`
+	Path string `json:"-"`
 }
 
+//Transaction records the purchase of a listing. OriginalPrice is the listing price at checkout, Discount the amount
+//taken off by a discount code or sale and FinalPrice what the buyer was charged
 type Transaction struct {
-	ID    string //`json:"id"`
-	Buyer *User
-	Date  time.Time //`json:"timestamp"`
+	ID            string          `json:"id"`
+	Buyer         *User           `json:"buyer,omitempty"`
+	Date          time.Time       `json:"timestamp"`
+	OriginalPrice currency.Amount `json:"original_price"`
+	Discount      currency.Amount `json:"discount"`
+	FinalPrice    currency.Amount `json:"final_price"`
+	DiscountID    string          `json:"discount_id,omitempty"`
+	Tax           *Tax            `json:"tax,omitempty"`
+	Downloads     int64           `json:"downloads"`
+}
+
+//Tax is the tax breakdown of a transaction. Net is the price excluding tax and Gross what the buyer paid
+type Tax struct {
+	Name      string          `json:"name"`
+	Country   string          `json:"country"`
+	Region    string          `json:"region,omitempty"`
+	Rate      string          `json:"rate"`
+	Inclusive bool            `json:"inclusive"`
+	Net       currency.Amount `json:"net"`
+	Amount    currency.Amount `json:"amount"`
+	Gross     currency.Amount `json:"gross"`
+}
+
+type FeedItemType string
+
+const (
+	FeedListing   FeedItemType = "listing"
+	FeedMilestone FeedItemType = "milestone"
+	FeedRepost    FeedItemType = "repost"
+)
+
+//FeedItem is an event shown in the feed of the actor's followers. Listing is set for new listings and reposts and
+//Milestone for the number of sales a seller reached
+type FeedItem struct {
+	ID        string       `json:"id"`
+	Type      FeedItemType `json:"type"`
+	Actor     *User        `json:"actor"`
+	Listing   *Listing     `json:"listing,omitempty"`
+	Milestone int64        `json:"milestone,omitempty"`
+	Time      time.Time    `json:"time"`
 }
 
+//GenerateID returns a random alphanumeric ID. Bytes that would favour the first characters are skipped, so every
+//character is equally likely. IDs that guard anything, such as grants or storage keys, should use auth.NewToken
 func GenerateID() string {
 	str := strings.Builder{}
-	rand.Seed(time.Now().UnixNano())
-	for it := 0; it < idLength; it++ {
-		str.WriteByte(alphaNumeric[rand.Intn(len(alphaNumeric))])
+	buf := make([]byte, idLength)
+	for str.Len() < idLength {
+		if _, err := rand.Read(buf); err != nil {
+			panic("reading random bytes: " + err.Error())
+		}
+		for _, b := range buf {
+			if int(b) < 256-256%len(alphaNumeric) && str.Len() < idLength {
+				str.WriteByte(alphaNumeric[int(b)%len(alphaNumeric)])
+			}
+		}
 	}
 	return str.String()
 }
@@ -0,0 +1,40 @@
+package types_test
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateID(t *testing.T) {
+	convey.Convey("Generating IDs...", t, func() {
+		convey.Convey("IDs should be alphanumeric and of a fixed length\n", func() {
+			id := types.GenerateID()
+			convey.So(id, convey.ShouldHaveLength, 10)
+			convey.So(regexp.MustCompile(`^[a-zA-Z0-9]+$`).MatchString(id), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("IDs generated at the same time should differ\n", func() {
+			ids := make(chan string, 100)
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ids <- types.GenerateID()
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := map[string]bool{}
+			for id := range ids {
+				seen[id] = true
+			}
+			convey.So(seen, convey.ShouldHaveLength, 100)
+		})
+	})
+}
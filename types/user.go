@@ -1,11 +1,21 @@
 package types
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
+//User is an account. Its JSON encoding is the PublicUser, as users are embedded in responses about listings, follows,
+//comments and messages anyone can read. Responses meant for the account holder or staff use their own types
 type User struct {
 	Username  string     `json:"username"`
-	Password  string     `json:"password"`
-	Email     string     `json:"email"`
+	Password  string     `json:"-"`
+	Email     string     `json:"-"`
+	Verified  bool       `json:"-"`
+	TwoFactor bool       `json:"-"`
+	Roles     []Role     `json:"-"`
+	Suspended bool       `json:"-"`
 	Following []*User    `json:"following,omitempty"`
 	Followers []*User    `json:"followers,omitempty"`
 	Listings  []*Listing `json:"listings,omitempty"`
@@ -22,6 +32,66 @@ func NewUser() *User {
 	}
 }
 
+//PublicUser is what anyone may learn about a user
+type PublicUser struct {
+	Username string `json:"username"`
+}
+
+//Public returns the public view of the user
+func (U *User) Public() *PublicUser {
+	return &PublicUser{Username: U.Username}
+}
+
+//MarshalJSON encodes the public view of the user, never their email address or password
+func (U User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(U.Public())
+}
+
+//String identifies the user by username only. Users end up in logs and error messages, which must carry neither their
+//password nor their email address
 func (U *User) String() string {
-	return fmt.Sprintf("{username: '%s', email: '%s', password: '%s'}", U.Username, U.Email, U.Password)
+	return fmt.Sprintf("{username: '%s'}", U.Username)
+}
+
+//Role grants a user the permissions of its holders. Every user holds RoleUser, sellers are given RoleSeller when they
+//create their first listing and the staff roles are granted by administrators
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleSeller    Role = "seller"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+//HasRole reports whether the user holds the role
+func (U *User) HasRole(role Role) bool {
+	if role == RoleUser {
+		return true
+	}
+
+	for _, r := range U.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Follow is an entry in a user's followers or following list. Mutual is set when the two users follow each other
+type Follow struct {
+	User   *User      `json:"user"`
+	Since  *time.Time `json:"since,omitempty"`
+	Mutual bool       `json:"mutual"`
+}
+
+//TwoFactor is a user's TOTP enrollment. Secrets are stored encrypted and recovery codes hashed. Pending holds the
+//secret of an enrollment that has not been confirmed with a code yet
+type TwoFactor struct {
+	Enabled       bool
+	Secret        string
+	Pending       string
+	LastStep      int64
+	RecoveryCodes int
 }
@@ -0,0 +1,29 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/danny-m08/music-match/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestUserJSON(t *testing.T) {
+	convey.Convey("Encoding users...", t, func() {
+		user := &types.User{Username: "alice", Password: "hunter2", Email: "alice@example.com"}
+
+		convey.Convey("Users embedded in responses should only show their public fields\n", func() {
+			data, err := json.Marshal(&types.Listing{ID: "l1", Seller: user, Tx: &types.Transaction{Buyer: user}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldContainSubstring, `"seller":{"username":"alice"}`)
+			convey.So(string(data), convey.ShouldNotContainSubstring, "alice@example.com")
+			convey.So(string(data), convey.ShouldNotContainSubstring, "password")
+		})
+
+		convey.Convey("Users should be encoded the same whether or not they are pointers\n", func() {
+			data, err := json.Marshal([]types.User{*user})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, `[{"username":"alice"}]`)
+		})
+	})
+}
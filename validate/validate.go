@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/auth"
+	"github.com/danny-m08/music-match/errs"
+)
+
+const (
+	MinUsernameLength  = 3
+	MaxUsernameLength  = 30
+	MaxEmailLength     = 254
+	MaxTrackNameLength = 200
+)
+
+//MaxPrice is the highest price a listing can have, in units of its currency
+const MaxPrice = "100000"
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	emailPattern    = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+)
+
+//Fields returns nil if none of the checks failed, otherwise a validation error listing every field that failed.
+//Checks are written as calls of the rules below, which return nil for valid values
+func Fields(checks ...*errs.FieldError) error {
+	var failed []errs.FieldError
+	for _, check := range checks {
+		if check != nil {
+			failed = append(failed, *check)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	} else if len(failed) == 1 {
+		return errs.Invalid(failed[0].Field, failed[0].Message)
+	}
+
+	return errs.Validation(failed...)
+}
+
+//That fails with the message unless ok holds, for checks not covered by a rule
+func That(ok bool, field, message string) *errs.FieldError {
+	if ok {
+		return nil
+	}
+
+	return &errs.FieldError{Field: field, Message: message}
+}
+
+//Required fails for empty and blank values
+func Required(field, value string) *errs.FieldError {
+	return That(strings.TrimSpace(value) != "", field, field+" is required")
+}
+
+//Length fails unless the value, without surrounding whitespace, has between min and max characters
+func Length(field, value string, min, max int) *errs.FieldError {
+	n := utf8.RuneCountInString(strings.TrimSpace(value))
+	return That(n >= min && n <= max, field, fmt.Sprintf("%s must be between %d and %d characters", field, min, max))
+}
+
+//Count fails unless a list has between min and max items
+func Count(field string, n, min, max int) *errs.FieldError {
+	return That(n >= min && n <= max, field, fmt.Sprintf("%s must have between %d and %d items", field, min, max))
+}
+
+//Username fails for usernames that are not made of letters, digits, dots, dashes and underscores or are too short or
+//long. Only new usernames are checked against it, existing ones are only required
+func Username(field, value string) *errs.FieldError {
+	n := utf8.RuneCountInString(value)
+	return That(n >= MinUsernameLength && n <= MaxUsernameLength && usernamePattern.MatchString(value), field,
+		fmt.Sprintf("%s must be %d to %d letters, digits, dots, dashes or underscores", field, MinUsernameLength,
+			MaxUsernameLength))
+}
+
+//Email fails for values that are not an email address
+func Email(field, value string) *errs.FieldError {
+	return That(len(value) <= MaxEmailLength && emailPattern.MatchString(value), field, "Invalid email address")
+}
+
+//Password fails for new passwords auth.ValidatePassword rejects
+func Password(field, value string) *errs.FieldError {
+	err := auth.ValidatePassword(value)
+	if err != nil {
+		return &errs.FieldError{Field: field, Message: err.Error()}
+	}
+
+	return nil
+}
+
+//Price fails unless the amount is in a known currency, is positive, is at most MaxPrice and has no more decimals than
+//the currency has minor units
+func Price(field string, price currency.Amount) *errs.FieldError {
+	code := price.CurrencyCode()
+	if code == "" {
+		return &errs.FieldError{Field: field, Message: field + " is required"}
+	} else if !currency.IsValid(code) {
+		return &errs.FieldError{Field: field, Message: fmt.Sprintf("Unknown currency code %q", code)}
+	} else if !price.IsPositive() {
+		return &errs.FieldError{Field: field, Message: "Price must be positive"}
+	}
+
+	max, _ := currency.NewAmount(MaxPrice, code)
+	if cmp, err := price.Cmp(max); err != nil || cmp > 0 {
+		return &errs.FieldError{Field: field, Message: fmt.Sprintf("Price must be at most %s %s", MaxPrice, code)}
+	}
+
+	return That(price.Round().Equal(price), field, "Price has more decimals than "+code+" allows")
+}
+
+//TrackName fails for track names that are blank, too long or contain control characters
+func TrackName(field, value string) *errs.FieldError {
+	if check := Length(field, value, 1, MaxTrackNameLength); check != nil {
+		return check
+	}
+
+	return That(strings.IndexFunc(value, unicode.IsControl) < 0, field, field+" must not contain control characters")
+}
@@ -0,0 +1,71 @@
+package validate_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bojanz/currency"
+	"github.com/danny-m08/music-match/errs"
+	"github.com/danny-m08/music-match/validate"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func price(n, code string) currency.Amount {
+	amount, err := currency.NewAmount(n, code)
+	convey.So(err, convey.ShouldBeNil)
+	return amount
+}
+
+func TestRules(t *testing.T) {
+	convey.Convey("Validating fields...", t, func() {
+		convey.Convey("Usernames should be limited in length and characters\n", func() {
+			convey.So(validate.Username("username", "dj_shadow.94"), convey.ShouldBeNil)
+			convey.So(validate.Username("username", "dj"), convey.ShouldNotBeNil)
+			convey.So(validate.Username("username", "dj shadow"), convey.ShouldNotBeNil)
+			convey.So(validate.Username("username", strings.Repeat("a", 31)), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Weak passwords should be rejected\n", func() {
+			convey.So(validate.Password("password", "s3cret-enough"), convey.ShouldBeNil)
+			convey.So(validate.Password("password", "password"), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Prices should be positive, bounded and in a known currency\n", func() {
+			convey.So(validate.Price("price", price("9.99", "USD")), convey.ShouldBeNil)
+			convey.So(validate.Price("price", currency.Amount{}), convey.ShouldNotBeNil)
+			convey.So(validate.Price("price", price("0", "USD")), convey.ShouldNotBeNil)
+			convey.So(validate.Price("price", price("100000.01", "USD")), convey.ShouldNotBeNil)
+			convey.So(validate.Price("price", price("9.999", "USD")), convey.ShouldNotBeNil)
+			convey.So(validate.Price("price", price("500", "JPY")), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Track names should not be blank or contain control characters\n", func() {
+			convey.So(validate.TrackName("name", "Midnight City"), convey.ShouldBeNil)
+			convey.So(validate.TrackName("name", "   "), convey.ShouldNotBeNil)
+			convey.So(validate.TrackName("name", "Midnight\x00City"), convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFields(t *testing.T) {
+	convey.Convey("Collecting failed checks...", t, func() {
+		convey.Convey("Passing checks should give no error\n", func() {
+			convey.So(validate.Fields(validate.Required("name", "x"), nil), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Every failed field should be reported at once\n", func() {
+			err := validate.Fields(
+				validate.Required("name", ""),
+				validate.Email("email", "nope"),
+				validate.Count("scopes", 0, 1, 3),
+			)
+
+			var e *errs.Error
+			convey.So(errors.As(err, &e), convey.ShouldBeTrue)
+			convey.So(e.Code, convey.ShouldEqual, errs.CodeValidation)
+			convey.So(e.Fields, convey.ShouldHaveLength, 3)
+			convey.So(e.Fields[1].Field, convey.ShouldEqual, "email")
+		})
+	})
+}